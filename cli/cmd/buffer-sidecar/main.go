@@ -38,9 +38,10 @@ func newRootCommand() *cobra.Command {
 		return tryOpenFileUntilContainerExits(&namespace, &podName, &containerName, filePath, flag, perm)
 	})
 
+	// Buffers written by runs are marked as failed when the write fails, so that their readers do not wait for them.
 	writeCommand := cmd.NewBufferWriteCommand(func(filePath string, flag int, perm fs.FileMode) (*os.File, error) {
 		return tryOpenFileUntilContainerExits(&namespace, &podName, &containerName, filePath, flag, perm)
-	})
+	}, false)
 
 	commands := []*cobra.Command{readCommand, writeCommand}
 	for _, command := range commands {
@@ -59,6 +60,18 @@ The reason for this is to avoid hanging indefinitely if the container completes
 		rootCommand.AddCommand(command)
 	}
 
+	rootCommand.AddCommand(cmd.NewDatasetFetchCommand())
+
+	nodeName := ""
+	evictCommand := cmd.NewDatasetCacheEvictCommand(func(ctx context.Context) (map[string]bool, error) {
+		return listLivePodsOnNode(ctx, namespace, nodeName)
+	})
+	evictCommand.Flags().StringVar(&namespace, "namespace", "", "The namespace of the pods that mount datasets")
+	evictCommand.MarkFlagRequired("namespace")
+	evictCommand.Flags().StringVar(&nodeName, "node", "", "The name of the node whose cache is managed")
+	evictCommand.MarkFlagRequired("node")
+	rootCommand.AddCommand(evictCommand)
+
 	return rootCommand
 }
 
@@ -103,6 +116,34 @@ func tryOpenFileUntilContainerExits(namespace, podName, containerName *string, f
 	return inputFile, nil
 }
 
+// listLivePodsOnNode returns the UIDs of the pods in a namespace on a node that have not terminated.
+func listLivePodsOnNode(ctx context.Context, namespace, nodeName string) (map[string]bool, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster Kubernetes config: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	uids := make(map[string]bool)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			uids[string(pod.UID)] = true
+		}
+	}
+
+	return uids, nil
+}
+
 func main() {
 	err := newRootCommand().Execute()
 	if err != nil {
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/microsoft/tyger/cli/internal/cmd"
@@ -55,7 +56,10 @@ func newRootCommand() *cobra.Command {
 	rootCommand.AddCommand(cmd.NewLogoutCommand())
 	rootCommand.AddCommand(cmd.NewBufferCommand())
 	rootCommand.AddCommand(cmd.NewCodespecCommand())
+	rootCommand.AddCommand(cmd.NewSecretCommand())
+	rootCommand.AddCommand(cmd.NewDatasetCommand())
 	rootCommand.AddCommand(cmd.NewRunCommand())
+	rootCommand.AddCommand(cmd.NewPipelineCommand())
 	rootCommand.AddCommand(install.NewConfigCommand(rootCommand))
 	rootCommand.AddCommand(install.NewCloudCommand(rootCommand))
 	rootCommand.AddCommand(install.NewApiCommand(rootCommand))
@@ -67,6 +71,10 @@ func newRootCommand() *cobra.Command {
 func main() {
 	err := newRootCommand().Execute()
 	if err != nil {
+		var exitCodeErr *cmd.ExitCodeError
+		if errors.As(err, &exitCodeErr) {
+			os.Exit(exitCodeErr.Code)
+		}
 		os.Exit(1)
 	}
 }
@@ -9,10 +9,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -218,6 +220,255 @@ timeoutSeconds: 600`, BasicImage)
 	require.Equal("Hello: Bonjour", execStdOut)
 }
 
+func TestEndToEndExecWithCompression(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	runSpec := fmt.Sprintf(`
+job:
+  codespec:
+    image: %s
+    buffers:
+      inputs: ["input"]
+      outputs: ["output"]
+    command:
+      - "sh"
+      - "-c"
+      - |
+        set -euo pipefail
+        inp=$(cat "$INPUT_PIPE")
+        echo -n "${inp}: Bonjour" > "$OUTPUT_PIPE"
+  tags:
+    testName: TestEndToEndExecWithCompression
+timeoutSeconds: 600`, BasicImage)
+
+	tempDir := t.TempDir()
+	runSpecPath := filepath.Join(tempDir, "runspec.yaml")
+	require.NoError(os.WriteFile(runSpecPath, []byte(runSpec), 0644))
+
+	execStdOut := NewTygerCmdBuilder("run", "exec", "--file", runSpecPath, "--compression", "zstd", "--log-level", "trace").
+		Stdin("Hello").
+		RunSucceeds(t)
+
+	require.Equal("Hello: Bonjour", execStdOut)
+}
+
+func TestEndToEndExecWithTemplate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	runSpec := `
+job:
+  codespec:
+    image: {{ image }}
+    buffers:
+      inputs: ["input"]
+      outputs: ["output"]
+    command:
+      - "sh"
+      - "-c"
+      - |
+        set -euo pipefail
+        inp=$(cat "$INPUT_PIPE")
+        echo -n "${inp}: {{ greeting | default "Bonjour" }}" > "$OUTPUT_PIPE"
+  tags:
+    testName: TestEndToEndExecWithTemplate
+timeoutSeconds: 600`
+
+	runSpecPath := filepath.Join(t.TempDir(), "runspec.yaml")
+	require.NoError(os.WriteFile(runSpecPath, []byte(runSpec), 0644))
+
+	execStdOut := NewTygerCmdBuilder("run", "exec", "--file", runSpecPath, "--set", "image="+BasicImage, "--log-level", "trace").
+		Stdin("Hello").
+		RunSucceeds(t)
+	require.Equal("Hello: Bonjour", execStdOut)
+
+	execStdOut = NewTygerCmdBuilder("run", "exec", "--file", runSpecPath, "--set", "image="+BasicImage, "--set", "greeting=Hola", "--log-level", "trace").
+		Stdin("Hello").
+		RunSucceeds(t)
+	require.Equal("Hello: Hola", execStdOut)
+
+	// Values can contain commas and equal signs
+	execStdOut = NewTygerCmdBuilder("run", "exec", "--file", runSpecPath, "--set", "image="+BasicImage, "--set", "greeting=Hola, a=b", "--log-level", "trace").
+		Stdin("Hello").
+		RunSucceeds(t)
+	require.Equal("Hello: Hola, a=b", execStdOut)
+
+	// Values are YAML scalars, so characters that are significant in YAML do not change the document
+	execStdOut = NewTygerCmdBuilder("run", "exec", "--file", runSpecPath, "--set", "image="+BasicImage, "--set", "greeting=[Hola]: a # b", "--log-level", "trace").
+		Stdin("Hello").
+		RunSucceeds(t)
+	require.Equal("Hello: [Hola]: a # b", execStdOut)
+
+	_, stderr, err := runTyger("run", "create", "--file", runSpecPath, "--set", "greeting=Hola", "--set", "unknown=1")
+	require.Error(err)
+	require.Contains(stderr, "line 4: the required parameter 'image' has no value")
+	require.Contains(stderr, "the parameter 'unknown' given with --set is not used")
+}
+
+func TestEndToEndExecWithMultipleBuffers(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	runSpec := fmt.Sprintf(`
+job:
+  codespec:
+    image: %s
+    buffers:
+      inputs: ["raw", "noise"]
+      outputs: ["images", "qa"]
+    command:
+      - "sh"
+      - "-c"
+      - |
+        set -euo pipefail
+        raw=$(cat "$RAW_PIPE")
+        noise=$(cat "$NOISE_PIPE")
+        echo -n "${raw}+${noise}" > "$IMAGES_PIPE"
+        echo -n "ok" > "$QA_PIPE"
+  tags:
+    testName: TestEndToEndExecWithMultipleBuffers
+timeoutSeconds: 600`, BasicImage)
+
+	tempDir := t.TempDir()
+	runSpecPath := filepath.Join(tempDir, "runspec.yaml")
+	require.NoError(os.WriteFile(runSpecPath, []byte(runSpec), 0644))
+
+	noisePath := filepath.Join(tempDir, "noise")
+	require.NoError(os.WriteFile(noisePath, []byte("noise"), 0644))
+	imagesPath := filepath.Join(tempDir, "images")
+
+	// raw is streamed from stdin and qa to stdout, since they are not bound to files.
+	execStdOut := NewTygerCmdBuilder("run", "exec", "--file", runSpecPath, "--input", "noise="+noisePath, "--output", "images="+imagesPath, "--log-level", "trace").
+		Stdin("raw").
+		RunSucceeds(t)
+
+	require.Equal("ok", execStdOut)
+
+	images, err := os.ReadFile(imagesPath)
+	require.NoError(err)
+	require.Equal("raw+noise", string(images))
+
+	_, stderr, err := runTyger("run", "exec", "--file", runSpecPath, "--input", "noise="+noisePath)
+	require.Error(err)
+	require.Contains(stderr, "multiple unmapped output buffers")
+
+	_, stderr, err = runTyger("run", "exec", "--file", runSpecPath, "--input", "missing="+noisePath, "--output", "images="+imagesPath)
+	require.Error(err)
+	require.Contains(stderr, "'missing' is not an unmapped input buffer")
+}
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	outputBufferId := runTygerSucceeds(t, "buffer", "create")
+
+	pipelineSpec := fmt.Sprintf(`
+tags:
+  testName: TestPipeline
+stages:
+  - name: preprocess
+    job:
+      codespec:
+        image: %[1]s
+        buffers:
+          outputs: ["output"]
+        command:
+          - "sh"
+          - "-c"
+          - echo -n "Hello" > "$OUTPUT_PIPE"
+  - name: recon
+    inputs:
+      input: preprocess.output
+    job:
+      codespec:
+        image: %[1]s
+        buffers:
+          inputs: ["input"]
+          outputs: ["output"]
+        command:
+          - "sh"
+          - "-c"
+          - |
+            set -euo pipefail
+            inp=$(cat "$INPUT_PIPE")
+            echo -n "${inp}: Bonjour" > "$OUTPUT_PIPE"
+      buffers:
+        output: %[2]s
+    timeoutSeconds: 600`, BasicImage, outputBufferId)
+
+	pipelineSpecPath := filepath.Join(t.TempDir(), "pipeline.yaml")
+	require.NoError(os.WriteFile(pipelineSpecPath, []byte(pipelineSpec), 0644))
+
+	runIds := map[string]int64{}
+	require.NoError(json.Unmarshal([]byte(runTygerSucceeds(t, "pipeline", "create", "-f", pipelineSpecPath)), &runIds))
+	require.Len(runIds, 2)
+
+	reconRun := getRun(t, strconv.FormatInt(runIds["recon"], 10))
+	require.Equal(model.Succeeded, *reconRun.Status)
+
+	preprocessRun := getRun(t, strconv.FormatInt(runIds["preprocess"], 10))
+	require.Equal(reconRun.Job.Buffers["input"], preprocessRun.Job.Buffers["output"])
+
+	require.Equal("Hello: Bonjour", runTygerSucceeds(t, "buffer", "read", outputBufferId))
+}
+
+func TestPipelineFailureCancelsOtherStages(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pipelineSpec := fmt.Sprintf(`
+stages:
+  - name: failing
+    job:
+      codespec:
+        image: %[1]s
+        command: ["sh", "-c", "exit 1"]
+  - name: slow
+    job:
+      codespec:
+        image: %[1]s
+        command: ["sh", "-c", "sleep 600"]`, BasicImage)
+
+	pipelineSpecPath := filepath.Join(t.TempDir(), "pipeline.yaml")
+	require.NoError(os.WriteFile(pipelineSpecPath, []byte(pipelineSpec), 0644))
+
+	stdout, stderr, err := runTyger("pipeline", "create", "-f", pipelineSpecPath)
+	require.Error(err)
+	require.Contains(stderr, "stage 'failing' did not succeed")
+
+	runIds := map[string]int64{}
+	require.NoError(json.Unmarshal([]byte(stdout), &runIds))
+
+	waitForRunCanceled(t, strconv.FormatInt(runIds["slow"], 10))
+}
+
+func TestPipelineWithCycle(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pipelineSpec := `
+stages:
+  - name: a
+    inputs:
+      input: b.output
+    job:
+      codespec: a
+  - name: b
+    inputs:
+      input: a.output
+    job:
+      codespec: b`
+
+	pipelineSpecPath := filepath.Join(t.TempDir(), "pipeline.yaml")
+	require.NoError(os.WriteFile(pipelineSpecPath, []byte(pipelineSpec), 0644))
+
+	_, stderr, err := runTyger("pipeline", "create", "-f", pipelineSpecPath)
+	require.Error(err)
+	require.Contains(stderr, "cycle")
+}
+
 func TestCodespecBufferTagsWithYamlSpec(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
@@ -558,6 +809,235 @@ func TestTargetCpuNodePoolWithGpuResourcesReturnsError(t *testing.T) {
 	require.Contains(t, stderr, "does not have GPUs and cannot satisfy GPU request")
 }
 
+func TestCodespecValidate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	tempDir := t.TempDir()
+	validSpecPath := filepath.Join(tempDir, "valid.yaml")
+	require.NoError(os.WriteFile(validSpecPath, []byte(fmt.Sprintf(`
+image: %s
+resources:
+  gpu: 1
+`, BasicImage)), 0644))
+
+	require.Equal("The codespec is valid.", runTygerSucceeds(t, "codespec", "validate", "-f", validSpecPath))
+	require.Equal("The codespec is valid.", runTygerSucceeds(t, "codespec", "validate", "-f", validSpecPath, "--node-pool", "gpunp"))
+
+	_, stderr, err := runTyger("codespec", "validate", "-f", validSpecPath, "--node-pool", "cpunp")
+	require.Error(err)
+	require.Contains(stderr, "does not have GPUs")
+
+	invalidSpecPath := filepath.Join(tempDir, "invalid.yaml")
+	require.NoError(os.WriteFile(invalidSpecPath, []byte(`
+kind: job
+image: mcr.microsoft.com/this/image/does-not-exist:1.0
+maxReplicas: 0
+resources:
+  requests:
+    memory: 2G
+  limits:
+    memory: 1G
+`), 0644))
+
+	_, stderr, err = runTyger("codespec", "validate", "-f", invalidSpecPath, "--cluster", "invalid")
+	require.Error(err)
+	require.Contains(stderr, "the codespec has 4 problems")
+	require.Contains(stderr, "maxReplicas: The maxReplicas must be at least 1.")
+	require.Contains(stderr, "resources.requests.memory: The memory request '2G' is greater than the memory limit '1G'.")
+	require.Contains(stderr, "cluster: Unknown cluster 'invalid'")
+	require.Contains(stderr, "image: the image 'mcr.microsoft.com/this/image/does-not-exist:1.0' was not found")
+
+	// a request that no node can satisfy, like a mistyped unit
+	tooLargeSpecPath := filepath.Join(tempDir, "too-large.yaml")
+	require.NoError(os.WriteFile(tooLargeSpecPath, []byte(fmt.Sprintf(`
+image: %s
+resources:
+  requests:
+    memory: 64Ti
+`, BasicImage)), 0644))
+
+	_, stderr, err = runTyger("codespec", "validate", "-f", tooLargeSpecPath, "--node-pool", "cpunp")
+	require.Error(err)
+	require.Contains(stderr, "resources.requests.memory: The memory request '64Ti' is greater than the allocatable memory of every node")
+}
+
+func TestCodespecSecretRefs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	secretName := strings.ToLower(t.Name())
+	codespecName := strings.ToLower(t.Name())
+	secretValue := "value-" + uuid.NewString()
+
+	tempDir := t.TempDir()
+	valueFile := filepath.Join(tempDir, "value.txt")
+	require.NoError(os.WriteFile(valueFile, []byte("from-file"), 0644))
+
+	require.Equal(secretName, runTygerSucceeds(t, "secret", "create", secretName, "--from-literal", "key="+secretValue, "--from-file", "other="+valueFile))
+	t.Cleanup(func() {
+		runTyger("secret", "delete", secretName)
+	})
+
+	secrets := runTygerSucceeds(t, "secret", "list", "-o", "json")
+	require.Contains(secrets, secretName)
+	require.NotContains(secrets, secretValue)
+
+	runTygerSucceeds(t,
+		"codespec", "create", codespecName,
+		"--image", BasicImage,
+		"--env", "PLAIN=plain",
+		"--secret-env", "FROM_SECRET="+secretName+"/key",
+		"--command", "--", "sh", "-c", `echo "$PLAIN $FROM_SECRET"`)
+
+	codespec := runTygerSucceeds(t, "codespec", "show", codespecName)
+	require.Contains(codespec, secretName+"/key")
+	require.NotContains(codespec, secretValue)
+
+	runId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName, "--timeout", "10m")
+	waitForRunSuccess(t, runId)
+	require.Equal("plain "+secretValue, runTygerSucceeds(t, "run", "logs", runId))
+
+	specPath := filepath.Join(tempDir, "spec.yaml")
+	require.NoError(os.WriteFile(specPath, []byte(fmt.Sprintf(`
+image: %s
+env:
+  MISSING_KEY:
+    secretRef: %s/missing
+  MISSING_SECRET:
+    secretRef: missing-%s/key
+`, BasicImage, secretName, secretName)), 0644))
+
+	_, stderr, err := runTyger("codespec", "validate", "-f", specPath)
+	require.Error(err)
+	require.Contains(stderr, fmt.Sprintf("env: The secret '%s' referenced by the environment variable 'MISSING_KEY' does not have the key 'missing'.", secretName))
+
+	// the codespec can be created, but not a run that uses it
+	runTygerSucceeds(t, "codespec", "create", codespecName+"-missing", "-f", specPath)
+	_, stderr, err = runTyger("run", "create", "--codespec", codespecName+"-missing", "--timeout", "10m")
+	require.Error(err)
+	require.Contains(stderr, "referenced by the environment variable")
+
+	require.Equal(secretName, runTygerSucceeds(t, "secret", "delete", secretName))
+	_, _, err = runTyger("run", "create", "--codespec", codespecName, "--timeout", "10m")
+	require.Error(err)
+}
+
+func TestDatasetMounts(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	datasetName := strings.ToLower(t.Name())
+	codespecName := strings.ToLower(t.Name())
+
+	tempDir := t.TempDir()
+	dataDir := filepath.Join(tempDir, "data")
+	require.NoError(os.MkdirAll(filepath.Join(dataDir, "nested"), 0755))
+	require.NoError(os.WriteFile(filepath.Join(dataDir, "nested", "file.txt"), []byte("v1"), 0644))
+
+	require.Equal("1", runTygerSucceeds(t, "dataset", "upload", datasetName, dataDir))
+
+	require.NoError(os.WriteFile(filepath.Join(dataDir, "nested", "file.txt"), []byte("v2"), 0644))
+	require.Equal("2", runTygerSucceeds(t, "dataset", "upload", datasetName, dataDir))
+
+	dataset := model.Dataset{}
+	require.NoError(json.Unmarshal([]byte(runTygerSucceeds(t, "dataset", "show", datasetName)), &dataset))
+	require.Equal(2, dataset.Version)
+
+	require.NoError(json.Unmarshal([]byte(runTygerSucceeds(t, "dataset", "show", datasetName, "--version", "1")), &dataset))
+	require.Equal(1, dataset.Version)
+
+	require.Contains(runTygerSucceeds(t, "dataset", "list", "-o", "json"), datasetName)
+
+	// the buffer of a dataset version cannot be deleted
+	_, _, err := runTyger("buffer", "delete", dataset.BufferId)
+	require.Error(err)
+
+	runTygerSucceeds(t,
+		"codespec", "create", codespecName,
+		"--image", BasicImage,
+		"--mount", datasetName+"=/data",
+		"--command", "--", "cat", "/data/nested/file.txt")
+
+	runId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName, "--timeout", "10m")
+	run := waitForRunSuccess(t, runId)
+	require.Equal(map[string]int{datasetName: 2}, run.Job.Datasets)
+	require.Equal("v2", runTygerSucceeds(t, "run", "logs", runId))
+
+	runId = runTygerSucceeds(t, "run", "create", "--codespec", codespecName, "--dataset", datasetName+"=1", "--timeout", "10m")
+	run = waitForRunSuccess(t, runId)
+	require.Equal(map[string]int{datasetName: 1}, run.Job.Datasets)
+	require.Equal("v1", runTygerSucceeds(t, "run", "logs", runId))
+
+	_, stderr, err := runTyger("run", "create", "--codespec", codespecName, "--dataset", "missing-"+datasetName+"=1", "--timeout", "10m")
+	require.Error(err)
+	require.Contains(stderr, "missing-"+datasetName)
+
+	specPath := filepath.Join(tempDir, "spec.yaml")
+	require.NoError(os.WriteFile(specPath, []byte(fmt.Sprintf(`
+image: %s
+mounts:
+  - dataset: %s
+    version: 100
+    path: /data
+  - dataset: missing-%s
+    path: /other
+`, BasicImage, datasetName, datasetName)), 0644))
+
+	_, stderr, err = runTyger("codespec", "validate", "-f", specPath)
+	require.Error(err)
+	require.Contains(stderr, fmt.Sprintf("mounts: The version '100' of dataset '%s' was not found", datasetName))
+}
+
+func TestCodespecCreateFromImage(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	codespecName := strings.ToLower(t.Name())
+
+	// A stand-in registry that serves a single image with tyger labels
+	imageConfig, err := json.Marshal(map[string]any{"config": map[string]any{"Labels": map[string]string{
+		"tyger.buffers.inputs":         "input",
+		"tyger.buffers.outputs":        "output",
+		"tyger.resources.gpu":          "1",
+		"tyger.resources.requests.cpu": "500m",
+		"tyger.max-replicas":           "2",
+		"tyger.future-setting":         "ignored",
+	}}})
+	require.NoError(err)
+	configDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(imageConfig))
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"%s"}}`, configDigest)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/app/manifests/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Write([]byte(manifest))
+	})
+	mux.HandleFunc("/v2/app/blobs/"+configDigest, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(imageConfig)
+	})
+	registry := httptest.NewServer(mux)
+	defer registry.Close()
+
+	image := strings.TrimPrefix(registry.URL, "http://") + "/app:1"
+
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--from-image", image, "--gpu", "2", "--output", "result")
+
+	codespec := model.Codespec{}
+	require.NoError(json.Unmarshal([]byte(runTygerSucceeds(t, "codespec", "show", codespecName)), &codespec))
+	require.Equal(image, codespec.Image)
+	require.Equal("job", codespec.Kind)
+	require.Equal([]string{"input"}, codespec.Buffers.Inputs)
+	require.Equal([]string{"result"}, codespec.Buffers.Outputs)
+	require.Equal("2", codespec.Resources.Gpu.String())
+	require.Equal("500m", codespec.Resources.Requests.Cpu.String())
+	require.Equal(2, *codespec.MaxReplicas)
+
+	_, stderr, err := runTyger("codespec", "create", codespecName, "--from-image", strings.TrimSuffix(image, ":1")+":2")
+	require.Error(err)
+	require.Contains(stderr, "was not found in the registry")
+}
+
 func TestUnrecognizedFieldsRejected(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
@@ -735,6 +1215,39 @@ func TestRecreateCodespec(t *testing.T) {
 	require.NotEqual(t, version6, version7)
 }
 
+func TestCodespecHistoryAndDiff(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	codespecName := strings.ToLower(t.Name() + uuid.NewString())
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--image", "busybee", "--env", "os=ubuntu", "--command", "--", "echo", "hi")
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--image", BasicImage, "--env", "os=ubuntu", "--command", "--", "echo", "hi")
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--image", BasicImage, "--env", "os=windows", "--cpu-request", "1", "--command", "--", "echo", "hi")
+
+	history := make([]map[string]any, 0)
+	require.NoError(json.Unmarshal([]byte(runTygerSucceeds(t, "codespec", "history", codespecName)), &history))
+	require.Len(history, 3)
+	require.EqualValues(3, history[0]["version"])
+	require.EqualValues(1, history[2]["version"])
+	require.Empty(history[2]["changes"])
+
+	require.Equal("env.os resources.requests.cpu", runTygerSucceeds(t, "codespec", "history", codespecName, "-o", "jsonpath={[0].changes[*].field}", "--limit", "1"))
+	require.Equal("image", runTygerSucceeds(t, "codespec", "history", codespecName, "-o", "jsonpath={[1].changes[*].field}"))
+
+	changes := make([]map[string]any, 0)
+	require.NoError(json.Unmarshal([]byte(runTygerSucceeds(t, "codespec", "diff", codespecName, "1", "3")), &changes))
+	require.Equal([]map[string]any{
+		{"field": "env.os", "from": "ubuntu", "to": "windows"},
+		{"field": "image", "from": "busybee", "to": BasicImage},
+		{"field": "resources.requests.cpu", "from": nil, "to": "1"},
+	}, changes)
+
+	require.Equal("[]", runTygerSucceeds(t, "codespec", "diff", codespecName, "2", "2"))
+
+	_, _, err := runTyger("codespec", "history", codespecName+"-missing")
+	require.Error(err)
+}
+
 func TestListCodespecsPaging(t *testing.T) {
 	t.Parallel()
 	ctx, _ := getServiceInfoContext(t)
@@ -841,6 +1354,74 @@ func TestListRunsSince(t *testing.T) {
 	require.Fail(t, "last run not found")
 }
 
+func TestListRunsWithFilters(t *testing.T) {
+	t.Parallel()
+
+	codespecName := strings.ToLower(t.Name())
+	tag := fmt.Sprintf("testName=%s-%d", t.Name(), time.Now().UnixNano())
+
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--image", BasicImage, "--command", "--", "echo", "hi")
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--image", BasicImage, "--command", "--", "sleep", "600")
+
+	succeededId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName, "--version", "1", "--tag", tag, "--timeout", "10m")
+	waitForRunSuccess(t, succeededId)
+
+	canceledId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName, "--version", "2", "--tag", tag, "--timeout", "10m")
+	runTygerSucceeds(t, "run", "cancel", canceledId)
+	waitForRunCanceled(t, canceledId)
+
+	listIds := func(args ...string) []string {
+		t.Helper()
+		list := make([]model.Run, 0)
+		require.NoError(t, json.Unmarshal([]byte(runTygerSucceeds(t, append([]string{"run", "list"}, args...)...)), &list))
+		ids := make([]string, 0, len(list))
+		for _, r := range list {
+			ids = append(ids, fmt.Sprint(r.Id))
+		}
+		return ids
+	}
+
+	require.ElementsMatch(t, []string{succeededId, canceledId}, listIds("--tag", tag))
+	require.ElementsMatch(t, []string{succeededId}, listIds("--tag", tag, "--status", "succeeded"))
+	require.ElementsMatch(t, []string{canceledId}, listIds("--tag", tag, "--status", "canceled"))
+	require.ElementsMatch(t, []string{succeededId, canceledId}, listIds("--tag", tag, "--codespec", codespecName))
+	require.ElementsMatch(t, []string{succeededId}, listIds("--tag", tag, "--codespec", codespecName, "--version", "1"))
+	require.Empty(t, listIds("--tag", tag, "--codespec", codespecName, "--version", "3"))
+	require.Empty(t, listIds("--tag", tag, "--finished-before", "2000-01-01T00:00:00Z"))
+
+	_, stderr, err := runTyger("run", "list", "--status", "unknown")
+	require.Error(t, err)
+	require.Contains(t, stderr, "Invalid status")
+}
+
+func TestOutputFormats(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	codespecName := strings.ToLower(t.Name())
+	version := runTygerSucceeds(t, "codespec", "create", codespecName, "--image", BasicImage, "--command", "--", "echo", "hi")
+
+	yamlOutput := runTygerSucceeds(t, "codespec", "show", codespecName, "-o", "yaml")
+	codespec := model.Codespec{}
+	require.NoError(yaml.Unmarshal([]byte(yamlOutput), &codespec))
+	require.Equal(codespecName, codespec.Name)
+	require.Equal(BasicImage, codespec.Image)
+
+	require.Equal(codespecName, runTygerSucceeds(t, "codespec", "show", codespecName, "-o", "jsonpath={.name}"))
+	require.Equal(fmt.Sprintf("%s:%s", codespecName, version), runTygerSucceeds(t, "codespec", "show", codespecName, "-o", "go-template={{.name}}:{{.version}}"))
+
+	tableLines := strings.Split(runTygerSucceeds(t, "codespec", "show", codespecName, "-o", "table"), "\n")
+	require.Len(tableLines, 2)
+	require.Equal([]string{"NAME", "VERSION", "KIND", "IMAGE", "CREATED"}, strings.Fields(tableLines[0]))
+	require.Equal([]string{codespecName, version, "job", BasicImage}, strings.Fields(tableLines[1])[:4])
+
+	require.Equal(codespecName, runTygerSucceeds(t, "codespec", "list", "--prefix", codespecName, "-o", "jsonpath={[*].name}"))
+
+	_, stderr, err := runTyger("codespec", "show", codespecName, "-o", "xml")
+	require.Error(err)
+	require.Contains(stderr, "invalid output format")
+}
+
 func TestListCodespecsWithPrefix(t *testing.T) {
 	t.Parallel()
 	ctx, _ := getServiceInfoContext(t)
@@ -1058,6 +1639,211 @@ func TestSpecifyingCacheFileAsEnvironmentVariable(t *testing.T) {
 		RunSucceeds(t)
 }
 
+func TestRunRetries(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	runSpec := fmt.Sprintf(`
+job:
+  codespec:
+    image: %s
+    command: ["sh", "-c", "exit 3"]
+retryPolicy:
+  maxAttempts: 2
+  retryableReasons: ["Error"]
+timeoutSeconds: 600`, BasicImage)
+
+	runSpecPath := filepath.Join(t.TempDir(), "runspec.yaml")
+	require.NoError(os.WriteFile(runSpecPath, []byte(runSpec), 0644))
+
+	_, stderr, err := runTyger("run", "create", "--file", runSpecPath, "--retry-backoff", "2h")
+	require.Error(err)
+	require.Contains(stderr, "The backoffSeconds of the retry policy must be between 0 and 3600")
+
+	runId := runTygerSucceeds(t, "run", "create", "--file", runSpecPath, "--retry-backoff", "5s")
+
+	var run model.Run
+	for deadline := time.Now().Add(5 * time.Minute); ; {
+		run = getRun(t, runId)
+		if run.Status != nil && *run.Status == model.Failed {
+			break
+		}
+		require.True(time.Now().Before(deadline), "timed out waiting for the run to fail")
+		time.Sleep(5 * time.Second)
+	}
+
+	require.NotNil(run.RetryPolicy)
+	require.Equal(2, run.RetryPolicy.MaxAttempts)
+	require.Equal(5, *run.RetryPolicy.BackoffSeconds)
+	require.Len(run.Attempts, 2)
+	for i, attempt := range run.Attempts {
+		require.Equal(0, attempt.Replica)
+		require.Equal(i+1, attempt.Attempt)
+		require.Equal(model.Failed, attempt.Status)
+		require.Contains(attempt.StatusReason, "exit code 3")
+	}
+}
+
+func TestRunRetriesWithOutputBuffer(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	codespecName := strings.ToLower(t.Name())
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--image", BasicImage, "--output", "output", "--command", "--", "sh", "-c", "echo hi > $OUTPUT_PIPE")
+
+	_, stderr, err := runTyger("run", "create", "--codespec", codespecName, "--retries", "2")
+	require.Error(err)
+	require.Contains(stderr, "cannot be retried")
+}
+
+func TestRunUsage(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	codespecName := strings.ToLower(t.Name())
+	runTygerSucceeds(t, "codespec", "create", codespecName, "-i=input", "-o=output", "--image", BasicImage, "--command", "--", "sh", "-c", `cat "$INPUT_PIPE" > "$OUTPUT_PIPE"`)
+
+	inputBufferId := runTygerSucceeds(t, "buffer", "create")
+	inputSasUri := runTygerSucceeds(t, "buffer", "access", inputBufferId, "-w")
+	runCommandSucceeds(t, "sh", "-c", fmt.Sprintf(`echo "Hello" | tyger buffer write "%s"`, inputSasUri))
+
+	runId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName, "--timeout", "10m", "-b", fmt.Sprintf("input=%s", inputBufferId))
+	waitForRunSuccess(t, runId)
+
+	// The usage is completed when the run is finalized, which can happen after it has succeeded
+	var run model.Run
+	for deadline := time.Now().Add(2 * time.Minute); ; {
+		run = getRun(t, runId)
+		if run.Usage != nil && len(run.Usage.Replicas) > 0 && run.Usage.Replicas[0].WallTimeSeconds != nil {
+			break
+		}
+		require.True(time.Now().Before(deadline), "timed out waiting for the run usage")
+		time.Sleep(5 * time.Second)
+	}
+
+	require.Len(run.Usage.Replicas, 1)
+	require.Equal("job", run.Usage.Replicas[0].Target)
+	require.Equal(0, run.Usage.Replicas[0].Replica)
+
+	require.Len(run.Usage.Buffers, 2)
+	for _, b := range run.Usage.Buffers {
+		require.NotNil(b.SizeBytes)
+		require.Equal(int64(len("Hello\n")), *b.SizeBytes)
+	}
+
+	summaries := make([]map[string]any, 0)
+	require.NoError(json.Unmarshal([]byte(runTygerSucceeds(t, "run", "usage", runId)), &summaries))
+	require.Len(summaries, 1)
+	require.Equal(runId, summaries[0]["group"])
+	require.EqualValues(len("Hello\n"), summaries[0]["inputBufferBytes"])
+	require.EqualValues(len("Hello\n"), summaries[0]["outputBufferBytes"])
+
+	require.Equal(codespecName, runTygerSucceeds(t, "run", "usage", runId, "--group-by", "codespec", "-o", "jsonpath={[0].group}"))
+
+	_, stderr, err := runTyger("run", "usage", runId, "--status", "failed")
+	require.Error(err)
+	require.Contains(stderr, "cannot be combined")
+
+	_, stderr, err = runTyger("run", "usage", "--codespec", codespecName, "--group-by", "codespec", "--limit", "5")
+	require.Error(err)
+	require.Contains(stderr, "--limit cannot be combined with --group-by")
+}
+
+func TestRunExecShell(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	codespecName := strings.ToLower(t.Name())
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--image", BasicImage, "--command", "--", "sleep", "600")
+
+	runId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName, "--timeout", "10m")
+	defer runTyger("run", "cancel", runId)
+	waitForRunStarted(t, runId)
+
+	require.Equal("hello", runTygerSucceeds(t, "run", "exec-shell", runId, "--", "echo", "hello"))
+
+	_, stderr, err := runTyger("run", "exec-shell", runId, "--", "sh", "-c", "echo failing >&2; exit 3")
+	var exitError *exec.ExitError
+	require.ErrorAs(err, &exitError)
+	require.Equal(3, exitError.ExitCode())
+	require.Contains(stderr, "failing")
+
+	_, stderr, err = runTyger("run", "exec-shell", runId, "--replica", "1", "--", "true")
+	require.Error(err)
+	require.Contains(stderr, "the replica must be between 0 and 0")
+}
+
+func TestRunEvents(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	codespecName := strings.ToLower(t.Name())
+	runTygerSucceeds(t, "codespec", "create", codespecName, "--image", BasicImage, "--command", "--", "sh", "-c", "sleep 5; exit 2")
+
+	runId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName, "--timeout", "10m")
+
+	// With --watch, events are written as JSON lines until the run completes
+	events := make([]model.RunEvent, 0)
+	for _, line := range strings.Split(runTygerSucceeds(t, "run", "events", runId, "--watch"), "\n") {
+		event := model.RunEvent{}
+		require.NoError(json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+
+	eventsByType := make(map[string]model.RunEvent)
+	for i, event := range events {
+		if i > 0 {
+			require.False(event.Timestamp.Before(events[i-1].Timestamp), "events are not in chronological order")
+		}
+		eventsByType[event.Type] = event
+	}
+
+	require.Equal("created", events[0].Type)
+	require.Equal("finished", events[len(events)-1].Type)
+	require.Contains(eventsByType, "scheduled")
+
+	require.Contains(eventsByType, "replicaStarted")
+	require.Equal("job", eventsByType["replicaStarted"].Target)
+	require.Equal(0, *eventsByType["replicaStarted"].Replica)
+
+	require.Contains(eventsByType, "replicaExited")
+	require.Equal(2, *eventsByType["replicaExited"].ExitCode)
+
+	tableLines := strings.Split(runTygerSucceeds(t, "run", "events", runId, "-o", "table"), "\n")
+	require.Equal([]string{"TIME", "TYPE", "REPLICA", "MESSAGE"}, strings.Fields(tableLines[0]))
+}
+
+func TestRunWait(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	codespecName := strings.ToLower(t.Name())
+	runTygerSucceeds(t, "codespec", "create", codespecName+"-succeed", "--image", BasicImage, "--command", "--", "true")
+	runTygerSucceeds(t, "codespec", "create", codespecName+"-fail", "--image", BasicImage, "--command", "--", "false")
+	runTygerSucceeds(t, "codespec", "create", codespecName+"-sleep", "--image", BasicImage, "--command", "--", "sleep", "600")
+
+	succeededRunId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName+"-succeed", "--timeout", "10m")
+	runTygerSucceeds(t, "run", "wait", succeededRunId)
+
+	failedRunId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName+"-fail", "--timeout", "10m")
+	_, stderr, err := runTyger("run", "wait", failedRunId)
+	var exitError *exec.ExitError
+	require.ErrorAs(err, &exitError)
+	require.Equal(2, exitError.ExitCode())
+	require.Contains(stderr, fmt.Sprintf("run %s failed", failedRunId))
+
+	sleepingRunId := runTygerSucceeds(t, "run", "create", "--codespec", codespecName+"-sleep", "--timeout", "10m")
+	_, stderr, err = runTyger("run", "wait", sleepingRunId, "--timeout", "2s")
+	require.ErrorAs(err, &exitError)
+	require.Equal(4, exitError.ExitCode())
+	require.Contains(stderr, "did not complete within 2s")
+
+	runTygerSucceeds(t, "run", "cancel", sleepingRunId)
+	_, _, err = runTyger("run", "wait", sleepingRunId)
+	require.ErrorAs(err, &exitError)
+	require.Equal(3, exitError.ExitCode())
+}
+
 func TestCancelJob(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
@@ -1288,6 +2074,89 @@ func TestBufferListWithoutTags(t *testing.T) {
 	require.Contains(buffers, buffer)
 }
 
+func TestBufferDelete(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	bufferId := runTygerSucceeds(t, "buffer", "create")
+	require.Equal(bufferId, runTygerSucceeds(t, "buffer", "delete", bufferId))
+
+	bufferJson := runTygerSucceeds(t, "buffer", "show", bufferId)
+	var buffer model.Buffer
+	require.NoError(json.Unmarshal([]byte(bufferJson), &buffer))
+	require.NotNil(buffer.DeletedAt)
+
+	_, stderr, err := runTyger("buffer", "access", bufferId)
+	require.Error(err)
+	require.Contains(stderr, "has been deleted")
+
+	bufferJson = runTygerSucceeds(t, "buffer", "list")
+	var buffers []model.Buffer
+	require.NoError(json.Unmarshal([]byte(bufferJson), &buffers))
+	for _, b := range buffers {
+		require.NotEqual(bufferId, b.Id)
+	}
+}
+
+func TestBufferDeleteWithTags(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	uniqueId := uuid.New().String()
+
+	bufferId1 := runTygerSucceeds(t, "buffer", "create", "--tag", "testtag1=testvalue1", "--tag", "testtagX="+uniqueId)
+	bufferId2 := runTygerSucceeds(t, "buffer", "create", "--tag", "testtag1=testvalue2", "--tag", "testtagX="+uniqueId)
+
+	require.Equal("", runTygerSucceeds(t, "buffer", "delete", "--tag", "testtagX="+uniqueId, "--older-than", "1d", "--yes"))
+
+	// Without --yes, confirmation is required, which is not possible without a terminal
+	_, stderr, err := runTyger("buffer", "delete", "--tag", "testtag1=testvalue1", "--tag", "testtagX="+uniqueId)
+	require.Error(err)
+	require.Contains(stderr, "Use --yes")
+
+	require.Equal(bufferId1, runTygerSucceeds(t, "buffer", "delete", "--tag", "testtag1=testvalue1", "--tag", "testtagX="+uniqueId, "--dry-run"))
+	require.Equal(bufferId1, runTygerSucceeds(t, "buffer", "delete", "--tag", "testtag1=testvalue1", "--tag", "testtagX="+uniqueId, "--yes"))
+
+	bufferJson := runTygerSucceeds(t, "buffer", "list", "--tag", "testtagX="+uniqueId)
+	var buffers []model.Buffer
+	require.NoError(json.Unmarshal([]byte(bufferJson), &buffers))
+	require.Equal(1, len(buffers))
+	require.Equal(bufferId2, buffers[0].Id)
+
+	_, _, err = runTyger("buffer", "delete", bufferId2, "--tag", "testtagX="+uniqueId)
+	require.Error(err)
+
+	_, stderr, err = runTyger("buffer", "delete", "--older-than", "1d")
+	require.Error(err)
+	require.Contains(stderr, "--all")
+
+	_, stderr, err = runTyger("buffer", "delete", "--tag", "testtagX="+uniqueId, "--older-than", "-1h", "--yes")
+	require.Error(err)
+	require.Contains(stderr, "--older-than must be a positive duration")
+}
+
+func TestBufferWithTtl(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	bufferJson := runTygerSucceeds(t, "buffer", "create", "--ttl", "2d", "--full-resource")
+	var buffer model.Buffer
+	require.NoError(json.Unmarshal([]byte(bufferJson), &buffer))
+
+	require.NotNil(buffer.TtlSeconds)
+	require.Equal(2*24*60*60, *buffer.TtlSeconds)
+	require.NotNil(buffer.ExpiresAt)
+	require.WithinDuration(buffer.CreatedAt.Add(48*time.Hour), *buffer.ExpiresAt, time.Second)
+	require.Nil(buffer.DeletedAt)
+}
+
+func getRun(t *testing.T, runId string) model.Run {
+	t.Helper()
+	run := model.Run{}
+	require.NoError(t, json.Unmarshal([]byte(runTygerSucceeds(t, "run", "show", runId)), &run))
+	return run
+}
+
 func waitForRunStarted(t *testing.T, runId string) model.Run {
 	t.Helper()
 	return waitForRun(t, runId, true, false)
@@ -11,12 +11,14 @@ import (
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -25,6 +27,7 @@ import (
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/microsoft/tyger/cli/internal/cmd"
+	"github.com/microsoft/tyger/cli/internal/controlplane/model"
 	"github.com/microsoft/tyger/cli/internal/dataplane"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -386,6 +389,276 @@ func TestBufferDoubleWriteFailure(t *testing.T) {
 	require.NotEqual(t, 0, exitError.ExitCode(), "Second call to buffer write had unexpected exit code")
 }
 
+func TestBufferWriteResume(t *testing.T) {
+	t.Parallel()
+
+	bufferId := runTygerSucceeds(t, "buffer", "create")
+	writeSasUri := runTygerSucceeds(t, "buffer", "access", bufferId, "-w")
+
+	blockSize := 1024
+	inputBuffer := &bytes.Buffer{}
+	require.NoError(t, cmd.Gen(int64(blockSize*10+10), inputBuffer))
+	input := inputBuffer.Bytes()
+
+	// Simulate losing the connection after the first five blobs have been written
+	failingClient := newInterceptingHttpClient(func(req *http.Request, inner http.RoundTripper) (*http.Response, error) {
+		if req.Method == http.MethodPut && (strings.HasSuffix(req.URL.Path, "/005") || strings.HasSuffix(req.URL.Path, dataplane.EndMetadataBlobName)) {
+			return &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}
+		return inner.RoundTrip(req)
+	})
+
+	ctx, _ := getServiceInfoContext(t)
+	err := dataplane.Write(ctx, writeSasUri, bytes.NewReader(input), dataplane.WithWriteHttpClient(failingClient), dataplane.WithWriteDop(1), dataplane.WithWriteBlockSize(blockSize))
+	require.Error(t, err)
+
+	err = dataplane.Write(ctx, writeSasUri, bytes.NewReader(input), dataplane.WithWriteBlockSize(blockSize))
+	require.ErrorContains(t, err, "buffer cannot be overwritten")
+
+	err = dataplane.Write(ctx, writeSasUri, bytes.NewReader([]byte("something else")), dataplane.WithWriteBlockSize(blockSize), dataplane.WithWriteResume(true))
+	require.ErrorContains(t, err, "does not match the input")
+
+	err = dataplane.Write(ctx, writeSasUri, bytes.NewReader(input), dataplane.WithWriteBlockSize(blockSize), dataplane.WithWriteResume(true))
+	require.NoError(t, err)
+
+	output := &bytes.Buffer{}
+	require.NoError(t, dataplane.Read(ctx, writeSasUri, output))
+	require.Equal(t, input, output.Bytes())
+
+	err = dataplane.Write(ctx, writeSasUri, bytes.NewReader(input), dataplane.WithWriteBlockSize(blockSize), dataplane.WithWriteResume(true))
+	require.ErrorContains(t, err, "the buffer has already been completed")
+}
+
+func TestBufferReadRange(t *testing.T) {
+	t.Parallel()
+
+	bufferId := runTygerSucceeds(t, "buffer", "create")
+	writeSasUri := runTygerSucceeds(t, "buffer", "access", bufferId, "-w")
+	readSasUri := runTygerSucceeds(t, "buffer", "access", bufferId)
+
+	blockSize := 1024
+	inputBuffer := &bytes.Buffer{}
+	require.NoError(t, cmd.Gen(int64(blockSize*10+10), inputBuffer))
+	input := inputBuffer.Bytes()
+
+	ctx, _ := getServiceInfoContext(t)
+	require.NoError(t, dataplane.Write(ctx, writeSasUri, bytes.NewReader(input), dataplane.WithWriteBlockSize(blockSize)))
+
+	testCases := []struct {
+		offset int64
+		length int64
+	}{
+		{0, 10},
+		{0, int64(blockSize)},
+		{int64(blockSize) - 5, 10},
+		{int64(blockSize*3 + 7), int64(blockSize * 4)},
+		{int64(blockSize * 9), -1},
+		{int64(blockSize * 10), 1000},
+		{int64(len(input)), -1},
+		{100, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d_%d", tc.offset, tc.length), func(t *testing.T) {
+			expected := input[tc.offset:]
+			if tc.length >= 0 && tc.length < int64(len(expected)) {
+				expected = expected[:tc.length]
+			}
+
+			output := &bytes.Buffer{}
+			require.NoError(t, dataplane.ReadRange(ctx, readSasUri, output, tc.offset, tc.length))
+			require.Equal(t, expected, output.Bytes())
+		})
+	}
+
+	err := dataplane.ReadRange(ctx, readSasUri, io.Discard, int64(len(input)+1), -1)
+	require.ErrorContains(t, err, "past the end of the buffer")
+
+	outputFilePath := filepath.Join(t.TempDir(), "output")
+	runTygerSucceeds(t, "buffer", "read", bufferId, "--offset", "2000", "--length", "30", "-o", outputFilePath)
+	output, err := os.ReadFile(outputFilePath)
+	require.NoError(t, err)
+	require.Equal(t, input[2000:2030], output)
+}
+
+func TestBufferEncryption(t *testing.T) {
+	t.Parallel()
+
+	keyFilePath := filepath.Join(t.TempDir(), "buffer.key")
+	require.NoError(t, os.WriteFile(keyFilePath, []byte("aMFHiCkBc0pU0v/XhAdsRVR3zJJbxSAGHRljQ19x0n8="), 0600))
+
+	bufferId := runTygerSucceeds(t, "buffer", "create")
+
+	inputFilePath := filepath.Join(t.TempDir(), "input")
+	runTygerSucceeds(t, "buffer", "gen", "10KB", "-o", inputFilePath)
+	runTygerSucceeds(t, "buffer", "write", bufferId, "-i", inputFilePath, "--block-size", "1KB", "--encryption-key", keyFilePath)
+
+	_, stderr, err := runTyger("buffer", "read", bufferId)
+	require.Error(t, err)
+	require.Contains(t, stderr, "the buffer is encrypted and an encryption key must be provided")
+
+	outputFilePath := filepath.Join(t.TempDir(), "output")
+	runTygerSucceeds(t, "buffer", "read", bufferId, "--encryption-key", keyFilePath, "-o", outputFilePath)
+
+	input, err := os.ReadFile(inputFilePath)
+	require.NoError(t, err)
+	output, err := os.ReadFile(outputFilePath)
+	require.NoError(t, err)
+	require.Equal(t, input, output)
+}
+
+func TestBufferCompression(t *testing.T) {
+	t.Parallel()
+
+	for _, compression := range dataplane.SupportedCompressions {
+		compression := compression
+		t.Run(compression, func(t *testing.T) {
+			t.Parallel()
+
+			bufferId := runTygerSucceeds(t, "buffer", "create")
+
+			inputFilePath := filepath.Join(t.TempDir(), "input")
+			runTygerSucceeds(t, "buffer", "gen", "100KB", "-o", inputFilePath)
+			runTygerSucceeds(t, "buffer", "write", bufferId, "-i", inputFilePath, "--block-size", "16KB", "--compression", compression)
+
+			outputFilePath := filepath.Join(t.TempDir(), "output")
+			runTygerSucceeds(t, "buffer", "read", bufferId, "-o", outputFilePath)
+
+			input, err := os.ReadFile(inputFilePath)
+			require.NoError(t, err)
+			output, err := os.ReadFile(outputFilePath)
+			require.NoError(t, err)
+			require.Equal(t, input, output)
+		})
+	}
+}
+
+func TestBufferVerify(t *testing.T) {
+	t.Parallel()
+
+	bufferId := runTygerSucceeds(t, "buffer", "create")
+	writeSasUri := runTygerSucceeds(t, "buffer", "access", bufferId, "-w")
+
+	ctx, _ := getServiceInfoContext(t)
+
+	result, err := dataplane.Verify(ctx, writeSasUri)
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.Equal(t, "the buffer has not been written to", result.Problem)
+
+	inputFilePath := filepath.Join(t.TempDir(), "input")
+	runTygerSucceeds(t, "buffer", "gen", "10245", "-o", inputFilePath)
+	runTygerSucceeds(t, "buffer", "write", bufferId, "-i", inputFilePath, "--block-size", "1KB")
+
+	result = &dataplane.VerifyResult{}
+	require.NoError(t, json.Unmarshal([]byte(runTygerSucceeds(t, "buffer", "verify", bufferId)), result))
+	require.True(t, result.Valid)
+	require.Equal(t, dataplane.BufferStatusComplete, result.Status)
+	require.Equal(t, int64(11), result.BlobCount)
+	require.Equal(t, int64(10245), result.TotalBytes)
+	require.Nil(t, result.FirstInvalidBlob)
+}
+
+func TestBufferVerifyFailedBuffer(t *testing.T) {
+	t.Parallel()
+
+	bufferId := runTygerSucceeds(t, "buffer", "create")
+	writeSasUri := runTygerSucceeds(t, "buffer", "access", bufferId, "-w")
+
+	failingClient := newInterceptingHttpClient(func(req *http.Request, inner http.RoundTripper) (*http.Response, error) {
+		if req.Method == http.MethodPut && strings.HasSuffix(req.URL.Path, "/002") {
+			return &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}
+		return inner.RoundTrip(req)
+	})
+
+	ctx, _ := getServiceInfoContext(t)
+	err := dataplane.Write(ctx, writeSasUri, bytes.NewReader(make([]byte, 5000)), dataplane.WithWriteHttpClient(failingClient), dataplane.WithWriteDop(1), dataplane.WithWriteBlockSize(1024))
+	require.Error(t, err)
+
+	stdout, _, err := runTyger("buffer", "verify", bufferId)
+	require.Error(t, err)
+
+	result := &dataplane.VerifyResult{}
+	require.NoError(t, json.Unmarshal([]byte(stdout), result))
+	require.False(t, result.Valid)
+	require.Equal(t, dataplane.BufferStatusFailed, result.Status)
+	require.NotNil(t, result.FirstInvalidBlob)
+	require.Equal(t, int64(2), *result.FirstInvalidBlob)
+}
+
+func TestBufferCopy(t *testing.T) {
+	t.Parallel()
+
+	sourceBufferId := runTygerSucceeds(t, "buffer", "create")
+	destinationBufferId := runTygerSucceeds(t, "buffer", "create")
+
+	inputFilePath := filepath.Join(t.TempDir(), "input")
+	runTygerSucceeds(t, "buffer", "gen", "10245", "-o", inputFilePath)
+	runTygerSucceeds(t, "buffer", "write", sourceBufferId, "-i", inputFilePath, "--block-size", "1KB", "--compression", "zstd")
+
+	// The destination can also be given as a SAS URI, as it would be for another environment.
+	destinationSasUri := runTygerSucceeds(t, "buffer", "access", destinationBufferId, "-w")
+	runTygerSucceeds(t, "buffer", "copy", sourceBufferId, destinationSasUri)
+
+	result := &dataplane.VerifyResult{}
+	require.NoError(t, json.Unmarshal([]byte(runTygerSucceeds(t, "buffer", "verify", destinationBufferId)), result))
+	require.True(t, result.Valid)
+	require.Equal(t, int64(11), result.BlobCount)
+
+	outputFilePath := filepath.Join(t.TempDir(), "output")
+	runTygerSucceeds(t, "buffer", "read", destinationBufferId, "-o", outputFilePath)
+
+	input, err := os.ReadFile(inputFilePath)
+	require.NoError(t, err)
+	output, err := os.ReadFile(outputFilePath)
+	require.NoError(t, err)
+	require.Equal(t, input, output)
+
+	_, stderr, err := runTyger("buffer", "copy", sourceBufferId, destinationBufferId)
+	require.Error(t, err)
+	require.Contains(t, stderr, "buffer cannot be overwritten")
+}
+
+func TestBufferStatusAndSize(t *testing.T) {
+	t.Parallel()
+
+	tag := fmt.Sprintf("status-test-%d", time.Now().UnixNano())
+	bufferId := runTygerSucceeds(t, "buffer", "create", "--tag", "testName="+tag)
+
+	buffer := model.Buffer{}
+	require.NoError(t, json.Unmarshal([]byte(runTygerSucceeds(t, "buffer", "show", bufferId)), &buffer))
+	require.Equal(t, "Pending", buffer.Status)
+	require.Nil(t, buffer.ByteCount)
+
+	inputFilePath := filepath.Join(t.TempDir(), "input")
+	runTygerSucceeds(t, "buffer", "gen", "10245", "-o", inputFilePath)
+	runTygerSucceeds(t, "buffer", "write", bufferId, "-i", inputFilePath, "--block-size", "1KB")
+
+	buffer = model.Buffer{}
+	require.NoError(t, json.Unmarshal([]byte(runTygerSucceeds(t, "buffer", "show", bufferId)), &buffer))
+	require.Equal(t, "Complete", buffer.Status)
+	require.NotNil(t, buffer.ByteCount)
+	require.Equal(t, int64(10245), *buffer.ByteCount)
+	require.NotNil(t, buffer.BlobCount)
+	require.Equal(t, int64(11), *buffer.BlobCount)
+
+	list := func(args ...string) []model.Buffer {
+		buffers := []model.Buffer{}
+		args = append([]string{"buffer", "list", "--tag", "testName=" + tag}, args...)
+		require.NoError(t, json.Unmarshal([]byte(runTygerSucceeds(t, args...)), &buffers))
+		return buffers
+	}
+
+	require.Len(t, list("--status", "complete"), 1)
+	require.Len(t, list("--status", "pending"), 0)
+	require.Len(t, list("--min-size", "10K"), 1)
+	require.Len(t, list("--max-size", "10K"), 0)
+
+	_, _, err := runTyger("buffer", "list", "--status", "unknown")
+	require.Error(t, err)
+}
+
 func newInterceptingHttpClient(roundtrip func(req *http.Request, inner http.RoundTripper) (*http.Response, error)) *retryablehttp.Client {
 	client := retryablehttp.NewClient()
 	client.Logger = nil
@@ -16,15 +16,21 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/alecthomas/units"
+	"github.com/erikgeiser/promptkit"
+	"github.com/erikgeiser/promptkit/confirmation"
 	"github.com/microsoft/tyger/cli/internal/controlplane"
 	"github.com/microsoft/tyger/cli/internal/controlplane/model"
 	"github.com/microsoft/tyger/cli/internal/dataplane"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 func NewBufferCommand() *cobra.Command {
@@ -43,25 +49,39 @@ func NewBufferCommand() *cobra.Command {
 	cmd.AddCommand(newBufferCreateCommand())
 	cmd.AddCommand(newBufferAccessCommand())
 	cmd.AddCommand(NewBufferReadCommand(os.OpenFile))
-	cmd.AddCommand(NewBufferWriteCommand(os.OpenFile))
+	cmd.AddCommand(NewBufferWriteCommand(os.OpenFile, true))
+	cmd.AddCommand(newBufferVerifyCommand())
+	cmd.AddCommand(newBufferCopyCommand())
 	cmd.AddCommand(newGenerateCommand())
 	cmd.AddCommand(newBufferShowCommand())
 	cmd.AddCommand(newBufferSetCommand())
 	cmd.AddCommand(newBufferListCommand())
+	cmd.AddCommand(newBufferDeleteCommand())
 
 	return cmd
 }
 
 func newBufferCreateCommand() *cobra.Command {
 	full := false
+	ttl := ""
 	tagEntries := make(map[string]string)
 	cmd := &cobra.Command{
-		Use:                   "create [--tag key=value ...]",
+		Use:                   "create [--tag key=value ...] [--ttl DURATION]",
 		Short:                 "Create a buffer",
 		Long:                  `Create a buffer. Writes the buffer ID to stdout on success.`,
 		DisableFlagsInUseLine: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			newBuffer := model.Buffer{Tags: tagEntries}
+			if ttl != "" {
+				duration, err := parseDuration(ttl)
+				if err != nil {
+					return err
+				}
+
+				seconds := int(duration.Seconds())
+				newBuffer.TtlSeconds = &seconds
+			}
+
 			buffer := model.Buffer{}
 			_, err := controlplane.InvokeRequest(cmd.Context(), http.MethodPost, "v1/buffers", newBuffer, &buffer)
 			if err != nil {
@@ -83,6 +103,7 @@ func newBufferCreateCommand() *cobra.Command {
 	}
 	cmd.Flags().StringToStringVar(&tagEntries, "tag", nil, "add a key-value tag to the buffer. Can be specified multiple times.")
 	cmd.Flags().BoolVar(&full, "full-resource", false, "return the full buffer resource and not just the buffer ID")
+	cmd.Flags().StringVar(&ttl, "ttl", "", "the time after which the buffer expires and is deleted, e.g. 12h or 30d. If not specified, the buffer does not expire.")
 
 	return cmd
 }
@@ -132,30 +153,48 @@ func newBufferSetCommand() *cobra.Command {
 	return cmd
 }
 
+// bufferTableColumns are the columns of the table output format for buffers.
+var bufferTableColumns = []tableColumn[model.Buffer]{
+	{"ID", func(b model.Buffer) string { return b.Id }},
+	{"STATUS", func(b model.Buffer) string { return b.Status }},
+	{"SIZE", func(b model.Buffer) string {
+		if b.ByteCount == nil {
+			return ""
+		}
+		return units.Base2Bytes(*b.ByteCount).String()
+	}},
+	{"CREATED", func(b model.Buffer) string { return formatTableTime(&b.CreatedAt) }},
+	{"EXPIRES", func(b model.Buffer) string { return formatTableTime(b.ExpiresAt) }},
+	{"TAGS", func(b model.Buffer) string { return formatTableTags(b.Tags) }},
+}
+
 func newBufferShowCommand() *cobra.Command {
+	outputFormat := ""
+
 	cmd := &cobra.Command{
-		Use:                   "show BUFFER_ID",
+		Use:                   "show BUFFER_ID [--output FORMAT]",
 		Short:                 "Show the details of a buffer",
 		Long:                  `Show the details of a buffer`,
 		DisableFlagsInUseLine: true,
 		Args:                  exactlyOneArg("buffer ID"),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			buffer := model.Buffer{}
-			_, err := controlplane.InvokeRequest(cmd.Context(), http.MethodGet, fmt.Sprintf("v1/buffers/%s", args[0]), nil, &buffer)
+			printer, err := newOutputPrinter(outputFormat, bufferTableColumns)
 			if err != nil {
 				return err
 			}
 
-			formattedBuffer, err := json.MarshalIndent(buffer, "", "  ")
+			buffer := model.Buffer{}
+			_, err = controlplane.InvokeRequest(cmd.Context(), http.MethodGet, fmt.Sprintf("v1/buffers/%s", args[0]), nil, &buffer)
 			if err != nil {
 				return err
 			}
 
-			fmt.Println(string(formattedBuffer))
-			return nil
+			return printer.printItem(buffer)
 		},
 	}
 
+	addOutputFlag(cmd, &outputFormat)
+
 	return cmd
 }
 
@@ -197,6 +236,9 @@ func getBufferAccessUri(ctx context.Context, bufferId string, writable bool) (st
 func NewBufferReadCommand(openFileFunc func(name string, flag int, perm fs.FileMode) (*os.File, error)) *cobra.Command {
 	outputFilePath := ""
 	dop := dataplane.DefaultReadDop
+	var offset int64 = 0
+	var length int64 = -1
+	encryptionKeyFilePath := ""
 	cmd := &cobra.Command{
 		Use:                   "read { BUFFER_ID | BUFFER_SAS_URI | FILE_WITH_SAS_URI } [flags]",
 		Short:                 "Reads the contents of a buffer",
@@ -210,6 +252,14 @@ func NewBufferReadCommand(openFileFunc func(name string, flag int, perm fs.FileM
 				log.Fatal().Msg("the degree of parallelism (dop) must be at least 1")
 			}
 
+			if offset < 0 {
+				log.Fatal().Msg("the offset must not be negative")
+			}
+
+			if hasFlagChanged(cmd, "length") && length < 0 {
+				log.Fatal().Msg("the length must not be negative")
+			}
+
 			uri, err := dataplane.GetUriFromAccessString(args[0])
 			if err != nil {
 				if err == dataplane.ErrAccessStringNotUri {
@@ -246,7 +296,22 @@ func NewBufferReadCommand(openFileFunc func(name string, flag int, perm fs.FileM
 				log.Warn().Msg("Canceling...")
 			}()
 
-			if err := dataplane.Read(ctx, uri, outputFile, dataplane.WithReadDop(dop)); err != nil {
+			readOptions := []dataplane.ReadOption{dataplane.WithReadDop(dop)}
+			if encryptionKeyFilePath != "" {
+				keyProvider, err := dataplane.NewKeyProviderFromFile(encryptionKeyFilePath)
+				if err != nil {
+					log.Fatal().Err(err).Msg("Invalid encryption key")
+				}
+				readOptions = append(readOptions, dataplane.WithReadKeyProvider(keyProvider))
+			}
+
+			if hasFlagChanged(cmd, "offset") || hasFlagChanged(cmd, "length") {
+				err = dataplane.ReadRange(ctx, uri, outputFile, offset, length, readOptions...)
+			} else {
+				err = dataplane.Read(ctx, uri, outputFile, readOptions...)
+			}
+
+			if err != nil {
 				if errors.Is(err, ctx.Err()) {
 					err = ctx.Err()
 				}
@@ -257,13 +322,22 @@ func NewBufferReadCommand(openFileFunc func(name string, flag int, perm fs.FileM
 
 	cmd.Flags().StringVarP(&outputFilePath, "output", "o", outputFilePath, "The file write to. If not specified, data is written to standard out.")
 	cmd.Flags().IntVarP(&dop, "dop", "p", dop, "The degree of parallelism")
+	cmd.Flags().Int64Var(&offset, "offset", offset, "The byte offset in the buffer at which to start reading")
+	cmd.Flags().Int64Var(&length, "length", length, "The maximum number of bytes to read. If not specified, the buffer is read to the end.")
+	cmd.Flags().StringVar(&encryptionKeyFilePath, "encryption-key", encryptionKeyFilePath, "A file containing the 256-bit key to decrypt an encrypted buffer, either raw or base64-encoded.")
 	return cmd
 }
 
-func NewBufferWriteCommand(openFileFunc func(name string, flag int, perm fs.FileMode) (*os.File, error)) *cobra.Command {
+// NewBufferWriteCommand creates the buffer write command. When resumableOnFailure is true, a write that is
+// interrupted or that fails with a transient error leaves the buffer incomplete instead of marking it as failed,
+// so that it can be resumed with --resume.
+func NewBufferWriteCommand(openFileFunc func(name string, flag int, perm fs.FileMode) (*os.File, error), resumableOnFailure bool) *cobra.Command {
 	intputFilePath := ""
 	dop := dataplane.DefaultWriteDop
 	blockSizeString := ""
+	resume := false
+	encryptionKeyFilePath := ""
+	compression := dataplane.CompressionNone
 
 	cmd := &cobra.Command{
 		Use:                   "write { BUFFER_ID | BUFFER_SAS_URI | FILE_WITH_SAS_URI } [flags]",
@@ -278,6 +352,10 @@ func NewBufferWriteCommand(openFileFunc func(name string, flag int, perm fs.File
 				log.Fatal().Msg("the degree of parallelism (dop) must be at least 1")
 			}
 
+			if !slices.Contains(dataplane.SupportedCompressions, compression) {
+				log.Fatal().Msgf("invalid compression '%s'. Supported values are %v", compression, dataplane.SupportedCompressions)
+			}
+
 			uri, err := dataplane.GetUriFromAccessString(args[0])
 			if err != nil {
 				if err == dataplane.ErrAccessStringNotUri {
@@ -315,7 +393,11 @@ func NewBufferWriteCommand(openFileFunc func(name string, flag int, perm fs.File
 				log.Warn().Msg("Canceling...")
 			}()
 
-			writeOptions := []dataplane.WriteOption{dataplane.WithWriteDop(dop)}
+			writeOptions := []dataplane.WriteOption{dataplane.WithWriteDop(dop), dataplane.WithWriteResume(resume), dataplane.WithWriteResumableOnFailure(resumableOnFailure)}
+			if hasFlagChanged(cmd, "compression") {
+				// When resuming, the compression is otherwise taken from the original write
+				writeOptions = append(writeOptions, dataplane.WithWriteCompression(compression))
+			}
 			if blockSizeString != "" {
 				if blockSizeString != "" && blockSizeString[len(blockSizeString)-1] != 'B' {
 					blockSizeString += "B"
@@ -328,6 +410,14 @@ func NewBufferWriteCommand(openFileFunc func(name string, flag int, perm fs.File
 				writeOptions = append(writeOptions, dataplane.WithWriteBlockSize(int(parsedBlockSize)))
 			}
 
+			if encryptionKeyFilePath != "" {
+				keyProvider, err := dataplane.NewKeyProviderFromFile(encryptionKeyFilePath)
+				if err != nil {
+					log.Fatal().Err(err).Msg("Invalid encryption key")
+				}
+				writeOptions = append(writeOptions, dataplane.WithWriteKeyProvider(keyProvider))
+			}
+
 			err = dataplane.Write(ctx, uri, inputReader, writeOptions...)
 			if err != nil {
 				if errors.Is(err, ctx.Err()) {
@@ -341,9 +431,125 @@ func NewBufferWriteCommand(openFileFunc func(name string, flag int, perm fs.File
 	cmd.Flags().StringVarP(&intputFilePath, "input", "i", intputFilePath, "The file to read from. If not specified, data is read from standard in.")
 	cmd.Flags().IntVarP(&dop, "dop", "p", dop, "The degree of parallelism")
 	cmd.Flags().StringVarP(&blockSizeString, "block-size", "b", blockSizeString, "Split the stream into blocks of this size.")
+	cmd.Flags().BoolVar(&resume, "resume", resume, "Resume an interrupted write. The input and block size must be the same as in the original write.")
+	cmd.Flags().StringVar(&encryptionKeyFilePath, "encryption-key", encryptionKeyFilePath, "A file containing a 256-bit key, either raw or base64-encoded, used to encrypt the buffer's contents.")
+	cmd.Flags().StringVar(&compression, "compression", compression, fmt.Sprintf("The compression to apply to each block. One of %v.", dataplane.SupportedCompressions))
 	return cmd
 }
 
+func newBufferVerifyCommand() *cobra.Command {
+	dop := dataplane.DefaultReadDop
+	cmd := &cobra.Command{
+		Use:   "verify { BUFFER_ID | BUFFER_SAS_URI | FILE_WITH_SAS_URI } [flags]",
+		Short: "Verifies the integrity of a buffer",
+		Long: `Verifies the integrity of a buffer without writing its contents anywhere.
+
+Every blob is downloaded and its MD5 hash and the cumulative hash chain are checked. The buffer must also
+have been marked as complete. A summary is printed and the command fails if the buffer is not valid.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dop < 1 {
+				return errors.New("the degree of parallelism (dop) must be at least 1")
+			}
+
+			uri, err := dataplane.GetUriFromAccessString(args[0])
+			if err != nil {
+				if err != dataplane.ErrAccessStringNotUri {
+					return fmt.Errorf("invalid buffer access string: %w", err)
+				}
+
+				uri, err = getBufferAccessUri(cmd.Context(), args[0], false)
+				if err != nil {
+					return fmt.Errorf("unable to get read access to buffer: %w", err)
+				}
+			}
+
+			result, err := dataplane.Verify(cmd.Context(), uri, dataplane.WithReadDop(dop))
+			if err != nil {
+				return err
+			}
+
+			formattedResult, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(formattedResult))
+
+			if !result.Valid {
+				log.Fatal().Msgf("The buffer is not valid: %s", result.Problem)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&dop, "dop", "p", dop, "The degree of parallelism")
+	return cmd
+}
+
+func newBufferCopyCommand() *cobra.Command {
+	dop := dataplane.DefaultCopyDop
+	cmd := &cobra.Command{
+		Use:   "copy { SOURCE_BUFFER_ID | SOURCE_SAS_URI | FILE_WITH_SAS_URI } { DESTINATION_BUFFER_ID | DESTINATION_SAS_URI | FILE_WITH_SAS_URI } [flags]",
+		Short: "Copies the contents of a buffer to another buffer",
+		Long: `Copies the contents of a buffer to another buffer, which must not have been written to.
+
+Blobs are copied in parallel directly between the buffers, and the hash chain of the source buffer is
+verified along the way. Compressed and encrypted buffers are copied as they are, so no encryption key is needed.
+The source and destination can be in different Tyger environments if SAS URIs are given.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dop < 1 {
+				return errors.New("the degree of parallelism (dop) must be at least 1")
+			}
+
+			sourceUri, err := getUriFromAccessStringOrBufferId(cmd.Context(), args[0], false)
+			if err != nil {
+				return fmt.Errorf("unable to get read access to the source buffer: %w", err)
+			}
+
+			destinationUri, err := getUriFromAccessStringOrBufferId(cmd.Context(), args[1], true)
+			if err != nil {
+				return fmt.Errorf("unable to get write access to the destination buffer: %w", err)
+			}
+
+			ctx, stopFunc := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stopFunc()
+
+			err = dataplane.Copy(ctx, sourceUri, destinationUri, dataplane.WithCopyDop(dop))
+			if err != nil {
+				if errors.Is(err, ctx.Err()) {
+					err = ctx.Err()
+				}
+				log.Fatal().Err(err).Msg("Failed to copy buffer")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&dop, "dop", "p", dop, "The degree of parallelism")
+	return cmd
+}
+
+// getUriFromAccessStringOrBufferId returns the URI given by an access string, or if the access string
+// is not a URI, treats it as a buffer ID and gets an access URI for it.
+func getUriFromAccessStringOrBufferId(ctx context.Context, accessString string, writable bool) (string, error) {
+	uri, err := dataplane.GetUriFromAccessString(accessString)
+	if err != nil {
+		if err != dataplane.ErrAccessStringNotUri {
+			return "", fmt.Errorf("invalid buffer access string: %w", err)
+		}
+
+		return getBufferAccessUri(ctx, accessString, writable)
+	}
+
+	return uri, nil
+}
+
 func newGenerateCommand() *cobra.Command {
 	outputFilePath := ""
 	cmd := &cobra.Command{
@@ -414,13 +620,24 @@ func Gen(byteCount int64, outputWriter io.Writer) error {
 func newBufferListCommand() *cobra.Command {
 	limit := 0
 	tagEntries := make(map[string]string)
+	status := ""
+	minSizeString := ""
+	maxSizeString := ""
+	outputFormat := ""
 
 	cmd := &cobra.Command{
-		Use:                   "list [--tag key=value ...] [--limit COUNT]",
-		Short:                 "List buffers",
-		Long:                  `List buffers. Buffers are sorted by descending created time.`,
+		Use:   "list [--tag key=value ...] [--status pending|complete|failed] [--min-size SIZE] [--max-size SIZE] [--limit COUNT] [--output FORMAT]",
+		Short: "List buffers",
+		Long: `List buffers. Buffers are sorted by descending created time.
+
+Buffers can be filtered by the status of their contents and, once they are complete, by their size in bytes.`,
 		DisableFlagsInUseLine: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(outputFormat, bufferTableColumns)
+			if err != nil {
+				return err
+			}
+
 			listOptions := url.Values{}
 			if limit > 0 {
 				listOptions.Add("limit", strconv.Itoa(limit))
@@ -432,13 +649,193 @@ func newBufferListCommand() *cobra.Command {
 				listOptions.Add(fmt.Sprintf("tag.%s", name), value)
 			}
 
+			if status != "" {
+				listOptions.Add("status", status)
+			}
+
+			if minSizeString != "" {
+				minSize, err := parseByteSize(minSizeString)
+				if err != nil {
+					return fmt.Errorf("invalid --min-size: %w", err)
+				}
+				listOptions.Add("minByteCount", strconv.FormatInt(minSize, 10))
+			}
+
+			if maxSizeString != "" {
+				maxSize, err := parseByteSize(maxSizeString)
+				if err != nil {
+					return fmt.Errorf("invalid --max-size: %w", err)
+				}
+				listOptions.Add("maxByteCount", strconv.FormatInt(maxSize, 10))
+			}
+
 			relativeUri := fmt.Sprintf("v1/buffers?%s", listOptions.Encode())
-			return controlplane.InvokePageRequests[model.Buffer](cmd.Context(), relativeUri, limit, !cmd.Flags().Lookup("limit").Changed)
+			return printer.printPages(cmd.Context(), relativeUri, limit, !cmd.Flags().Lookup("limit").Changed)
 		},
 	}
 
 	cmd.Flags().StringToStringVar(&tagEntries, "tag", nil, "add a key-value tag to the buffer. Can be specified multiple times.")
+	cmd.Flags().StringVar(&status, "status", "", "only list buffers with this status. One of pending, complete, or failed.")
+	cmd.Flags().StringVar(&minSizeString, "min-size", "", "only list complete buffers with at least this many bytes, e.g. 10M")
+	cmd.Flags().StringVar(&maxSizeString, "max-size", "", "only list complete buffers with at most this many bytes, e.g. 1G")
 	cmd.Flags().IntVarP(&limit, "limit", "l", 1000, "The maximum number of buffers to list. Default 1000")
+	addOutputFlag(cmd, &outputFormat)
+
+	return cmd
+}
+
+// parseByteSize parses a size such as 512, 10K, or 4MB.
+func parseByteSize(sizeString string) (int64, error) {
+	if sizeString != "" && sizeString[len(sizeString)-1] != 'B' {
+		sizeString += "B"
+	}
+
+	parsedBytes, err := units.ParseBase2Bytes(sizeString)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(parsedBytes), nil
+}
+
+func newBufferDeleteCommand() *cobra.Command {
+	olderThan := ""
+	tagEntries := make(map[string]string)
+	all := false
+	dryRun := false
+	yes := false
+
+	cmd := &cobra.Command{
+		Use:   "delete { BUFFER_ID ... | { --tag key=value ... | --all } [--older-than DURATION] [--dry-run] [--yes] }",
+		Short: "Delete buffers",
+		Long: `Delete buffers, either by ID or all buffers that match the given tags and age. Writes the IDs of the deleted buffers to stdout.
+
+Deleting buffers by tag requires at least one --tag, and deleting buffers regardless of their tags requires --all.
+Before deleting buffers by tag or with --all, you are asked to confirm unless --yes is given. Use --dry-run to only
+write the IDs of the buffers that would be deleted.
+
+Deleted buffers can no longer be read or written to, but they are still returned by 'tyger buffer show'
+with a deletedAt timestamp until they are permanently removed after a grace period.`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hasFilter := len(tagEntries) > 0 || olderThan != "" || all
+			if len(args) > 0 && (hasFilter || dryRun) {
+				return errors.New("buffer IDs cannot be combined with --tag, --all, --older-than, or --dry-run")
+			}
+			if len(args) == 0 && len(tagEntries) == 0 && !all {
+				return errors.New("at least one buffer ID or --tag must be specified, or --all to delete buffers regardless of their tags")
+			}
+			if all && len(tagEntries) > 0 {
+				return errors.New("--all cannot be combined with --tag")
+			}
+
+			ids := args
+			if hasFilter {
+				var createdBefore *time.Time
+				if olderThan != "" {
+					duration, err := parseDuration(olderThan)
+					if err != nil {
+						return err
+					}
+					if duration <= 0 {
+						return errors.New("--older-than must be a positive duration")
+					}
+
+					t := time.Now().Add(-duration)
+					createdBefore = &t
+				}
+
+				var err error
+				ids, err = findBuffersToDelete(cmd.Context(), tagEntries, createdBefore)
+				if err != nil {
+					return err
+				}
+
+				if dryRun {
+					for _, id := range ids {
+						fmt.Println(id)
+					}
+					return nil
+				}
+
+				if len(ids) > 0 && !yes {
+					confirmed, err := confirmBufferDeletion(len(ids))
+					if err != nil {
+						return err
+					}
+					if !confirmed {
+						return errors.New("no buffers were deleted")
+					}
+				}
+			}
+
+			for _, id := range ids {
+				_, err := controlplane.InvokeRequest(cmd.Context(), http.MethodDelete, fmt.Sprintf("v1/buffers/%s", id), nil, nil)
+				if err != nil {
+					return err
+				}
+
+				fmt.Println(id)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringToStringVar(&tagEntries, "tag", nil, "only delete buffers with this key-value tag. Can be specified multiple times.")
+	cmd.Flags().BoolVar(&all, "all", false, "delete buffers regardless of their tags")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "only delete buffers created longer ago than this duration, e.g. 12h or 30d")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "write the IDs of the buffers that would be deleted without deleting them")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "delete the buffers without asking for confirmation")
 
 	return cmd
 }
+
+// confirmBufferDeletion asks the user to confirm deleting the given number of buffers.
+// The prompt is written to stderr, since stdout receives the IDs of the deleted buffers.
+func confirmBufferDeletion(count int) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("%d buffers would be deleted. Use --yes to delete them without confirmation", count)
+	}
+
+	input := confirmation.New(fmt.Sprintf("%d buffers will be deleted. Continue?", count), confirmation.No)
+	input.WrapMode = promptkit.WordWrap
+	input.Output = os.Stderr
+	return input.RunPrompt()
+}
+
+// findBuffersToDelete returns the IDs of the buffers that have all of the given tags and,
+// if createdBefore is not nil, were created before the given time. Buffers that hold dataset
+// versions are skipped, since they cannot be deleted.
+func findBuffersToDelete(ctx context.Context, tags map[string]string, createdBefore *time.Time) ([]string, error) {
+	listOptions := url.Values{}
+	listOptions.Add("limit", "200")
+	for name, value := range tags {
+		listOptions.Add(fmt.Sprintf("tag.%s", name), value)
+	}
+	if createdBefore != nil {
+		listOptions.Add("createdBefore", createdBefore.UTC().Format(time.RFC3339Nano))
+	}
+
+	ids := []string{}
+	for uri := fmt.Sprintf("v1/buffers?%s", listOptions.Encode()); uri != ""; {
+		page := model.Page[model.Buffer]{}
+		if _, err := controlplane.InvokeRequest(ctx, http.MethodGet, uri, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, buffer := range page.Items {
+			if _, ok := buffer.Tags[datasetTagKey]; ok {
+				continue
+			}
+			// The server filters by creation time, but check again in case it is an older version that ignores the parameter.
+			if createdBefore == nil || buffer.CreatedAt.Before(*createdBefore) {
+				ids = append(ids, buffer.Id)
+			}
+		}
+
+		uri = strings.TrimLeft(page.NextLink, "/")
+	}
+
+	return ids, nil
+}
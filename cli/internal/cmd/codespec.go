@@ -4,6 +4,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,12 +12,17 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/microsoft/tyger/cli/internal/controlplane"
 	"github.com/microsoft/tyger/cli/internal/controlplane/model"
+	"github.com/microsoft/tyger/cli/internal/registry"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/yaml"
@@ -38,6 +44,9 @@ func NewCodespecCommand() *cobra.Command {
 	cmd.AddCommand(newCodespecCreateCommand())
 	cmd.AddCommand(newCodespecShowCommand())
 	cmd.AddCommand(codespecListCommand())
+	cmd.AddCommand(newCodespecHistoryCommand())
+	cmd.AddCommand(newCodespecDiffCommand())
+	cmd.AddCommand(newCodespecValidateCommand())
 
 	return cmd
 }
@@ -49,23 +58,29 @@ func newCodespecCreateCommand() *cobra.Command {
 	}
 	var flags struct {
 		specFile      string
+		fromImage     string
 		image         string
 		kind          string
 		inputBuffers  []string
 		outputBuffers []string
 		env           map[string]string
+		secretEnv     map[string]string
 		command       bool
 		requests      overcommittableResourceStrings
 		limits        overcommittableResourceStrings
 		gpu           string
 		maxReplicas   string
 		endpoints     map[string]int
+		mounts        map[string]string
 	}
 
 	var cmd = &cobra.Command{
-		Use:                   `create NAME [--file YAML_SPEC] [--image IMAGE] [--kind job|worker] [--max-replicas REPLICAS] [[--input BUFFER_NAME] ...] [[--output BUFFER_NAME] ...] [[--env \"KEY=VALUE\"] ...] [[ --endpoint SERVICE=PORT ]] [--gpu QUANTITY] [--cpu-request QUANTITY] [--memory-request QUANTITY] [--cpu-limit QUANTITY] [--memory-limit QUANTITY] [--command] -- [COMMAND] [args...]`,
-		Short:                 "Create or update a codespec",
-		Long:                  `Create or update a codespec. Outputs the version of the codespec that was created.`,
+		Use:   `create NAME [--file YAML_SPEC | --from-image IMAGE] [--image IMAGE] [--kind job|worker] [--max-replicas REPLICAS] [[--input BUFFER_NAME] ...] [[--output BUFFER_NAME] ...] [[--env \"KEY=VALUE\"] ...] [[--secret-env \"KEY=SECRET/SECRET_KEY\"] ...] [[ --endpoint SERVICE=PORT ]] [[--mount DATASET[:VERSION]=PATH] ...] [--gpu QUANTITY] [--cpu-request QUANTITY] [--memory-request QUANTITY] [--cpu-limit QUANTITY] [--memory-limit QUANTITY] [--command] -- [COMMAND] [args...]`,
+		Short: "Create or update a codespec",
+		Long: `Create or update a codespec. Outputs the version of the codespec that was created.
+
+With --from-image, the codespec is built from the ` + imageLabelPrefix + `* labels of the image's configuration, which is read
+from its registry, and the image is used as the codespec's image. Other flags override the values from the labels.`,
 		DisableFlagsInUseLine: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			newCodespec := model.Codespec{}
@@ -112,6 +127,17 @@ func newCodespecCreateCommand() *cobra.Command {
 				return errors.New("codespec names must contain only lower case letters (a-z), numbers (0-9), dashes (-), underscores (_), and dots (.)")
 			}
 
+			if flags.fromImage != "" {
+				labels, err := getImageLabels(cmd.Context(), flags.fromImage)
+				if err != nil {
+					return err
+				}
+				if err := applyCodespecImageLabels(&newCodespec, labels); err != nil {
+					return fmt.Errorf("the image '%s' has an invalid label: %w", flags.fromImage, err)
+				}
+				newCodespec.Image = flags.fromImage
+			}
+
 			if hasFlagChanged(cmd, "image") {
 				newCodespec.Image = flags.image
 			}
@@ -131,13 +157,33 @@ func newCodespecCreateCommand() *cobra.Command {
 			}
 
 			if hasFlagChanged(cmd, "env") {
-				newCodespec.Env = flags.env
+				newCodespec.Env = make(map[string]model.EnvValue, len(flags.env))
+				for k, v := range flags.env {
+					newCodespec.Env[k] = model.EnvValue{Value: v}
+				}
+			}
+
+			if hasFlagChanged(cmd, "secret-env") {
+				if newCodespec.Env == nil {
+					newCodespec.Env = make(map[string]model.EnvValue, len(flags.secretEnv))
+				}
+				for k, v := range flags.secretEnv {
+					newCodespec.Env[k] = model.EnvValue{SecretRef: v}
+				}
 			}
 
 			if hasFlagChanged(cmd, "endpoint") {
 				newCodespec.Endpoints = flags.endpoints
 			}
 
+			if hasFlagChanged(cmd, "mount") {
+				mounts, err := parseDatasetMounts(flags.mounts)
+				if err != nil {
+					return err
+				}
+				newCodespec.Mounts = mounts
+			}
+
 			if flags.maxReplicas != "" {
 				mr, err := strconv.Atoi(flags.maxReplicas)
 				if err != nil {
@@ -146,7 +192,8 @@ func newCodespecCreateCommand() *cobra.Command {
 				newCodespec.MaxReplicas = &mr
 			}
 
-			if newCodespec.Kind == "" {
+			// The kind from the image labels is overridden by --kind, like the other labels are by their flags.
+			if newCodespec.Kind == "" || (flags.fromImage != "" && hasFlagChanged(cmd, "kind")) {
 				newCodespec.Kind = strings.ToLower(flags.kind)
 			}
 
@@ -269,35 +316,200 @@ func newCodespecCreateCommand() *cobra.Command {
 
 	cmd.Flags().StringVar(&flags.image, "image", "", "The container image (required)")
 	cmd.Flags().StringVarP(&flags.specFile, "file", "f", "", "A YAML file with the run specification. All other flags override the values in the file.")
+	cmd.Flags().StringVar(&flags.fromImage, "from-image", "", "A container image whose "+imageLabelPrefix+"* labels describe the codespec. All other flags override the values from the labels.")
 	cmd.Flags().StringVarP(&flags.kind, "kind", "k", "job", "The codespec kind. Either 'job' (the default) or 'worker'.")
 	cmd.Flags().StringVarP(&flags.maxReplicas, "max-replicas", "r", "", "The maximum number of replicas this codespec supports.")
 	cmd.Flags().StringSliceVarP(&flags.inputBuffers, "input", "i", nil, "Input buffer parameter names")
 	cmd.Flags().StringSliceVarP(&flags.outputBuffers, "output", "o", nil, "Output buffer parameter names")
 	cmd.Flags().StringToStringVarP(&flags.env, "env", "e", nil, "Environment variables to set in the container in the form KEY=value")
+	cmd.Flags().StringToStringVar(&flags.secretEnv, "secret-env", nil, "Environment variables to set in the container from a secret in the form KEY=SECRET/SECRET_KEY. The value is read from the secret when the container starts.")
 	cmd.Flags().StringToIntVar(&flags.endpoints, "endpoint", nil, "TCP endpoints in the form NAME=PORT. Only valid for worker codespecs.")
+	cmd.Flags().StringToStringVar(&flags.mounts, "mount", nil, "Mount a dataset read-only in the container in the form DATASET[:VERSION]=PATH. Without a version, runs use the latest version of the dataset. Can be specified multiple times.")
 	cmd.Flags().BoolVar(&flags.command, "command", false, "If true and extra arguments are present, use them as the 'command' field in the container, rather than the 'args' field which is the default.")
 	cmd.Flags().StringVar(&flags.requests.cpu, "cpu-request", "", "CPU cores requested")
 	cmd.Flags().StringVar(&flags.requests.memory, "memory-request", "", "memory bytes requested")
 	cmd.Flags().StringVar(&flags.limits.cpu, "cpu-limit", "", "CPU cores limit")
 	cmd.Flags().StringVar(&flags.limits.memory, "memory-limit", "", "memory bytes limit")
 	cmd.Flags().StringVar(&flags.gpu, "gpu", "", "GPUs needed")
+	cmd.MarkFlagsMutuallyExclusive("file", "from-image")
 
 	return cmd
 }
 
+// imageLabelPrefix is the prefix of the image labels that codespecs are built from with --from-image.
+const imageLabelPrefix = "tyger."
+
+// codespecImageLabels maps each supported image label to a function that sets the corresponding codespec field.
+// List values are comma-separated.
+var codespecImageLabels = map[string]func(codespec *model.Codespec, value string) error{
+	"tyger.kind": func(codespec *model.Codespec, value string) error {
+		codespec.Kind = strings.ToLower(value)
+		return nil
+	},
+	"tyger.max-replicas": func(codespec *model.Codespec, value string) error {
+		mr, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got '%s'", value)
+		}
+		codespec.MaxReplicas = &mr
+		return nil
+	},
+	"tyger.buffers.inputs": func(codespec *model.Codespec, value string) error {
+		if codespec.Buffers == nil {
+			codespec.Buffers = &model.BufferParameters{}
+		}
+		codespec.Buffers.Inputs = splitImageLabelList(value)
+		return nil
+	},
+	"tyger.buffers.outputs": func(codespec *model.Codespec, value string) error {
+		if codespec.Buffers == nil {
+			codespec.Buffers = &model.BufferParameters{}
+		}
+		codespec.Buffers.Outputs = splitImageLabelList(value)
+		return nil
+	},
+	"tyger.endpoints": func(codespec *model.Codespec, value string) error {
+		codespec.Endpoints = make(map[string]int)
+		for _, endpoint := range splitImageLabelList(value) {
+			name, portString, _ := strings.Cut(endpoint, "=")
+			port, err := strconv.Atoi(portString)
+			if err != nil {
+				return fmt.Errorf("endpoints must be in the form NAME=PORT, got '%s'", endpoint)
+			}
+			codespec.Endpoints[name] = port
+		}
+		return nil
+	},
+	"tyger.resources.gpu": func(codespec *model.Codespec, value string) error {
+		return setImageLabelQuantity(codespec, value, func(r *model.CodespecResources) **resource.Quantity { return &r.Gpu })
+	},
+	"tyger.resources.requests.cpu": func(codespec *model.Codespec, value string) error {
+		return setImageLabelQuantity(codespec, value, func(r *model.CodespecResources) **resource.Quantity { return &imageLabelRequests(r).Cpu })
+	},
+	"tyger.resources.requests.memory": func(codespec *model.Codespec, value string) error {
+		return setImageLabelQuantity(codespec, value, func(r *model.CodespecResources) **resource.Quantity { return &imageLabelRequests(r).Memory })
+	},
+	"tyger.resources.limits.cpu": func(codespec *model.Codespec, value string) error {
+		return setImageLabelQuantity(codespec, value, func(r *model.CodespecResources) **resource.Quantity { return &imageLabelLimits(r).Cpu })
+	},
+	"tyger.resources.limits.memory": func(codespec *model.Codespec, value string) error {
+		return setImageLabelQuantity(codespec, value, func(r *model.CodespecResources) **resource.Quantity { return &imageLabelLimits(r).Memory })
+	},
+}
+
+// getImageLabels reads the labels of an image from its registry.
+func getImageLabels(ctx context.Context, image string) (map[string]string, error) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return nil, fmt.Errorf("the image reference '%s' is invalid: %w", image, err)
+	}
+
+	imageConfig, err := registry.GetImageConfig(ctx, ref)
+	if err != nil {
+		if errors.Is(err, registry.ErrImageNotFound) {
+			return nil, fmt.Errorf("the image '%s' was not found in the registry %s", image, ref.Domain)
+		}
+		return nil, fmt.Errorf("unable to read the configuration of the image '%s': %w", image, err)
+	}
+
+	return imageConfig.Labels, nil
+}
+
+// applyCodespecImageLabels sets the codespec fields described by the image labels
+// with the imageLabelPrefix prefix. Labels with the prefix that are not recognized are ignored
+// with a warning, so that a misspelled label is noticed but images with labels meant for
+// other versions of the CLI can still be used.
+func applyCodespecImageLabels(codespec *model.Codespec, labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		if strings.HasPrefix(key, imageLabelPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		log.Warn().Msgf("The image does not have any %s* labels", imageLabelPrefix)
+	}
+
+	for _, key := range keys {
+		apply, ok := codespecImageLabels[key]
+		if !ok {
+			log.Warn().Str("label", key).Msg("Ignoring unrecognized image label")
+			continue
+		}
+		if err := apply(codespec, strings.TrimSpace(labels[key])); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func splitImageLabelList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func setImageLabelQuantity(codespec *model.Codespec, value string, field func(*model.CodespecResources) **resource.Quantity) error {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return err
+	}
+	if codespec.Resources == nil {
+		codespec.Resources = &model.CodespecResources{}
+	}
+	*field(codespec.Resources) = &q
+	return nil
+}
+
+func imageLabelRequests(r *model.CodespecResources) *model.OvercommittableResources {
+	if r.Requests == nil {
+		r.Requests = &model.OvercommittableResources{}
+	}
+	return r.Requests
+}
+
+func imageLabelLimits(r *model.CodespecResources) *model.OvercommittableResources {
+	if r.Limits == nil {
+		r.Limits = &model.OvercommittableResources{}
+	}
+	return r.Limits
+}
+
+// codespecTableColumns are the columns of the table output format for codespecs.
+var codespecTableColumns = []tableColumn[model.Codespec]{
+	{"NAME", func(c model.Codespec) string { return c.Name }},
+	{"VERSION", func(c model.Codespec) string { return strconv.Itoa(c.Version) }},
+	{"KIND", func(c model.Codespec) string { return c.Kind }},
+	{"IMAGE", func(c model.Codespec) string { return c.Image }},
+	{"CREATED", func(c model.Codespec) string { return formatTableTime(&c.CreatedAt) }},
+}
+
 func newCodespecShowCommand() *cobra.Command {
 	var flags struct {
 		version int
+		output  string
 	}
 
 	var cmd = &cobra.Command{
-		Use:                   "show NAME [--version VERSION]",
+		Use:                   "show NAME [--version VERSION] [--output FORMAT]",
 		Aliases:               []string{"get"},
 		Short:                 "Show the details of a codespec",
 		Long:                  `Show the details of a codespec.`,
 		DisableFlagsInUseLine: true,
 		Args:                  exactlyOneArg("codespec name"),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(flags.output, codespecTableColumns)
+			if err != nil {
+				return err
+			}
+
 			name := args[0]
 
 			relativeUri := fmt.Sprintf("v1/codespecs/%s", name)
@@ -308,26 +520,42 @@ func newCodespecShowCommand() *cobra.Command {
 			}
 
 			codespec := model.Codespec{}
-			_, err := controlplane.InvokeRequest(cmd.Context(), http.MethodGet, relativeUri, nil, &codespec)
-			if err != nil {
-				return err
-			}
-
-			formattedRun, err := json.MarshalIndent(codespec, "  ", "  ")
+			_, err = controlplane.InvokeRequest(cmd.Context(), http.MethodGet, relativeUri, nil, &codespec)
 			if err != nil {
 				return err
 			}
 
-			fmt.Println(string(formattedRun))
-			return nil
+			return printer.printItem(codespec)
 		},
 	}
 
 	cmd.Flags().IntVar(&flags.version, "version", -1, "the version of the codespec to get")
+	addOutputFlag(cmd, &flags.output)
 
 	return cmd
 }
 
+// parseDatasetMounts parses --mount values of the form DATASET[:VERSION]=PATH.
+// The mounts are sorted by path so that the same flags always produce the same codespec.
+func parseDatasetMounts(mounts map[string]string) ([]model.DatasetMount, error) {
+	result := make([]model.DatasetMount, 0, len(mounts))
+	for datasetAndVersion, path := range mounts {
+		mount := model.DatasetMount{Dataset: datasetAndVersion, Path: path}
+		if dataset, versionString, ok := strings.Cut(datasetAndVersion, ":"); ok {
+			version, err := strconv.Atoi(versionString)
+			if err != nil {
+				return nil, fmt.Errorf("the version of the dataset '%s' must be an integer, got '%s'", dataset, versionString)
+			}
+			mount.Dataset = dataset
+			mount.Version = &version
+		}
+		result = append(result, mount)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result, nil
+}
+
 func getCodespecVersionFromResponse(resp *http.Response) (int, error) {
 	location := resp.Header.Get("Location")
 	return strconv.Atoi(location[strings.LastIndex(location, "/")+1:])
@@ -337,15 +565,21 @@ func codespecListCommand() *cobra.Command {
 	var flags struct {
 		limit  int
 		prefix string
+		output string
 	}
 
 	cmd := &cobra.Command{
-		Use:                   "list [--prefix STRING] [--limit COUNT]",
+		Use:                   "list [--prefix STRING] [--limit COUNT] [--output FORMAT]",
 		Short:                 "List codespecs",
 		Long:                  `List codespecs. Latest version of codespecs are sorted alphabetically.`,
 		DisableFlagsInUseLine: true,
 		Args:                  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(flags.output, codespecTableColumns)
+			if err != nil {
+				return err
+			}
+
 			queryOptions := url.Values{}
 			if flags.limit > 0 {
 				queryOptions.Add("limit", strconv.Itoa(flags.limit))
@@ -357,12 +591,232 @@ func codespecListCommand() *cobra.Command {
 			}
 
 			var relativeUri string = fmt.Sprintf("v1/codespecs?%s", queryOptions.Encode())
-			return controlplane.InvokePageRequests[model.Codespec](cmd.Context(), relativeUri, flags.limit, !cmd.Flags().Lookup("limit").Changed)
+			return printer.printPages(cmd.Context(), relativeUri, flags.limit, !cmd.Flags().Lookup("limit").Changed)
 		},
 	}
 
 	cmd.Flags().StringVarP(&flags.prefix, "prefix", "p", "", "Show only codespecs that start with this prefix")
 	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 1000, "The maximum number of codespecs to list. Default 1000")
+	addOutputFlag(cmd, &flags.output)
+
+	return cmd
+}
+
+// codespecChange is a difference in one field between two versions of a codespec.
+// Nested fields, like environment variables and resources, are compared one by one
+// and named with a dotted path, for example env.LOG_LEVEL or resources.requests.cpu.
+type codespecChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+var codespecChangeTableColumns = []tableColumn[codespecChange]{
+	{"FIELD", func(c codespecChange) string { return c.Field }},
+	{"FROM", func(c codespecChange) string { return formatCodespecFieldValue(c.From) }},
+	{"TO", func(c codespecChange) string { return formatCodespecFieldValue(c.To) }},
+}
+
+// codespecHistoryEntry is a version of a codespec and how it differs from the previous version.
+type codespecHistoryEntry struct {
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Image     string           `json:"image"`
+	Changes   []codespecChange `json:"changes"`
+}
+
+var codespecHistoryTableColumns = []tableColumn[codespecHistoryEntry]{
+	{"VERSION", func(e codespecHistoryEntry) string { return strconv.Itoa(e.Version) }},
+	{"CREATED", func(e codespecHistoryEntry) string { return formatTableTime(&e.CreatedAt) }},
+	{"IMAGE", func(e codespecHistoryEntry) string { return e.Image }},
+	{"CHANGES", func(e codespecHistoryEntry) string {
+		if e.Version == 1 {
+			return "(created)"
+		}
+		fields := make([]string, 0, len(e.Changes))
+		for _, c := range e.Changes {
+			fields = append(fields, c.Field)
+		}
+		return strings.Join(fields, ",")
+	}},
+}
+
+func newCodespecHistoryCommand() *cobra.Command {
+	var flags struct {
+		limit  int
+		output string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "history NAME [--limit COUNT] [--output FORMAT]",
+		Short: "List the versions of a codespec",
+		Long: `List the versions of a codespec, newest first, with the time each version was created
+and the fields that changed from the previous version.`,
+		DisableFlagsInUseLine: true,
+		Args:                  exactlyOneArg("codespec name"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(flags.output, codespecHistoryTableColumns)
+			if err != nil {
+				return err
+			}
+
+			if flags.limit <= 0 {
+				flags.limit = math.MaxInt - 1
+			}
+
+			// One more version than requested is needed to know what changed in the oldest one.
+			relativeUri := fmt.Sprintf("v1/codespecs/%s/versions", args[0])
+			versions, err := controlplane.GetPages[model.Codespec](cmd.Context(), relativeUri, flags.limit+1, false)
+			if err != nil {
+				return err
+			}
+
+			entries := make([]codespecHistoryEntry, 0, len(versions))
+			for i, version := range versions {
+				if i == flags.limit {
+					break
+				}
+
+				entry := codespecHistoryEntry{Version: version.Version, CreatedAt: version.CreatedAt, Image: version.Image, Changes: []codespecChange{}}
+				if i+1 < len(versions) {
+					entry.Changes, err = diffCodespecs(versions[i+1], version)
+					if err != nil {
+						return err
+					}
+				}
+				entries = append(entries, entry)
+			}
+
+			return printer.printList(entries)
+		},
+	}
+
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 1000, "The maximum number of versions to list. Default 1000")
+	addOutputFlag(cmd, &flags.output)
 
 	return cmd
 }
+
+func newCodespecDiffCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "diff NAME VERSION1 VERSION2 [--output FORMAT]",
+		Short: "Show the differences between two versions of a codespec",
+		Long: `Show the differences between two versions of a codespec, such as changes to the image, command, args,
+environment variables, resources, and buffer parameters. Each change has the field that changed and its value
+in VERSION1 (from) and in VERSION2 (to). A value is null when the field is not set in that version.`,
+		DisableFlagsInUseLine: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 3 {
+				return errors.New("a codespec name and two versions are required")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(output, codespecChangeTableColumns)
+			if err != nil {
+				return err
+			}
+
+			name := args[0]
+			codespecs := make([]model.Codespec, 2)
+			for i, versionString := range args[1:] {
+				version, err := strconv.Atoi(versionString)
+				if err != nil {
+					return fmt.Errorf("invalid codespec version '%s'", versionString)
+				}
+
+				_, err = controlplane.InvokeRequest(cmd.Context(), http.MethodGet, fmt.Sprintf("v1/codespecs/%s/versions/%d", name, version), nil, &codespecs[i])
+				if err != nil {
+					return err
+				}
+			}
+
+			changes, err := diffCodespecs(codespecs[0], codespecs[1])
+			if err != nil {
+				return err
+			}
+
+			return printer.printList(changes)
+		},
+	}
+
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+// diffCodespecs returns the fields that differ between two codespecs, sorted by field.
+// System fields like the version and creation time are ignored.
+func diffCodespecs(from, to model.Codespec) ([]codespecChange, error) {
+	fromFields, err := flattenCodespec(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := flattenCodespec(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]codespecChange, 0)
+	for field, fromValue := range fromFields {
+		if toValue, ok := toFields[field]; !ok || !reflect.DeepEqual(fromValue, toValue) {
+			changes = append(changes, codespecChange{Field: field, From: fromValue, To: toValue})
+		}
+	}
+	for field, toValue := range toFields {
+		if _, ok := fromFields[field]; !ok {
+			changes = append(changes, codespecChange{Field: field, To: toValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// flattenCodespec returns the fields of a codespec keyed by their dotted path.
+// Lists, like the command and args, are kept whole.
+func flattenCodespec(codespec model.Codespec) (map[string]any, error) {
+	codespec.CodespecMetadata = model.CodespecMetadata{}
+
+	bytes, err := json.Marshal(codespec)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]any
+	if err := json.Unmarshal(bytes, &object); err != nil {
+		return nil, err
+	}
+	delete(object, "name")
+	delete(object, "version")
+	delete(object, "createdAt")
+
+	fields := make(map[string]any)
+	var flatten func(prefix string, object map[string]any)
+	flatten = func(prefix string, object map[string]any) {
+		for k, v := range object {
+			if nested, ok := v.(map[string]any); ok {
+				flatten(prefix+k+".", nested)
+			} else {
+				fields[prefix+k] = v
+			}
+		}
+	}
+	flatten("", object)
+
+	return fields, nil
+}
+
+func formatCodespecFieldValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "<none>"
+	case string:
+		return v
+	default:
+		bytes, _ := json.Marshal(v)
+		return string(bytes)
+	}
+}
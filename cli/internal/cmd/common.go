@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mitchellh/go-ps"
 	"github.com/rs/zerolog/log"
@@ -25,6 +28,18 @@ func exactlyOneArg(argName string) func(cmd *cobra.Command, args []string) error
 	}
 }
 
+// parseDuration parses a duration in the format accepted by time.ParseDuration,
+// and additionally accepts a number of days such as "30d".
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil {
+			return time.Duration(n * float64(24*time.Hour)), nil
+		}
+	}
+
+	return time.ParseDuration(s)
+}
+
 func warnIfRunningInPowerShell() {
 	parentPid := os.Getppid()
 	parentProcess, err := ps.FindProcess(parentPid)
@@ -0,0 +1,374 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/alecthomas/units"
+	"github.com/microsoft/tyger/cli/internal/controlplane"
+	"github.com/microsoft/tyger/cli/internal/controlplane/model"
+	"github.com/microsoft/tyger/cli/internal/dataplane"
+	"github.com/spf13/cobra"
+)
+
+// datasetTagKey is the tag set on the buffers that hold dataset versions.
+const datasetTagKey = "tyger-dataset"
+
+func NewDatasetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "dataset",
+		Aliases: []string{"datasets"},
+		Short:   "Manage datasets",
+		Long: `Manage datasets. A dataset is a named, versioned directory of read-only files, such as calibration
+tables or model weights, that codespecs can mount into their containers.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			return errors.New("a command is required")
+		},
+	}
+
+	cmd.AddCommand(newDatasetUploadCommand())
+	cmd.AddCommand(newDatasetShowCommand())
+	cmd.AddCommand(newDatasetListCommand())
+
+	return cmd
+}
+
+func newDatasetUploadCommand() *cobra.Command {
+	dop := dataplane.DefaultWriteDop
+
+	cmd := &cobra.Command{
+		Use:   "upload NAME DIRECTORY [--dop DOP]",
+		Short: "Upload a new version of a dataset",
+		Long: `Upload the contents of a directory as a new version of a dataset. The dataset is created if it does not exist.
+Writes the new version number to stdout on success.
+
+The contents are stored in a buffer tagged with ` + datasetTagKey + `=NAME. This buffer cannot be deleted while
+the dataset version exists.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, dir := args[0], args[1]
+			if info, err := os.Stat(dir); err != nil {
+				return err
+			} else if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			buffer := model.Buffer{}
+			_, err := controlplane.InvokeRequest(ctx, http.MethodPost, "v1/buffers", model.Buffer{Tags: map[string]string{datasetTagKey: name}}, &buffer)
+			if err != nil {
+				return err
+			}
+
+			uri, err := getBufferAccessUri(ctx, buffer.Id, true)
+			if err != nil {
+				return err
+			}
+
+			pipeReader, pipeWriter := io.Pipe()
+			go func() {
+				pipeWriter.CloseWithError(writeDatasetArchive(dir, pipeWriter))
+			}()
+
+			if err := dataplane.Write(ctx, uri, pipeReader, dataplane.WithWriteDop(dop), dataplane.WithWriteCompression(dataplane.CompressionZstd)); err != nil {
+				pipeReader.CloseWithError(err)
+				return fmt.Errorf("failed to upload the dataset: %w", err)
+			}
+
+			dataset := model.Dataset{}
+			_, err = controlplane.InvokeRequest(ctx, http.MethodPost, fmt.Sprintf("v1/datasets/%s/versions", name), model.NewDatasetVersion{BufferId: buffer.Id}, &dataset)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(dataset.Version)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&dop, "dop", "p", dop, "The degree of parallelism")
+
+	return cmd
+}
+
+// datasetTableColumns are the columns of the table output format for datasets.
+var datasetTableColumns = []tableColumn[model.Dataset]{
+	{"NAME", func(d model.Dataset) string { return d.Name }},
+	{"VERSION", func(d model.Dataset) string { return strconv.Itoa(d.Version) }},
+	{"SIZE", func(d model.Dataset) string {
+		if d.ByteCount == nil {
+			return ""
+		}
+		return units.Base2Bytes(*d.ByteCount).String()
+	}},
+	{"BUFFER", func(d model.Dataset) string { return d.BufferId }},
+	{"CREATED", func(d model.Dataset) string { return formatTableTime(&d.CreatedAt) }},
+}
+
+func newDatasetShowCommand() *cobra.Command {
+	var flags struct {
+		version int
+		output  string
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "show NAME [--version VERSION] [--output FORMAT]",
+		Short:                 "Show the details of a dataset version",
+		Long:                  `Show the details of a dataset version. Without --version, the latest version is shown.`,
+		DisableFlagsInUseLine: true,
+		Args:                  exactlyOneArg("dataset name"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(flags.output, datasetTableColumns)
+			if err != nil {
+				return err
+			}
+
+			relativeUri := fmt.Sprintf("v1/datasets/%s", args[0])
+			if cmd.Flag("version").Changed {
+				relativeUri = fmt.Sprintf("%s/versions/%d", relativeUri, flags.version)
+			}
+
+			dataset := model.Dataset{}
+			_, err = controlplane.InvokeRequest(cmd.Context(), http.MethodGet, relativeUri, nil, &dataset)
+			if err != nil {
+				return err
+			}
+
+			return printer.printItem(dataset)
+		},
+	}
+
+	cmd.Flags().IntVar(&flags.version, "version", -1, "the version of the dataset to show")
+	addOutputFlag(cmd, &flags.output)
+
+	return cmd
+}
+
+func newDatasetListCommand() *cobra.Command {
+	var flags struct {
+		limit  int
+		output string
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "list [--limit COUNT] [--output FORMAT]",
+		Short:                 "List datasets",
+		Long:                  `List the latest version of each dataset, sorted by name.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(flags.output, datasetTableColumns)
+			if err != nil {
+				return err
+			}
+
+			queryOptions := url.Values{}
+			if flags.limit > 0 {
+				queryOptions.Add("limit", strconv.Itoa(flags.limit))
+			} else {
+				flags.limit = math.MaxInt
+			}
+
+			relativeUri := fmt.Sprintf("v1/datasets?%s", queryOptions.Encode())
+			return printer.printPages(cmd.Context(), relativeUri, flags.limit, !cmd.Flags().Lookup("limit").Changed)
+		},
+	}
+
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 1000, "The maximum number of datasets to list. Default 1000")
+	addOutputFlag(cmd, &flags.output)
+
+	return cmd
+}
+
+// NewDatasetFetchCommand is run by the init containers of runs that mount datasets. It extracts a dataset
+// version into the pod's dataset volume.
+func NewDatasetFetchCommand() *cobra.Command {
+	var flags struct {
+		cacheDir string
+		entry    string
+		podUid   string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "fetch-dataset { BUFFER_SAS_URI | FILE_WITH_SAS_URI } --cache-dir DIRECTORY --entry NAME/VERSION --pod-uid UID",
+		Short: "Download and extract a dataset version into a node's dataset cache",
+		Long: `Download a dataset version and extract it into the NAME/VERSION directory of a node's dataset cache,
+unless it is already in the cache, and record that the pod with the given UID uses it, so that it is not
+evicted while the pod is on the node.
+
+The dataset is extracted into a temporary directory that is moved into the cache once complete, so that a fetch
+that is interrupted, or that runs at the same time as another fetch of the same version, never leaves
+partial contents in the cache.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			uri, err := dataplane.GetUriFromAccessString(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid buffer access string: %w", err)
+			}
+
+			return fetchCachedDataset(flags.cacheDir, flags.entry, flags.podUid, func(dir string) error {
+				pipeReader, pipeWriter := io.Pipe()
+				readErr := make(chan error, 1)
+				go func() {
+					err := dataplane.Read(cmd.Context(), uri, pipeWriter)
+					pipeWriter.CloseWithError(err)
+					readErr <- err
+				}()
+
+				err := extractDatasetArchive(pipeReader, dir)
+				if err == nil {
+					// Consume the padding after the end of the archive so that the read can complete.
+					_, err = io.Copy(io.Discard, pipeReader)
+				}
+				if err != nil {
+					pipeReader.CloseWithError(err)
+					<-readErr
+					return fmt.Errorf("failed to extract the dataset: %w", err)
+				}
+				if err := <-readErr; err != nil {
+					return fmt.Errorf("failed to read the dataset: %w", err)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.cacheDir, "cache-dir", "", "The directory of the node's dataset cache (required)")
+	cmd.MarkFlagRequired("cache-dir")
+	cmd.Flags().StringVar(&flags.entry, "entry", "", "The NAME/VERSION of the dataset version in the cache (required)")
+	cmd.MarkFlagRequired("entry")
+	cmd.Flags().StringVar(&flags.podUid, "pod-uid", "", "The UID of the pod that mounts the dataset version (required)")
+	cmd.MarkFlagRequired("pod-uid")
+
+	return cmd
+}
+
+// writeDatasetArchive writes the contents of a directory to w as a tar archive with paths relative to the directory.
+func writeDatasetArchive(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if relativePath == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return fmt.Errorf("%s is not a regular file or directory. Datasets can only contain regular files and directories", path)
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relativePath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// extractDatasetArchive extracts a tar archive written by writeDatasetArchive into dir.
+// Entries are made readable by everyone, since containers may run as any user.
+func extractDatasetArchive(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !filepath.IsLocal(header.Name) {
+			return fmt.Errorf("invalid path in dataset archive: %s", header.Name)
+		}
+		path := filepath.Join(dir, header.Name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+
+			var mode fs.FileMode = 0644
+			if header.FileInfo().Mode()&0111 != 0 {
+				mode = 0755
+			}
+
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported entry in dataset archive: %s", header.Name)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// A node's dataset cache holds each dataset version that pods on the node have mounted in a NAME/VERSION
+// directory. The pods that use a version hold a lease on it, which is a file named after the pod's UID
+// in .leases/NAME/VERSION. Versions are only evicted when none of their leases belong to a pod that is
+// still on the node. The modification time of a version's directory is the time it was last leased.
+const (
+	datasetCacheLockFile   = ".lock"
+	datasetCacheLeasesDir  = ".leases"
+	datasetCacheTempDir    = ".tmp"
+	datasetCacheEvictedDir = ".evicted"
+)
+
+// fetchCachedDataset leases the cache entry of a dataset version for a pod and calls fetch to
+// populate the entry if it is not already in the cache.
+func fetchCachedDataset(cacheDir string, entry string, podUid string, fetch func(dir string) error) error {
+	if parts := strings.Split(entry, "/"); len(parts) != 2 || !filepath.IsLocal(entry) || strings.HasPrefix(entry, ".") {
+		return fmt.Errorf("invalid dataset cache entry '%s'. It must be NAME/VERSION", entry)
+	}
+	if podUid == "" || !filepath.IsLocal(podUid) || strings.ContainsRune(podUid, '/') {
+		return fmt.Errorf("invalid pod UID '%s'", podUid)
+	}
+
+	entryDir := filepath.Join(cacheDir, filepath.FromSlash(entry))
+
+	// The lease is taken before checking whether the entry exists so that the evictor,
+	// which holds the same lock, cannot remove the entry once it has been found.
+	found, err := func() (bool, error) {
+		unlock, err := lockDatasetCache(cacheDir)
+		if err != nil {
+			return false, err
+		}
+		defer unlock()
+
+		leaseDir := filepath.Join(cacheDir, datasetCacheLeasesDir, filepath.FromSlash(entry))
+		if err := os.MkdirAll(leaseDir, 0755); err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(filepath.Join(leaseDir, podUid), nil, 0644); err != nil {
+			return false, err
+		}
+
+		if _, err := os.Stat(entryDir); err != nil {
+			return false, nil
+		}
+
+		now := time.Now()
+		return true, os.Chtimes(entryDir, now, now)
+	}()
+	if err != nil {
+		return err
+	}
+	if found {
+		log.Info().Str("dataset", entry).Msg("Dataset is already in the node's cache")
+		return nil
+	}
+
+	// The temporary directory is named after the pod so that the evictor can remove it if the pod
+	// goes away before the fetch completes.
+	tempParent := filepath.Join(cacheDir, datasetCacheTempDir, podUid)
+	if err := os.MkdirAll(tempParent, 0755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempParent)
+
+	tempDir, err := os.MkdirTemp(tempParent, "")
+	if err != nil {
+		return err
+	}
+
+	if err := fetch(tempDir); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tempDir, 0755); err != nil {
+		return err
+	}
+
+	unlock, err := lockDatasetCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(entryDir); err == nil {
+		log.Info().Str("dataset", entry).Msg("Dataset was fetched by another pod")
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(entryDir), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tempDir, entryDir); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(entryDir, now, now); err != nil {
+		return err
+	}
+
+	log.Info().Str("dataset", entry).Msg("Dataset fetched into the node's cache")
+	return nil
+}
+
+type datasetCacheEntry struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// evictDatasetCache removes the leases and temporary directories of pods that are no longer on the node,
+// and then, if the cache is larger than maxBytes, removes the least recently used entries that are not leased
+// until it is not. Entries that are leased are never removed, so the cache can remain larger than maxBytes.
+func evictDatasetCache(cacheDir string, maxBytes int64, livePodUids map[string]bool) error {
+	// The entries are immutable, so they can be measured before taking the lock
+	entries, err := listDatasetCacheEntries(cacheDir)
+	if err != nil {
+		return err
+	}
+
+	evictedDir := filepath.Join(cacheDir, datasetCacheEvictedDir)
+	if err := evictDatasetCacheEntries(cacheDir, evictedDir, entries, maxBytes, livePodUids); err != nil {
+		return err
+	}
+
+	// Evicted entries are moved out of the cache while the lock is held and deleted afterwards
+	return os.RemoveAll(evictedDir)
+}
+
+func evictDatasetCacheEntries(cacheDir string, evictedDir string, entries []datasetCacheEntry, maxBytes int64, livePodUids map[string]bool) error {
+	unlock, err := lockDatasetCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tempDirs, err := os.ReadDir(filepath.Join(cacheDir, datasetCacheTempDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, d := range tempDirs {
+		if !livePodUids[d.Name()] {
+			if err := os.RemoveAll(filepath.Join(cacheDir, datasetCacheTempDir, d.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	leasedEntries, err := sweepDatasetCacheLeases(cacheDir, livePodUids)
+	if err != nil {
+		return err
+	}
+
+	var totalBytes int64
+	var candidates []datasetCacheEntry
+	for _, entry := range entries {
+		totalBytes += entry.size
+
+		relativePath, err := filepath.Rel(cacheDir, entry.path)
+		if err != nil {
+			return err
+		}
+
+		if !leasedEntries[relativePath] {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].lastUsed.Before(candidates[j].lastUsed) })
+
+	for _, entry := range candidates {
+		if totalBytes <= maxBytes {
+			break
+		}
+
+		if err := os.MkdirAll(evictedDir, 0755); err != nil {
+			return err
+		}
+		evictedPath, err := os.MkdirTemp(evictedDir, "")
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(entry.path, filepath.Join(evictedPath, "entry")); err != nil {
+			return err
+		}
+
+		totalBytes -= entry.size
+		log.Info().Str("directory", entry.path).Int64("bytes", entry.size).Msg("Evicted dataset from the node's cache")
+	}
+
+	return nil
+}
+
+// sweepDatasetCacheLeases removes the leases of pods that are no longer on the node and returns the
+// NAME/VERSION paths of the entries that are still leased.
+func sweepDatasetCacheLeases(cacheDir string, livePodUids map[string]bool) (map[string]bool, error) {
+	leasesDir := filepath.Join(cacheDir, datasetCacheLeasesDir)
+	leasedEntries := make(map[string]bool)
+	err := filepath.WalkDir(leasesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == leasesDir {
+				return nil
+			}
+			return err
+		}
+
+		relativePath, err := filepath.Rel(leasesDir, path)
+		if err != nil {
+			return err
+		}
+
+		// Leases are files at NAME/VERSION/POD_UID
+		if d.IsDir() || strings.Count(filepath.ToSlash(relativePath), "/") != 2 {
+			return nil
+		}
+
+		if livePodUids[d.Name()] {
+			leasedEntries[filepath.Dir(relativePath)] = true
+			return nil
+		}
+
+		return os.Remove(path)
+	})
+
+	return leasedEntries, err
+}
+
+// listDatasetCacheEntries returns the NAME/VERSION directories of a node's dataset cache with their sizes.
+func listDatasetCacheEntries(cacheDir string) ([]datasetCacheEntry, error) {
+	names, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []datasetCacheEntry
+	for _, name := range names {
+		if !name.IsDir() || strings.HasPrefix(name.Name(), ".") {
+			continue
+		}
+
+		versions, err := os.ReadDir(filepath.Join(cacheDir, name.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, version := range versions {
+			if !version.IsDir() {
+				continue
+			}
+
+			entry := datasetCacheEntry{path: filepath.Join(cacheDir, name.Name(), version.Name())}
+			info, err := version.Info()
+			if err != nil {
+				return nil, err
+			}
+			entry.lastUsed = info.ModTime()
+
+			err = filepath.WalkDir(entry.path, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() {
+					info, err := d.Info()
+					if err != nil {
+						return err
+					}
+					entry.size += info.Size()
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// NewDatasetCacheEvictCommand creates the command that keeps the dataset cache of a node within its size limit.
+// listLivePods returns the UIDs of the pods on the node that have not terminated.
+func NewDatasetCacheEvictCommand(listLivePods func(ctx context.Context) (map[string]bool, error)) *cobra.Command {
+	cacheDir := ""
+	maxSize := ""
+	interval := time.Minute
+
+	cmd := &cobra.Command{
+		Use:   "evict-datasets --cache-dir DIRECTORY --max-size SIZE [--interval DURATION]",
+		Short: "Evict datasets from a node's cache",
+		Long: `Periodically remove the least recently used dataset versions from a node's dataset cache while
+the cache is larger than --max-size. Dataset versions that are mounted by a pod that is still on the node are never
+removed, so the cache can remain larger than --max-size while they are in use.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			maxBytes, err := resource.ParseQuantity(maxSize)
+			if err != nil {
+				return fmt.Errorf("invalid --max-size value '%s': %w", maxSize, err)
+			}
+			if interval <= 0 {
+				return errors.New("--interval must be a positive duration")
+			}
+
+			for {
+				livePodUids, err := listLivePods(cmd.Context())
+				if err != nil {
+					// Leases cannot be checked, so nothing is evicted
+					log.Error().Err(err).Msg("Failed to list the pods on the node")
+				} else if err := evictDatasetCache(cacheDir, maxBytes.Value(), livePodUids); err != nil {
+					log.Error().Err(err).Msg("Failed to evict datasets from the node's cache")
+				}
+
+				select {
+				case <-cmd.Context().Done():
+					return nil
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "The directory of the node's dataset cache (required)")
+	cmd.MarkFlagRequired("cache-dir")
+	cmd.Flags().StringVar(&maxSize, "max-size", "", "The size above which unused datasets are evicted, for example 100Gi (required)")
+	cmd.MarkFlagRequired("max-size")
+	cmd.Flags().DurationVar(&interval, "interval", interval, "How often to check the size of the cache")
+
+	return cmd
+}
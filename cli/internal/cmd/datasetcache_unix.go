@@ -0,0 +1,31 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDatasetCache takes an exclusive lock on a node's dataset cache, which is shared by the
+// pods and the evictor running on the node, and returns a function that releases it.
+func lockDatasetCache(cacheDir string) (unlock func(), err error) {
+	f, err := os.OpenFile(filepath.Join(cacheDir, datasetCacheLockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,10 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import "errors"
+
+func lockDatasetCache(cacheDir string) (unlock func(), err error) {
+	return nil, errors.New("the dataset cache is not supported on this platform")
+}
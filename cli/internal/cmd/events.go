@@ -0,0 +1,116 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/microsoft/tyger/cli/internal/controlplane"
+	"github.com/microsoft/tyger/cli/internal/controlplane/model"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var runEventTableColumns = []tableColumn[model.RunEvent]{
+	{"TIME", func(e model.RunEvent) string { return formatTableTime(&e.Timestamp) }},
+	{"TYPE", func(e model.RunEvent) string { return e.Type }},
+	{"REPLICA", func(e model.RunEvent) string {
+		if e.Replica == nil {
+			return ""
+		}
+		return fmt.Sprintf("%s/%d", e.Target, *e.Replica)
+	}},
+	{"MESSAGE", func(e model.RunEvent) string { return e.Message }},
+}
+
+func newRunEventsCommand() *cobra.Command {
+	var flags struct {
+		watch  bool
+		output string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "events ID [--watch] [--output FORMAT]",
+		Short: "Show the events of a run",
+		Long: `Show a timeline of the events of a run, such as its pods being scheduled, container images being pulled,
+replicas starting and exiting, and buffers being opened and closed. This helps to understand why a run
+is not making progress, for example when it stays pending.
+
+With --watch, events are written as they happen until the run completes.
+
+Events about the pods of a run are only available until the run has completed and its pods have been removed.`,
+		DisableFlagsInUseLine: true,
+		Args:                  exactlyOneArg("run ID"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runId, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return err
+			}
+
+			printer, err := newOutputPrinter(flags.output, runEventTableColumns)
+			if err != nil {
+				return err
+			}
+
+			if !flags.watch {
+				events := make([]model.RunEvent, 0)
+				if _, err := controlplane.InvokeRequest(cmd.Context(), http.MethodGet, fmt.Sprintf("v1/runs/%d/events", runId), nil, &events); err != nil {
+					return err
+				}
+
+				return printer.printList(events)
+			}
+
+			// The server writes the events that already happened when the watch starts,
+			// so they need to be skipped when the watch is restarted after an error.
+			printedEvents := make(map[string]bool)
+			consecutiveErrors := 0
+		start:
+			eventChan, errChan := watchJsonLines[model.RunEvent](cmd.Context(), fmt.Sprintf("v1/runs/%d/events?watch=true", runId))
+			for {
+				select {
+				case err := <-errChan:
+					if err == errNotFound {
+						return errors.New("run not found")
+					}
+
+					consecutiveErrors++
+					if consecutiveErrors > 1 {
+						return err
+					}
+
+					log.Error().Err(err).Msg("error watching run events")
+					goto start
+				case event, ok := <-eventChan:
+					if !ok {
+						return nil
+					}
+					consecutiveErrors = 0
+
+					key, err := json.Marshal(event)
+					if err != nil {
+						return err
+					}
+					if printedEvents[string(key)] {
+						continue
+					}
+					printedEvents[string(key)] = true
+
+					if err := printer.printWatchEvent(event); err != nil {
+						return err
+					}
+				}
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&flags.watch, "watch", "w", false, "Write events as they happen until the run completes")
+	cmd.Flags().StringVarP(&flags.output, "output", "o", "json", outputFlagUsage+" With --watch, each event is written as a JSON line, a YAML document, or a table row.")
+
+	return cmd
+}
@@ -0,0 +1,183 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/microsoft/tyger/cli/internal/controlplane"
+	"github.com/microsoft/tyger/cli/internal/controlplane/model"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/websocket"
+	"golang.org/x/term"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The channels of the Kubernetes v4.channel.k8s.io exec protocol, which the server relays.
+// The first byte of each WebSocket message is the channel and the rest is the data.
+const (
+	execStdinChannel  = 0
+	execStdoutChannel = 1
+	execStderrChannel = 2
+	execStatusChannel = 3
+	execResizeChannel = 4
+)
+
+func newRunExecShellCommand() *cobra.Command {
+	replica := 0
+
+	cmd := &cobra.Command{
+		Use:   "exec-shell ID [--replica N] [-- COMMAND [ARG...]]",
+		Short: "Execute a command in a running job replica",
+		Long: `Execute a command in the main container of a running job replica, streaming stdin, stdout and stderr.
+If no command is given, an interactive 'sh' shell is started.
+
+When stdin is a terminal, the command is given a terminal too. This command exits with the exit code of the remote command.`,
+		DisableFlagsInUseLine: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			argsBeforeDash := cmd.ArgsLenAtDash()
+			if argsBeforeDash == -1 {
+				argsBeforeDash = len(args)
+			}
+			if argsBeforeDash != 1 {
+				return errors.New("exactly one run ID must be given, followed by -- and the command to execute")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runId := args[0]
+			command := args[1:]
+			if len(command) == 0 {
+				command = []string{"sh"}
+			}
+
+			run := model.Run{}
+			if _, err := controlplane.InvokeRequest(cmd.Context(), http.MethodGet, fmt.Sprintf("v1/runs/%s", runId), nil, &run); err != nil {
+				return err
+			}
+
+			if run.Status != nil {
+				switch *run.Status {
+				case model.Pending:
+					return fmt.Errorf("run %s has not started yet", runId)
+				case model.Succeeded, model.Failed, model.Canceling, model.Canceled:
+					return fmt.Errorf("run %s is no longer running. Its status is %s", runId, run.Status)
+				}
+			}
+
+			if replica < 0 || replica >= max(run.Job.Replicas, 1) {
+				return fmt.Errorf("the replica must be between 0 and %d", max(run.Job.Replicas, 1)-1)
+			}
+
+			stdinFd := int(os.Stdin.Fd())
+			tty := term.IsTerminal(stdinFd) && term.IsTerminal(int(os.Stdout.Fd()))
+
+			query := url.Values{}
+			query.Set("replica", strconv.Itoa(replica))
+			query.Set("tty", strconv.FormatBool(tty))
+			for _, arg := range command {
+				query.Add("command", arg)
+			}
+
+			conn, err := controlplane.DialWebSocket(cmd.Context(), fmt.Sprintf("v1/runs/%s/exec?%s", runId, query.Encode()))
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			if tty {
+				oldState, err := term.MakeRaw(stdinFd)
+				if err != nil {
+					return fmt.Errorf("unable to configure the terminal: %w", err)
+				}
+				defer term.Restore(stdinFd, oldState)
+
+				if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+					size, _ := json.Marshal(map[string]int{"Width": width, "Height": height})
+					if err := websocket.Message.Send(conn, append([]byte{execResizeChannel}, size...)); err != nil {
+						return fmt.Errorf("error writing to the server: %w", err)
+					}
+				}
+			}
+
+			go func() {
+				buf := make([]byte, 32*1024)
+				for {
+					n, err := os.Stdin.Read(buf)
+					if n > 0 {
+						if err := websocket.Message.Send(conn, append([]byte{execStdinChannel}, buf[:n]...)); err != nil {
+							return
+						}
+					}
+					if err != nil {
+						// The protocol has no way of closing stdin, so the remote command does not see the end of the input.
+						return
+					}
+				}
+			}()
+
+			var status *metav1.Status
+			for {
+				var message []byte
+				if err := websocket.Message.Receive(conn, &message); err != nil {
+					if err == io.EOF {
+						break
+					}
+					return fmt.Errorf("error reading from the server: %w", err)
+				}
+
+				if len(message) == 0 {
+					continue
+				}
+
+				switch message[0] {
+				case execStdoutChannel:
+					os.Stdout.Write(message[1:])
+				case execStderrChannel:
+					os.Stderr.Write(message[1:])
+				case execStatusChannel:
+					status = &metav1.Status{}
+					if err := json.Unmarshal(message[1:], status); err != nil {
+						return fmt.Errorf("unable to understand the status of the command: %w", err)
+					}
+				}
+			}
+
+			return execStatusToError(status)
+		},
+	}
+
+	cmd.Flags().IntVarP(&replica, "replica", "r", 0, "The index of the job replica to execute the command in")
+
+	return cmd
+}
+
+func execStatusToError(status *metav1.Status) error {
+	if status == nil {
+		return errors.New("the connection was closed before the command completed")
+	}
+
+	if status.Status == metav1.StatusSuccess {
+		return nil
+	}
+
+	if status.Reason == "NonZeroExitCode" && status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			if cause.Type == "ExitCode" {
+				if code, err := strconv.Atoi(cause.Message); err == nil {
+					return &ExitCodeError{Code: code, Message: fmt.Sprintf("command terminated with exit code %d", code)}
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("unable to execute the command: %s", status.Message)
+}
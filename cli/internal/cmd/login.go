@@ -156,14 +156,35 @@ proxy: auto
 	return loginCmd
 }
 
+type loginStatus struct {
+	ServerUri string `json:"serverUri"`
+	Principal string `json:"principal,omitempty"`
+}
+
+var loginStatusTableColumns = []tableColumn[loginStatus]{
+	{"SERVER", func(s loginStatus) string { return s.ServerUri }},
+	{"PRINCIPAL", func(s loginStatus) string { return s.Principal }},
+}
+
 func newLoginStatusCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:                   "status",
+	outputFormat := ""
+
+	cmd := &cobra.Command{
+		Use:                   "status [--output FORMAT]",
 		Short:                 "Get the login status",
 		Long:                  `Get the login status.`,
 		DisableFlagsInUseLine: true,
 		Args:                  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			var printer *outputPrinter[loginStatus]
+			if outputFormat != "" {
+				var err error
+				printer, err = newOutputPrinter(outputFormat, loginStatusTableColumns)
+				if err != nil {
+					return err
+				}
+			}
+
 			serviceInfo, err := settings.GetServiceInfoFromContext(cmd.Context())
 
 			if err != nil || serviceInfo.GetServerUri() == nil {
@@ -176,6 +197,10 @@ func newLoginStatusCommand() *cobra.Command {
 			}
 
 			principal := serviceInfo.GetPrincipal()
+			if printer != nil {
+				return printer.printItem(loginStatus{ServerUri: serviceInfo.GetServerUri().String(), Principal: principal})
+			}
+
 			if principal == "" {
 				fmt.Printf("You are anonymously logged in to %s\n", serviceInfo.GetServerUri())
 			} else {
@@ -184,6 +209,10 @@ func newLoginStatusCommand() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", "", outputFlagUsage+" If not given, the login status is described in a sentence.")
+
+	return cmd
 }
 
 func NewLogoutCommand() *cobra.Command {
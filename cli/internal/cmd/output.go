@@ -0,0 +1,309 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/microsoft/tyger/cli/internal/controlplane"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+const outputFlagUsage = "Output format. One of json, yaml, table, jsonpath=TEMPLATE, or go-template=TEMPLATE."
+
+func addOutputFlag(cmd *cobra.Command, format *string) {
+	cmd.Flags().StringVarP(format, "output", "o", "json", outputFlagUsage)
+}
+
+// tableColumn is a column of the table output format.
+type tableColumn[T any] struct {
+	header string
+	value  func(T) string
+}
+
+// outputPrinter writes resources to stdout in the format given with --output.
+type outputPrinter[T any] struct {
+	format  string
+	columns []tableColumn[T]
+
+	// An optional function that returns the value to write for a resource in formats other than table.
+	transform func(T) any
+
+	jsonPath        *jsonpath.JSONPath
+	goTemplate      *template.Template
+	tableWriter     *tabwriter.Writer
+	headerFormatted bool
+	watchWidths     []int
+	out             io.Writer
+}
+
+// newOutputPrinter validates the output format and parses its template, if any, so that an invalid
+// format is reported before any request is made.
+func newOutputPrinter[T any](format string, columns []tableColumn[T]) (*outputPrinter[T], error) {
+	p := &outputPrinter[T]{columns: columns, out: os.Stdout}
+
+	name, templateText, _ := strings.Cut(format, "=")
+	switch name {
+	case "json", "yaml", "table":
+		if templateText != "" {
+			return nil, fmt.Errorf("the output format '%s' does not take a template", name)
+		}
+	case "jsonpath":
+		if templateText == "" {
+			return nil, fmt.Errorf("a template is required for the output format '%s'. For example: %s='{.id}'", name, name)
+		}
+		if !strings.Contains(templateText, "{") {
+			templateText = fmt.Sprintf("{%s}", templateText)
+		}
+
+		p.jsonPath = jsonpath.New("output").AllowMissingKeys(true)
+		if err := p.jsonPath.Parse(templateText); err != nil {
+			return nil, fmt.Errorf("invalid jsonpath template: %w", err)
+		}
+	case "go-template":
+		if templateText == "" {
+			return nil, fmt.Errorf("a template is required for the output format '%s'. For example: %s='{{.id}}'", name, name)
+		}
+
+		var err error
+		p.goTemplate, err = template.New("output").Parse(templateText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid go-template: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid output format '%s'. %s", format, outputFlagUsage)
+	}
+
+	p.format = name
+	return p, nil
+}
+
+// printItem writes a single resource.
+func (p *outputPrinter[T]) printItem(item T) error {
+	if p.format == "table" {
+		p.writeTableRow(item)
+		return p.tableWriter.Flush()
+	}
+
+	if p.format == "json" {
+		return p.writeIndentedJson(p.data(item))
+	}
+
+	return p.writeData(p.data(item))
+}
+
+// printList writes a list of resources. Templates are evaluated against the list as a whole.
+func (p *outputPrinter[T]) printList(items []T) error {
+	if p.format == "table" {
+		p.writeTableHeader()
+		for _, item := range items {
+			p.writeTableRow(item)
+		}
+		return p.tableWriter.Flush()
+	}
+
+	data := make([]any, 0, len(items))
+	for _, item := range items {
+		data = append(data, p.data(item))
+	}
+
+	if p.format == "json" {
+		return p.writeIndentedJson(data)
+	}
+
+	return p.writeData(data)
+}
+
+// printWatchEvent writes one of a stream of resources. JSON is written on a single line
+// and the table header is only written before the first event.
+func (p *outputPrinter[T]) printWatchEvent(item T) error {
+	switch p.format {
+	case "table":
+		return p.writeWatchTableRow(item)
+	case "json":
+		bytes, err := json.Marshal(p.data(item))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.out, string(bytes))
+		return err
+	case "yaml":
+		if _, err := fmt.Fprintln(p.out, "---"); err != nil {
+			return err
+		}
+	}
+
+	return p.writeData(p.data(item))
+}
+
+// printPages writes the resources returned by a list request. JSON is written
+// as pages are received, while other formats wait for all pages to be read.
+func (p *outputPrinter[T]) printPages(ctx context.Context, uri string, limit int, warnIfTruncated bool) error {
+	if p.format == "json" && p.transform == nil {
+		return controlplane.InvokePageRequests[T](ctx, uri, limit, warnIfTruncated)
+	}
+
+	items, err := controlplane.GetPages[T](ctx, uri, limit, warnIfTruncated)
+	if err != nil {
+		return err
+	}
+
+	return p.printList(items)
+}
+
+func (p *outputPrinter[T]) data(item T) any {
+	if p.transform != nil {
+		return p.transform(item)
+	}
+	return item
+}
+
+func (p *outputPrinter[T]) writeIndentedJson(data any) error {
+	bytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(p.out, string(bytes))
+	return err
+}
+
+// writeData writes the data in the yaml, jsonpath, or go-template formats.
+func (p *outputPrinter[T]) writeData(data any) error {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if p.format == "yaml" {
+		yamlBytes, err := yaml.JSONToYAML(jsonBytes)
+		if err != nil {
+			return err
+		}
+		_, err = p.out.Write(yamlBytes)
+		return err
+	}
+
+	// Templates are evaluated against the JSON representation so that field names
+	// are the same as in the json output format.
+	var genericData any
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&genericData); err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if p.jsonPath != nil {
+		err = p.jsonPath.Execute(buf, genericData)
+	} else {
+		err = p.goTemplate.Execute(buf, genericData)
+	}
+	if err != nil {
+		return fmt.Errorf("error executing the output template: %w", err)
+	}
+
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteString("\n")
+	}
+
+	_, err = p.out.Write(buf.Bytes())
+	return err
+}
+
+func (p *outputPrinter[T]) writeTableHeader() {
+	if p.tableWriter == nil {
+		p.tableWriter = tabwriter.NewWriter(p.out, 0, 0, 3, ' ', 0)
+	}
+	if p.headerFormatted {
+		return
+	}
+
+	headers := make([]string, 0, len(p.columns))
+	for _, c := range p.columns {
+		headers = append(headers, c.header)
+	}
+	fmt.Fprintln(p.tableWriter, strings.Join(headers, "\t"))
+	p.headerFormatted = true
+}
+
+func (p *outputPrinter[T]) writeTableRow(item T) {
+	p.writeTableHeader()
+
+	values := make([]string, 0, len(p.columns))
+	for _, c := range p.columns {
+		values = append(values, c.value(item))
+	}
+	fmt.Fprintln(p.tableWriter, strings.Join(values, "\t"))
+}
+
+// writeWatchTableRow writes a table row right away. Since a tabwriter only aligns the rows
+// written between flushes, the columns are instead padded to the widest value seen so far.
+func (p *outputPrinter[T]) writeWatchTableRow(item T) error {
+	rows := make([][]string, 0, 2)
+	if p.watchWidths == nil {
+		p.watchWidths = make([]int, len(p.columns))
+		headers := make([]string, 0, len(p.columns))
+		for _, c := range p.columns {
+			headers = append(headers, c.header)
+		}
+		rows = append(rows, headers)
+	}
+
+	values := make([]string, 0, len(p.columns))
+	for _, c := range p.columns {
+		values = append(values, c.value(item))
+	}
+	rows = append(rows, values)
+
+	for _, row := range rows {
+		for i, v := range row {
+			p.watchWidths[i] = max(p.watchWidths[i], len(v))
+		}
+	}
+
+	for _, row := range rows {
+		line := &strings.Builder{}
+		for i, v := range row {
+			if i == len(row)-1 {
+				line.WriteString(v)
+			} else {
+				fmt.Fprintf(line, "%-*s   ", p.watchWidths[i], v)
+			}
+		}
+		if _, err := fmt.Fprintln(p.out, line.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatTableTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatTableTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
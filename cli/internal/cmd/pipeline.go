@@ -0,0 +1,350 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/tyger/cli/internal/controlplane"
+	"github.com/microsoft/tyger/cli/internal/controlplane/model"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// pipelineSpec declares a set of runs, called stages, where the output buffers of a stage
+// can be wired to the input buffers of other stages.
+type pipelineSpec struct {
+	// Tags to apply to the buffers created to connect stages and to any buffer created by the stages' jobs
+	Tags   map[string]string `json:"tags,omitempty"`
+	Stages []pipelineStage   `json:"stages"`
+}
+
+type pipelineStage struct {
+	Name string `json:"name"`
+
+	// Maps a buffer parameter of the stage's job to an output of another stage, in the form STAGE.PARAMETER
+	Inputs map[string]string `json:"inputs,omitempty"`
+
+	model.Run
+}
+
+func NewPipelineCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "pipeline",
+		Aliases:               []string{"pipelines"},
+		Short:                 "Manage pipelines",
+		Long:                  `Manage pipelines`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			return errors.New("a command is required")
+		},
+	}
+
+	cmd.AddCommand(newPipelineCreateCommand())
+
+	return cmd
+}
+
+func newPipelineCreateCommand() *cobra.Command {
+	specFile := ""
+	noWait := false
+
+	cmd := &cobra.Command{
+		Use:   "create --file YAML_SPEC [--no-wait]",
+		Short: "Creates the runs of a pipeline and watches them until completion",
+		Long: `Creates the runs of a pipeline. The pipeline specification declares stages, each of which is a run,
+and wires the output buffers of stages to the input buffers of other stages. The buffers that connect
+stages are created, all runs are created, and the IDs of the runs are written to stdout as a JSON object.
+
+The runs are then watched until they have all succeeded. If a stage fails, the remaining stages are canceled
+and the command exits with a non-zero code.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			bytes, err := os.ReadFile(specFile)
+			if err != nil {
+				return fmt.Errorf("failed to read file %s: %w", specFile, err)
+			}
+
+			spec := pipelineSpec{}
+			if err := yaml.UnmarshalStrict(bytes, &spec); err != nil {
+				return fmt.Errorf("failed to parse file %s: %w", specFile, err)
+			}
+
+			stages, err := orderPipelineStages(spec.Stages)
+			if err != nil {
+				return err
+			}
+
+			runIds, err := createPipeline(cmd.Context(), spec.Tags, stages)
+			if err != nil {
+				return err
+			}
+
+			formattedRunIds, err := json.MarshalIndent(runIds, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(formattedRunIds))
+
+			if noWait {
+				return nil
+			}
+
+			return watchPipeline(cmd.Context(), stages, runIds)
+		},
+	}
+
+	cmd.Flags().StringVarP(&specFile, "file", "f", "", "A YAML file with the pipeline specification.")
+	cmd.MarkFlagRequired("file")
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Exit once the runs have been created instead of watching them. Failed stages will then not cancel the others.")
+
+	return cmd
+}
+
+// orderPipelineStages validates the stages and returns them in an order where every stage comes
+// after the stages whose outputs it reads.
+func orderPipelineStages(stages []pipelineStage) ([]pipelineStage, error) {
+	if len(stages) == 0 {
+		return nil, errors.New("a pipeline must have at least one stage")
+	}
+
+	stagesByName := make(map[string]pipelineStage, len(stages))
+	for _, stage := range stages {
+		if stage.Name == "" {
+			return nil, errors.New("every stage must have a name")
+		}
+		if strings.Contains(stage.Name, ".") {
+			return nil, fmt.Errorf("the stage name '%s' cannot contain '.'", stage.Name)
+		}
+		if _, ok := stagesByName[stage.Name]; ok {
+			return nil, fmt.Errorf("the stage name '%s' is duplicated", stage.Name)
+		}
+		stagesByName[stage.Name] = stage
+	}
+
+	for _, stage := range stages {
+		for parameter, source := range stage.Inputs {
+			sourceStage, _, err := parsePipelineStageOutput(source)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := stagesByName[sourceStage]; !ok {
+				return nil, fmt.Errorf("the input '%s' of stage '%s' refers to the unknown stage '%s'", parameter, stage.Name, sourceStage)
+			}
+			if _, ok := stage.Job.Buffers[parameter]; ok {
+				return nil, fmt.Errorf("the buffer parameter '%s' of stage '%s' cannot be both mapped to a buffer and wired to another stage", parameter, stage.Name)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(stages))
+	ordered := make([]pipelineStage, 0, len(stages))
+
+	var visit func(stage pipelineStage) error
+	visit = func(stage pipelineStage) error {
+		switch state[stage.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("the stages form a cycle at stage '%s'", stage.Name)
+		}
+
+		state[stage.Name] = visiting
+
+		// Visit the inputs in a deterministic order
+		parameters := make([]string, 0, len(stage.Inputs))
+		for parameter := range stage.Inputs {
+			parameters = append(parameters, parameter)
+		}
+		sort.Strings(parameters)
+
+		for _, parameter := range parameters {
+			sourceStage, _, _ := parsePipelineStageOutput(stage.Inputs[parameter])
+			if err := visit(stagesByName[sourceStage]); err != nil {
+				return err
+			}
+		}
+
+		state[stage.Name] = visited
+		ordered = append(ordered, stage)
+		return nil
+	}
+
+	for _, stage := range stages {
+		if err := visit(stage); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+func parsePipelineStageOutput(source string) (stage string, parameter string, err error) {
+	stage, parameter, ok := strings.Cut(source, ".")
+	if !ok || stage == "" || parameter == "" {
+		return "", "", fmt.Errorf("invalid stage output '%s'. It must be in the form STAGE.PARAMETER", source)
+	}
+
+	return stage, parameter, nil
+}
+
+// createPipeline creates the buffers that connect the stages and then the runs of the stages.
+// If a run cannot be created, the runs that have already been created are canceled.
+func createPipeline(ctx context.Context, tags map[string]string, stages []pipelineStage) (map[string]int64, error) {
+	runs := make(map[string]*model.Run, len(stages))
+	for i := range stages {
+		run := stages[i].Run
+		run.Job.Buffers = copyMap(run.Job.Buffers)
+		run.Job.Tags = copyMap(run.Job.Tags)
+		for k, v := range tags {
+			if _, ok := run.Job.Tags[k]; !ok {
+				run.Job.Tags[k] = v
+			}
+		}
+
+		if run.Job.Codespec.Inline != nil {
+			run.Job.Codespec.Inline.Kind = "job"
+		}
+		if run.Worker != nil && run.Worker.Codespec.Inline != nil {
+			run.Worker.Codespec.Inline.Kind = "worker"
+		}
+
+		runs[stages[i].Name] = &run
+	}
+
+	for _, stage := range stages {
+		for parameter, source := range stage.Inputs {
+			sourceStage, sourceParameter, _ := parsePipelineStageOutput(source)
+			sourceRun := runs[sourceStage]
+
+			bufferId, ok := sourceRun.Job.Buffers[sourceParameter]
+			if !ok {
+				buffer := model.Buffer{}
+				_, err := controlplane.InvokeRequest(ctx, http.MethodPost, "v1/buffers", model.Buffer{Tags: tags}, &buffer)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create a buffer for '%s': %w", source, err)
+				}
+
+				bufferId = buffer.Id
+				sourceRun.Job.Buffers[sourceParameter] = bufferId
+			}
+
+			runs[stage.Name].Job.Buffers[parameter] = bufferId
+		}
+	}
+
+	runIds := make(map[string]int64, len(stages))
+	for _, stage := range stages {
+		committedRun := model.Run{}
+		_, err := controlplane.InvokeRequest(ctx, http.MethodPost, "v1/runs", runs[stage.Name], &committedRun)
+		if err != nil {
+			cancelPipelineRuns(ctx, runIds)
+			return nil, fmt.Errorf("failed to create the run for stage '%s': %w", stage.Name, err)
+		}
+
+		log.Info().Str("stage", stage.Name).Int64("runId", committedRun.Id).Msg("Run created")
+		runIds[stage.Name] = committedRun.Id
+	}
+
+	return runIds, nil
+}
+
+// watchPipeline waits for the runs of all stages to complete. As soon as one of them does not
+// succeed, the runs of the other stages are canceled.
+func watchPipeline(ctx context.Context, stages []pipelineStage, runIds map[string]int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type stageResult struct {
+		stage string
+		run   model.Run
+		err   error
+	}
+
+	results := make(chan stageResult, len(stages))
+	for _, stage := range stages {
+		go func(stage string, runId int64) {
+			run, err := watchPipelineRun(ctx, stage, runId)
+			results <- stageResult{stage: stage, run: run, err: err}
+		}(stage.Name, runIds[stage.Name])
+	}
+
+	remaining := copyMap(runIds)
+	for range stages {
+		result := <-results
+		delete(remaining, result.stage)
+
+		var failure error
+		if result.err != nil {
+			failure = fmt.Errorf("failed to watch the run of stage '%s': %w", result.stage, result.err)
+		} else if result.run.Status == nil || *result.run.Status != model.Succeeded {
+			status := "unknown"
+			if result.run.Status != nil {
+				status = result.run.Status.String()
+			}
+			failure = fmt.Errorf("the run %d of stage '%s' did not succeed. Status: %s", result.run.Id, result.stage, status)
+			if result.run.StatusReason != "" {
+				failure = fmt.Errorf("%w (%s)", failure, result.run.StatusReason)
+			}
+		}
+
+		if failure != nil {
+			cancelPipelineRuns(ctx, remaining)
+			return failure
+		}
+
+		log.Info().Str("stage", result.stage).Int64("runId", result.run.Id).Msg("Stage succeeded")
+	}
+
+	log.Info().Msg("Pipeline succeeded")
+	return nil
+}
+
+// watchPipelineRun watches a run until it has completed, logging its status changes, and returns its final state.
+func watchPipelineRun(ctx context.Context, stage string, runId int64) (model.Run, error) {
+	var lastStatus *model.RunStatus
+	return waitForRun(ctx, runId, func(event model.Run) error {
+		if event.Status != nil && (lastStatus == nil || *lastStatus != *event.Status) {
+			log.Info().Str("stage", stage).Int64("runId", runId).Str("status", event.Status.String()).Msg("Run status changed")
+			lastStatus = event.Status
+		}
+		return nil
+	})
+}
+
+func cancelPipelineRuns(ctx context.Context, runIds map[string]int64) {
+	for stage, runId := range runIds {
+		_, err := controlplane.InvokeRequest(ctx, http.MethodPost, fmt.Sprintf("v1/runs/%d/cancel", runId), nil, nil)
+		if err != nil {
+			log.Warn().Err(err).Str("stage", stage).Int64("runId", runId).Msg("Failed to cancel run")
+		} else {
+			log.Warn().Str("stage", stage).Int64("runId", runId).Msg("Run canceled")
+		}
+	}
+}
+
+// copyMap returns a non-nil copy of the map.
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
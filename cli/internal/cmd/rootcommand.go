@@ -133,3 +133,13 @@ func hasFlagChanged(cmd *cobra.Command, flagName string) bool {
 
 	return flag.Changed
 }
+
+// ExitCodeError is returned by commands that need the process to exit with a specific exit code.
+type ExitCodeError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Message
+}
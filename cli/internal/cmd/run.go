@@ -15,6 +15,7 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -52,9 +53,13 @@ func NewRunCommand() *cobra.Command {
 	cmd.AddCommand(newRunExecCommand())
 	cmd.AddCommand(newRunShowCommand())
 	cmd.AddCommand(newRunWatchCommand())
+	cmd.AddCommand(newRunWaitCommand())
+	cmd.AddCommand(newRunEventsCommand())
 	cmd.AddCommand(newRunLogsCommand())
+	cmd.AddCommand(newRunExecShellCommand())
 	cmd.AddCommand(newRunListCommand())
 	cmd.AddCommand(newRunCancelCommand())
+	cmd.AddCommand(newRunUsageCommand())
 
 	return cmd
 }
@@ -75,8 +80,12 @@ func newRunExecCommand() *cobra.Command {
 	logs := false
 	logTimestamps := false
 
-	var inputBufferParameter string
-	var outputBufferParameter string
+	inputPaths := make(map[string]string)
+	outputPaths := make(map[string]string)
+
+	// Map each unmapped buffer parameter to a local path, or to "" for standard input or output.
+	var inputBindings map[string]string
+	var outputBindings map[string]string
 
 	preValidate := func(ctx context.Context, run model.Run) error {
 		var resolvedCodespec model.Codespec
@@ -95,7 +104,7 @@ func newRunExecCommand() *cobra.Command {
 
 		bufferParameters := resolvedCodespec.Buffers
 		if bufferParameters == nil {
-			return nil
+			bufferParameters = &model.BufferParameters{}
 		}
 		unmappedInputBuffers := make([]string, 0)
 		for _, input := range bufferParameters.Inputs {
@@ -109,50 +118,40 @@ func newRunExecCommand() *cobra.Command {
 				unmappedOutputBuffers = append(unmappedOutputBuffers, output)
 			}
 		}
-		switch len(unmappedInputBuffers) {
-		case 0:
-			break
-		case 1:
-			inputBufferParameter = unmappedInputBuffers[0]
-		default:
-			return errors.New("exec cannot be called if the job has multiple unmapped input buffers")
-		}
 
-		switch len(unmappedOutputBuffers) {
-		case 0:
-			break
-		case 1:
-			outputBufferParameter = unmappedOutputBuffers[0]
-		default:
-			return errors.New("exec cannot be called if the job has multiple unmapped output buffers")
+		var err error
+		inputBindings, err = bindExecBuffers("input", unmappedInputBuffers, inputPaths)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		outputBindings, err = bindExecBuffers("output", unmappedOutputBuffers, outputPaths)
+		return err
 	}
 
 	blockSize := dataplane.DefaultBlockSize
 	writeDop := dataplane.DefaultWriteDop
 	readDop := dataplane.DefaultReadDop
+	compression := dataplane.CompressionNone
 
 	postCreate := func(ctx context.Context, run model.Run) error {
 		log.Logger = log.Logger.With().Int64("runId", run.Id).Logger()
 		log.Info().Msg("Run created")
-		var inputSasUri string
-		var outputSasUri string
-		var err error
-		if inputBufferParameter != "" {
-			bufferId := run.Job.Buffers[inputBufferParameter]
-			inputSasUri, err = getBufferAccessUri(ctx, bufferId, true)
+		inputSasUris := make(map[string]string, len(inputBindings))
+		for name := range inputBindings {
+			sasUri, err := getBufferAccessUri(ctx, run.Job.Buffers[name], true)
 			if err != nil {
 				return err
 			}
+			inputSasUris[name] = sasUri
 		}
-		if outputBufferParameter != "" {
-			bufferId := run.Job.Buffers[outputBufferParameter]
-			outputSasUri, err = getBufferAccessUri(ctx, bufferId, false)
+		outputSasUris := make(map[string]string, len(outputBindings))
+		for name := range outputBindings {
+			sasUri, err := getBufferAccessUri(ctx, run.Job.Buffers[name], false)
 			if err != nil {
 				return err
 			}
+			outputSasUris[name] = sasUri
 		}
 
 		mainWg := sync.WaitGroup{}
@@ -167,37 +166,64 @@ func newRunExecCommand() *cobra.Command {
 			log.Warn().Msg("Canceling...")
 		}()
 
-		if inputSasUri != "" {
+		// All buffers are streamed concurrently. Files are opened in their own goroutine
+		// because opening a named pipe blocks until the other end is opened.
+		for name, path := range inputBindings {
 			mainWg.Add(1)
-			go func() {
+			go func(name, path, sasUri string) {
 				defer mainWg.Done()
-				err := dataplane.Write(ctx, inputSasUri, os.Stdin,
+				logger := log.With().Str("buffer", name).Logger()
+
+				var inputReader io.Reader = os.Stdin
+				if path != "" {
+					inputFile, err := os.Open(path)
+					if err != nil {
+						logger.Fatal().Err(err).Msg("Unable to open input file for reading")
+					}
+					defer inputFile.Close()
+					inputReader = inputFile
+				}
+
+				err := dataplane.Write(ctx, sasUri, inputReader,
 					dataplane.WithWriteHttpClient(httpClient),
 					dataplane.WithWriteBlockSize(blockSize),
-					dataplane.WithWriteDop(writeDop))
+					dataplane.WithWriteDop(writeDop),
+					dataplane.WithWriteCompression(compression))
 				if err != nil {
 					if errors.Is(err, ctx.Err()) {
 						err = ctx.Err()
 					}
-					log.Fatal().Err(err).Msg("Failed to write input")
+					logger.Fatal().Err(err).Msg("Failed to write input")
 				}
-			}()
+			}(name, path, inputSasUris[name])
 		}
 
-		if outputSasUri != "" {
+		for name, path := range outputBindings {
 			mainWg.Add(1)
-			go func() {
+			go func(name, path, sasUri string) {
 				defer mainWg.Done()
-				err := dataplane.Read(ctx, outputSasUri, os.Stdout,
+				logger := log.With().Str("buffer", name).Logger()
+
+				outputFile := os.Stdout
+				if path != "" {
+					var err error
+					outputFile, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+					if err != nil {
+						logger.Fatal().Err(err).Msg("Unable to open output file for writing")
+					}
+					defer outputFile.Close()
+				}
+
+				err := dataplane.Read(ctx, sasUri, outputFile,
 					dataplane.WithReadHttpClient(httpClient),
 					dataplane.WithReadDop(readDop))
 				if err != nil {
 					if errors.Is(err, ctx.Err()) {
 						err = ctx.Err()
 					}
-					log.Fatal().Err(err).Msg("Failed to read output")
+					logger.Fatal().Err(err).Msg("Failed to read output")
 				}
-			}()
+			}(name, path, outputSasUris[name])
 		}
 
 		logsWg := sync.WaitGroup{}
@@ -293,24 +319,64 @@ func newRunExecCommand() *cobra.Command {
 			blockSize = int(parsedBlockSize)
 		}
 
+		if !slices.Contains(dataplane.SupportedCompressions, compression) {
+			return fmt.Errorf("invalid compression '%s'. Supported values are %v", compression, dataplane.SupportedCompressions)
+		}
+
 		return nil
 	}
 
 	cmd.Short = "Creates a run and reads and writes to its buffers."
 	cmd.Long = `Creates a run.
-If the job has a single input buffer, stdin is streamed to the buffer.
-If the job has a single output buffer, stdout is streamed from the buffer.`
+Unmapped input buffers can be bound to local files or named pipes with --input NAME=PATH,
+and unmapped output buffers with --output NAME=PATH. All bound buffers are streamed concurrently.
+If the job has a single input buffer that is not bound, stdin is streamed to the buffer.
+If the job has a single output buffer that is not bound, stdout is streamed from the buffer.`
 
 	cmd.Flags().BoolVar(&logs, "logs", false, "Print run logs to stderr.")
 	cmd.Flags().BoolVar(&logTimestamps, "timestamps", false, "Print run logs with timestamps.")
 
+	cmd.Flags().StringToStringVar(&inputPaths, "input", nil, "Stream a local file or named pipe to an unmapped input buffer, as NAME=PATH. Can be specified multiple times.")
+	cmd.Flags().StringToStringVar(&outputPaths, "output", nil, "Stream an unmapped output buffer to a local file or named pipe, as NAME=PATH. Can be specified multiple times.")
+
 	cmd.Flags().StringVar(&blockSizeString, "block-size", blockSizeString, "Split the input stream into buffer blocks of this size.")
 	cmd.Flags().IntVar(&writeDop, "write-dop", writeDop, "The degree of parallelism for writing to the input buffer.")
 	cmd.Flags().IntVar(&readDop, "read-dop", readDop, "The degree of parallelism for reading from the output buffer.")
+	cmd.Flags().StringVar(&compression, "compression", compression, fmt.Sprintf("The compression to apply to the blocks of the input buffer. One of %v.", dataplane.SupportedCompressions))
 
 	return cmd
 }
 
+// bindExecBuffers maps each unmapped buffer parameter to the path given for it. At most one
+// parameter can be left without a path, and it is mapped to "" to be streamed through stdin or stdout.
+func bindExecBuffers(direction string, unmappedBuffers []string, paths map[string]string) (map[string]string, error) {
+	for name, path := range paths {
+		if !slices.Contains(unmappedBuffers, name) {
+			return nil, fmt.Errorf("'%s' is not an unmapped %s buffer of the job", name, direction)
+		}
+		if path == "" {
+			return nil, fmt.Errorf("a path must be given for the %s buffer '%s'", direction, name)
+		}
+	}
+
+	bindings := make(map[string]string, len(unmappedBuffers))
+	unbound := make([]string, 0)
+	for _, name := range unmappedBuffers {
+		if path, ok := paths[name]; ok {
+			bindings[name] = path
+		} else {
+			bindings[name] = ""
+			unbound = append(unbound, name)
+		}
+	}
+
+	if len(unbound) > 1 {
+		return nil, fmt.Errorf("exec cannot be called if the job has multiple unmapped %s buffers that are not bound with --%s: %s", direction, direction, strings.Join(unbound, ", "))
+	}
+
+	return bindings, nil
+}
+
 func newRunCreateCommandCore(
 	commandName string,
 	preValidate func(context.Context, model.Run) error,
@@ -322,13 +388,17 @@ func newRunCreateCommandCore(
 		tags            map[string]string
 		nodePool        string
 		replicas        int
+		datasets        map[string]int
 	}
 	var flags struct {
-		specFile string
-		job      codeTargetFlags
-		worker   codeTargetFlags
-		cluster  string
-		timeout  string
+		specFile       string
+		templateValues []string
+		job            codeTargetFlags
+		worker         codeTargetFlags
+		cluster        string
+		timeout        string
+		retries        int
+		retryBackoff   string
 	}
 
 	getCodespecRef := func(ctf codeTargetFlags) model.CodespecRef {
@@ -346,12 +416,26 @@ func newRunCreateCommandCore(
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			newRun := model.Run{}
+			if len(flags.templateValues) > 0 && flags.specFile == "" {
+				return errors.New("--set can only be used together with --file")
+			}
+
 			if flags.specFile != "" {
 				bytes, err := os.ReadFile(flags.specFile)
 				if err != nil {
 					return fmt.Errorf("failed to read file %s: %w", flags.specFile, err)
 				}
 
+				templateValues, err := parseTemplateValues(flags.templateValues)
+				if err != nil {
+					return err
+				}
+
+				bytes, err = expandTemplate(bytes, templateValues)
+				if err != nil {
+					return fmt.Errorf("failed to process file %s: %w", flags.specFile, err)
+				}
+
 				err = yaml.UnmarshalStrict(bytes, &newRun)
 				if err != nil {
 					return fmt.Errorf("failed to parse file %s: %w", flags.specFile, err)
@@ -391,6 +475,14 @@ func newRunCreateCommandCore(
 			if hasFlagChanged(cmd, "replicas") {
 				newRun.Job.Replicas = flags.job.replicas
 			}
+			if len(flags.job.datasets) > 0 {
+				if newRun.Job.Datasets == nil {
+					newRun.Job.Datasets = map[string]int{}
+				}
+				for k, v := range flags.job.datasets {
+					newRun.Job.Datasets[k] = v
+				}
+			}
 
 			cmd.Flags().VisitAll(func(f *pflag.Flag) {
 				if newRun.Worker == nil && f.Changed && strings.HasPrefix(f.Name, "worker") {
@@ -409,6 +501,14 @@ func newRunCreateCommandCore(
 				if hasFlagChanged(cmd, "worker-replicas") {
 					newRun.Worker.Replicas = flags.worker.replicas
 				}
+				if len(flags.worker.datasets) > 0 {
+					if newRun.Worker.Datasets == nil {
+						newRun.Worker.Datasets = map[string]int{}
+					}
+					for k, v := range flags.worker.datasets {
+						newRun.Worker.Datasets[k] = v
+					}
+				}
 			}
 
 			if flags.cluster != "" {
@@ -425,6 +525,31 @@ func newRunCreateCommandCore(
 				newRun.TimeoutSeconds = &seconds
 			}
 
+			if hasFlagChanged(cmd, "retries") {
+				if flags.retries < 0 {
+					return errors.New("the number of retries cannot be negative")
+				}
+				if newRun.RetryPolicy == nil {
+					newRun.RetryPolicy = &model.RetryPolicy{}
+				}
+				newRun.RetryPolicy.MaxAttempts = flags.retries + 1
+			}
+
+			if flags.retryBackoff != "" {
+				duration, err := parseDuration(flags.retryBackoff)
+				if err != nil {
+					return err
+				}
+				if duration < 0 {
+					return errors.New("--retry-backoff cannot be negative")
+				}
+				if newRun.RetryPolicy == nil {
+					newRun.RetryPolicy = &model.RetryPolicy{}
+				}
+				seconds := int(duration.Seconds())
+				newRun.RetryPolicy.BackoffSeconds = &seconds
+			}
+
 			if preValidate != nil {
 				err := preValidate(cmd.Context(), newRun)
 				if err != nil {
@@ -449,6 +574,7 @@ func newRunCreateCommandCore(
 	}
 
 	cmd.Flags().StringVarP(&flags.specFile, "file", "f", "", "A YAML file with the run specification. All other flags override the values in the file.")
+	cmd.Flags().StringArrayVar(&flags.templateValues, "set", nil, "Set the value of a {{ name }} placeholder in the run specification file, as NAME=VALUE. Can be specified multiple times.")
 
 	cmd.Flags().StringVarP(&flags.job.codespec, "codespec", "c", "", "The name of the job codespec to execute")
 	cmd.Flags().StringVar(&flags.job.codespecVersion, "version", "", "The version of the job codespec to execute")
@@ -456,51 +582,89 @@ func newRunCreateCommandCore(
 	cmd.Flags().StringVar(&flags.job.nodePool, "node-pool", "", "The name of the nodepool to execute the job in")
 	cmd.Flags().StringToStringVarP(&flags.job.buffers, "buffer", "b", nil, "maps a codespec buffer parameter to a buffer ID")
 	cmd.Flags().StringToStringVar(&flags.job.tags, "tag", nil, "add a key-value tag to be applied to any buffer created by the job")
+	cmd.Flags().StringToIntVar(&flags.job.datasets, "dataset", nil, "Pin the version of a dataset mounted by the job codespec, as NAME=VERSION. Can be specified multiple times.")
 
 	cmd.Flags().StringVar(&flags.worker.codespec, "worker-codespec", "", "The name of the optional worker codespec to execute")
 	cmd.Flags().StringVar(&flags.worker.codespecVersion, "worker-version", "", "The version of the optional worker codespec to execute")
 	cmd.Flags().IntVar(&flags.worker.replicas, "worker-replicas", 1, "The number of parallel worker replicas. Defaults to 1 if a worker is specified.")
 	cmd.Flags().StringVar(&flags.worker.nodePool, "worker-node-pool", "", "The name of the nodepool to execute the optional worker codespec in")
+	cmd.Flags().StringToIntVar(&flags.worker.datasets, "worker-dataset", nil, "Pin the version of a dataset mounted by the worker codespec, as NAME=VERSION. Can be specified multiple times.")
 
 	cmd.Flags().StringVar(&flags.cluster, "cluster", "", "The name of the cluster to execute in")
+	cmd.Flags().IntVar(&flags.retries, "retries", 0, "The number of times to retry a job replica that fails because it was preempted. Other retryable reasons can be given in the retryPolicy of the run specification file.")
+	cmd.Flags().StringVar(&flags.retryBackoff, "retry-backoff", "", "How long to wait before retrying a failed job replica, e.g. 30s or 5m. The wait doubles with each failed attempt of the replica, up to 1h.")
 	cmd.Flags().StringVar(&flags.timeout, "timeout", "", `How log before the run times out. Specified in a sequence of decimal numbers, each with optional fraction and a unit suffix, such as "300s", "1.5h" or "2h45m". Valid time units are "s", "m", "h"`)
 
 	return cmd
 }
 
+// runTableColumns are the columns of the table output format for runs.
+var runTableColumns = []tableColumn[model.Run]{
+	{"ID", func(r model.Run) string { return strconv.FormatInt(r.Id, 10) }},
+	{"STATUS", func(r model.Run) string {
+		if r.Status == nil {
+			return ""
+		}
+		return r.Status.String()
+	}},
+	{"CODESPEC", func(r model.Run) string { return formatCodespecRef(r.Job.Codespec) }},
+	{"CREATED", func(r model.Run) string { return formatTableTime(&r.CreatedAt) }},
+	{"FINISHED", func(r model.Run) string { return formatTableTime(r.FinishedAt) }},
+	{"REASON", func(r model.Run) string { return r.StatusReason }},
+}
+
+// formatCodespecRef returns the name and version of the codespec of a run, or "(inline)"
+// if the codespec was given inline.
+func formatCodespecRef(ref model.CodespecRef) string {
+	switch {
+	case ref.Named != nil:
+		return string(*ref.Named)
+	case ref.Inline != nil && ref.Inline.Name != "":
+		return fmt.Sprintf("%s/versions/%d", ref.Inline.Name, ref.Inline.Version)
+	default:
+		return "(inline)"
+	}
+}
+
 func newRunShowCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:                   "show ID",
+	outputFormat := ""
+
+	cmd := &cobra.Command{
+		Use:                   "show ID [--output FORMAT]",
 		Aliases:               []string{"get"},
 		Short:                 "Show the details of a run",
 		Long:                  `Show the details of a run.`,
 		DisableFlagsInUseLine: true,
 		Args:                  exactlyOneArg("run name"),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			run := model.Run{}
-			_, err := controlplane.InvokeRequest(cmd.Context(), http.MethodGet, fmt.Sprintf("v1/runs/%s", args[0]), nil, &run)
+			printer, err := newOutputPrinter(outputFormat, runTableColumns)
 			if err != nil {
 				return err
 			}
 
-			formattedRun, err := json.MarshalIndent(run, "", "  ")
+			run := model.Run{}
+			_, err = controlplane.InvokeRequest(cmd.Context(), http.MethodGet, fmt.Sprintf("v1/runs/%s", args[0]), nil, &run)
 			if err != nil {
 				return err
 			}
 
-			fmt.Println(string(formattedRun))
-			return nil
+			return printer.printItem(run)
 		},
 	}
+
+	addOutputFlag(cmd, &outputFormat)
+
+	return cmd
 }
 
 func newRunWatchCommand() *cobra.Command {
 	var flags struct {
 		fullResource bool
+		output       string
 	}
 
 	cmd := &cobra.Command{
-		Use:                   "watch ID",
+		Use:                   "watch ID [--full-resource] [--output FORMAT]",
 		Aliases:               []string{"get"},
 		Short:                 "Watch the status changes of a run",
 		Long:                  "Watch the status changes of a run",
@@ -512,81 +676,262 @@ func newRunWatchCommand() *cobra.Command {
 				return err
 			}
 
-			consecutiveErrors := 0
-		start:
-			eventChan, errChan := watchRun(cmd.Context(), runId)
+			printer, err := newOutputPrinter(flags.output, runTableColumns)
+			if err != nil {
+				return err
+			}
+			if !flags.fullResource {
+				printer.transform = func(r model.Run) any { return r.RunMetadata }
+			}
+
+			_, err = waitForRun(cmd.Context(), runId, printer.printWatchEvent)
+			if err == errNotFound {
+				return errors.New("run not found")
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.fullResource, "full-resource", false, "Display the full resource instead of just the system fields")
+	cmd.Flags().StringVarP(&flags.output, "output", "o", "json", outputFlagUsage+" Each status change is written as a JSON line, a YAML document, or a table row.")
+	return cmd
+}
+
+// The exit codes of `tyger run wait`. Other errors, such as the run not being found, exit with 1.
+const (
+	runWaitFailedExitCode   = 2
+	runWaitCanceledExitCode = 3
+	runWaitTimeoutExitCode  = 4
+)
+
+func newRunWaitCommand() *cobra.Command {
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "wait ID [--timeout DURATION]",
+		Short: "Wait for a run to complete",
+		Long: fmt.Sprintf(`Wait for a run to complete and exit with a code that reflects its final status:
+
+  0  the run succeeded
+  %d  the run failed
+  %d  the run was canceled
+  %d  the run did not complete within the timeout
+
+When the run does not succeed, its status reason is written to stderr.`, runWaitFailedExitCode, runWaitCanceledExitCode, runWaitTimeoutExitCode),
+		DisableFlagsInUseLine: true,
+		Args:                  exactlyOneArg("run ID"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runId, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			run, err := waitForRun(ctx, runId, nil)
+			if err != nil {
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return &ExitCodeError{Code: runWaitTimeoutExitCode, Message: fmt.Sprintf("run %d did not complete within %s", runId, timeout)}
+				}
+				if err == errNotFound {
+					return errors.New("run not found")
+				}
+				return err
+			}
+
+			message := fmt.Sprintf("run %d %s", runId, strings.ToLower(run.Status.String()))
+			if run.StatusReason != "" {
+				message = fmt.Sprintf("%s: %s", message, run.StatusReason)
+			}
+
+			switch *run.Status {
+			case model.Failed:
+				return &ExitCodeError{Code: runWaitFailedExitCode, Message: message}
+			case model.Canceled:
+				return &ExitCodeError{Code: runWaitCanceledExitCode, Message: message}
+			default:
+				return nil
+			}
+		},
+	}
+
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "The maximum time to wait for the run to complete, for example 30m or 2h. By default, there is no limit.")
+	return cmd
+}
+
+// maxWatchReconnects is the number of times in a row that waitForRun reconnects to the
+// watch of a run without receiving an event before giving up.
+const maxWatchReconnects = 5
+
+// waitForRun watches a run until it has completed and returns its final state. onEvent, if not nil,
+// is called with each event, and an error it returns stops the watch. If the stream ends before the
+// run has completed or fails, the watch is restarted after a delay that doubles with each attempt,
+// unless it has already been restarted maxWatchReconnects times without receiving an event.
+// The last event received is returned together with any error.
+func waitForRun(ctx context.Context, runId int64, onEvent func(model.Run) error) (model.Run, error) {
+	var lastEvent model.Run
+	reconnects := 0
+	for {
+		// done is true when the watch must not be restarted
+		done, err := func() (done bool, err error) {
+			eventChan, errChan := watchRun(ctx, runId)
 			for {
 				select {
 				case err := <-errChan:
-					if err == errNotFound {
-						return errors.New("run not found")
-					}
-
-					consecutiveErrors++
-					if consecutiveErrors > 1 {
-						return err
-					}
-
-					log.Error().Err(err).Msg("error watching run")
-					goto start
+					return err == errNotFound || ctx.Err() != nil, err
 				case event, ok := <-eventChan:
 					if !ok {
-						return nil
+						return false, fmt.Errorf("the watch of run %d ended before the run completed", runId)
 					}
-					consecutiveErrors = 0
-					var valueToPrint any = event
-					if !flags.fullResource {
-						valueToPrint = event.RunMetadata
+					reconnects = 0
+					lastEvent = event
+
+					if onEvent != nil {
+						if err := onEvent(event); err != nil {
+							return true, err
+						}
 					}
 
-					bytes, err := json.Marshal(valueToPrint)
-					if err != nil {
-						return err
+					if event.Status != nil {
+						switch *event.Status {
+						case model.Succeeded, model.Failed, model.Canceled:
+							return true, nil
+						}
 					}
-					fmt.Println(string(bytes))
 				}
 			}
-		},
+		}()
+
+		if done || reconnects >= maxWatchReconnects {
+			return lastEvent, err
+		}
+
+		delay := time.Second << reconnects
+		reconnects++
+		log.Warn().Err(err).Int64("runId", runId).Dur("delay", delay).Msg("Reconnecting to the watch of the run")
+		select {
+		case <-ctx.Done():
+			return lastEvent, ctx.Err()
+		case <-time.After(delay):
+		}
 	}
+}
 
-	cmd.Flags().BoolVar(&flags.fullResource, "full-resource", false, "Display the full resource instead of just the system fields")
-	return cmd
+// runFilterFlags are the flags that select the runs returned by v1/runs.
+type runFilterFlags struct {
+	since           string
+	status          string
+	codespec        string
+	codespecVersion string
+	tags            map[string]string
+	finishedBefore  string
+	finishedAfter   string
+}
+
+const runFilterFlagsUsage = "[--since DATE/TIME] [--status STATUS] [--codespec NAME [--version VERSION]] [--tag key=value ...] [--finished-after DATE/TIME] [--finished-before DATE/TIME]"
+
+func (f *runFilterFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.since, "since", "s", "", "Results before this datetime (specified in local time) are not included")
+	cmd.Flags().StringVar(&f.status, "status", "", "Only include runs with this status. One of pending, running, failed, succeeded, canceling, or canceled.")
+	cmd.Flags().StringVarP(&f.codespec, "codespec", "c", "", "Only include runs whose job was created from this codespec")
+	cmd.Flags().StringVar(&f.codespecVersion, "version", "", "Only include runs whose job was created from this version of the codespec given with --codespec")
+	cmd.Flags().StringToStringVar(&f.tags, "tag", nil, "Only include runs whose job has this key-value tag. Can be specified multiple times.")
+	cmd.Flags().StringVar(&f.finishedBefore, "finished-before", "", "Only include runs that finished before this datetime (specified in local time)")
+	cmd.Flags().StringVar(&f.finishedAfter, "finished-after", "", "Only include runs that finished after this datetime (specified in local time)")
+}
+
+// queryOptions returns the query parameters of v1/runs for the flags.
+func (f *runFilterFlags) queryOptions() (url.Values, error) {
+	queryOptions := url.Values{}
+
+	now := time.Now()
+	addTimeOption := func(flagName string, value string, parameterName string) error {
+		if value == "" {
+			return nil
+		}
+		tm, err := timeparser.ParseTimeStr(value, &now)
+		if err != nil {
+			return fmt.Errorf("failed to parse --%s time %s", flagName, value)
+		}
+		queryOptions.Add(parameterName, tm.UTC().Format(time.RFC3339Nano))
+		return nil
+	}
+
+	if err := addTimeOption("since", f.since, "since"); err != nil {
+		return nil, err
+	}
+	if err := addTimeOption("finished-before", f.finishedBefore, "finishedBefore"); err != nil {
+		return nil, err
+	}
+	if err := addTimeOption("finished-after", f.finishedAfter, "finishedAfter"); err != nil {
+		return nil, err
+	}
+
+	if f.status != "" {
+		queryOptions.Add("status", f.status)
+	}
+
+	if f.codespec != "" {
+		queryOptions.Add("codespec", f.codespec)
+	}
+	if f.codespecVersion != "" {
+		if f.codespec == "" {
+			return nil, errors.New("--version can only be used together with --codespec")
+		}
+		queryOptions.Add("codespecVersion", f.codespecVersion)
+	}
+
+	for name, value := range f.tags {
+		queryOptions.Add(fmt.Sprintf("tag.%s", name), value)
+	}
+
+	return queryOptions, nil
 }
 
 func newRunListCommand() *cobra.Command {
 	var flags struct {
-		limit int
-		since string
+		runFilterFlags
+		limit  int
+		output string
 	}
 
 	cmd := &cobra.Command{
-		Use:                   "list [--since DATE/TIME] [--limit COUNT]",
-		Short:                 "List runs",
-		Long:                  `List runs. Runs are sorted by descending created time.`,
+		Use:   "list " + runFilterFlagsUsage + " [--limit COUNT] [--output FORMAT]",
+		Short: "List runs",
+		Long: `List runs. Runs are sorted by descending created time.
+
+Runs can be filtered by their status, by the job codespec they were created from, by the tags of their job, and by the time they finished.`,
 		DisableFlagsInUseLine: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			queryOptions := url.Values{}
+			printer, err := newOutputPrinter(flags.output, runTableColumns)
+			if err != nil {
+				return err
+			}
+
+			queryOptions, err := flags.queryOptions()
+			if err != nil {
+				return err
+			}
+
 			if flags.limit > 0 {
 				queryOptions.Add("limit", strconv.Itoa(flags.limit))
 			} else {
 				flags.limit = math.MaxInt
 			}
-			if flags.since != "" {
-				now := time.Now()
-				tm, err := timeparser.ParseTimeStr(flags.since, &now)
-				if err != nil {
-					return fmt.Errorf("failed to parse time %s", flags.since)
-				}
-				queryOptions.Add("since", tm.UTC().Format(time.RFC3339Nano))
-			}
 
 			relativeUri := fmt.Sprintf("v1/runs?%s", queryOptions.Encode())
-			return controlplane.InvokePageRequests[model.Run](cmd.Context(), relativeUri, flags.limit, !cmd.Flags().Lookup("limit").Changed)
+			return printer.printPages(cmd.Context(), relativeUri, flags.limit, !cmd.Flags().Lookup("limit").Changed)
 		},
 	}
 
-	cmd.Flags().StringVarP(&flags.since, "since", "s", "", "Results before this datetime (specified in local time) are not included")
+	flags.addFlags(cmd)
 	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 1000, "The maximum number of runs to list. Default 1000")
+	addOutputFlag(cmd, &flags.output)
 
 	return cmd
 }
@@ -739,13 +1084,19 @@ func followLogs(body io.Reader, includeTimestamps bool, outputSink io.Writer) (l
 }
 
 func watchRun(ctx context.Context, runId int64) (<-chan model.Run, <-chan error) {
-	runEventChan := make(chan model.Run)
+	return watchJsonLines[model.Run](ctx, fmt.Sprintf("v1/runs/%d?watch=true", runId))
+}
+
+// watchJsonLines makes a request whose response is a stream of JSON lines
+// and sends each item on the returned channel until the stream ends.
+func watchJsonLines[T any](ctx context.Context, relativeUri string) (<-chan T, <-chan error) {
+	itemChan := make(chan T)
 	errChan := make(chan error)
 
 	go func() {
-		defer close(runEventChan)
+		defer close(itemChan)
 
-		resp, err := controlplane.InvokeRequest(ctx, http.MethodGet, fmt.Sprintf("v1/runs/%d?watch=true", runId), nil, nil)
+		resp, err := controlplane.InvokeRequest(ctx, http.MethodGet, relativeUri, nil, nil)
 		if resp != nil && resp.StatusCode == http.StatusNotFound {
 			errChan <- errNotFound
 			return
@@ -774,16 +1125,15 @@ func watchRun(ctx context.Context, runId int64) (<-chan model.Run, <-chan error)
 				return
 			}
 
-			run := model.Run{}
-
-			if err := json.Unmarshal([]byte(line), &run); err != nil {
+			var item T
+			if err := json.Unmarshal([]byte(line), &item); err != nil {
 				errChan <- err
 				return
 			}
 
-			runEventChan <- run
+			itemChan <- item
 		}
 	}()
 
-	return runEventChan, errChan
+	return itemChan, errChan
 }
@@ -0,0 +1,163 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/microsoft/tyger/cli/internal/controlplane"
+	"github.com/microsoft/tyger/cli/internal/controlplane/model"
+	"github.com/spf13/cobra"
+)
+
+func NewSecretCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "secret",
+		Aliases: []string{"secrets"},
+		Short:   "Manage secrets",
+		Long: `Manage secrets. A secret holds one or more key-value pairs that codespecs can reference
+in their environment variables with {secretRef: name/key}. Secret values are stored by the server
+and are never returned by it.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			return errors.New("a command is required")
+		},
+	}
+
+	cmd.AddCommand(newSecretCreateCommand())
+	cmd.AddCommand(newSecretListCommand())
+	cmd.AddCommand(newSecretDeleteCommand())
+
+	return cmd
+}
+
+func newSecretCreateCommand() *cobra.Command {
+	var flags struct {
+		literals map[string]string
+		files    map[string]string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "create NAME [[--from-literal KEY=VALUE] ...] [[--from-file KEY=PATH] ...]",
+		Short: "Create or update a secret",
+		Long: `Create a secret, or replace the keys and values of an existing secret. Writes the secret name to stdout on success.
+
+Running containers keep the values they started with. Runs created afterwards get the new values.`,
+		DisableFlagsInUseLine: true,
+		Args:                  exactlyOneArg("secret name"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data := make(map[string]string, len(flags.literals)+len(flags.files))
+			for k, v := range flags.literals {
+				data[k] = v
+			}
+
+			for k, path := range flags.files {
+				if _, ok := data[k]; ok {
+					return fmt.Errorf("the key '%s' is given more than once", k)
+				}
+
+				bytes, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to read file %s: %w", path, err)
+				}
+				data[k] = string(bytes)
+			}
+
+			if len(data) == 0 {
+				return errors.New("at least one key must be given with --from-literal or --from-file")
+			}
+
+			secret := model.Secret{}
+			_, err := controlplane.InvokeRequest(cmd.Context(), http.MethodPut, fmt.Sprintf("v1/secrets/%s", args[0]), model.SecretData{Data: data}, &secret)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(secret.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringToStringVar(&flags.literals, "from-literal", nil, "A key and its value in the form KEY=VALUE. Can be specified multiple times.")
+	cmd.Flags().StringToStringVar(&flags.files, "from-file", nil, "A key and a file with its value in the form KEY=PATH. Can be specified multiple times.")
+
+	return cmd
+}
+
+// secretTableColumns are the columns of the table output format for secrets.
+var secretTableColumns = []tableColumn[model.Secret]{
+	{"NAME", func(s model.Secret) string { return s.Name }},
+	{"KEYS", func(s model.Secret) string { return strings.Join(s.Keys, ",") }},
+	{"CREATED", func(s model.Secret) string { return formatTableTime(s.CreatedAt) }},
+}
+
+func newSecretListCommand() *cobra.Command {
+	var flags struct {
+		limit  int
+		output string
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "list [--limit COUNT] [--output FORMAT]",
+		Short:                 "List secrets",
+		Long:                  `List secrets sorted by name. Only the names of the keys are shown, never their values.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(flags.output, secretTableColumns)
+			if err != nil {
+				return err
+			}
+
+			queryOptions := url.Values{}
+			if flags.limit > 0 {
+				queryOptions.Add("limit", strconv.Itoa(flags.limit))
+			} else {
+				flags.limit = math.MaxInt
+			}
+
+			relativeUri := fmt.Sprintf("v1/secrets?%s", queryOptions.Encode())
+			return printer.printPages(cmd.Context(), relativeUri, flags.limit, !cmd.Flags().Lookup("limit").Changed)
+		},
+	}
+
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 1000, "The maximum number of secrets to list. Default 1000")
+	addOutputFlag(cmd, &flags.output)
+
+	return cmd
+}
+
+func newSecretDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete NAME ...",
+		Short: "Delete secrets",
+		Long: `Delete secrets. Writes the names of the deleted secrets to stdout.
+
+Runs whose codespecs reference a deleted secret will fail to start.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range args {
+				_, err := controlplane.InvokeRequest(cmd.Context(), http.MethodDelete, fmt.Sprintf("v1/secrets/%s", name), nil, nil)
+				if err != nil {
+					return err
+				}
+
+				fmt.Println(name)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	templatePlaceholderRegex = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+	// Matches `name` or `name | default "value"`
+	templateParameterRegex = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?:\|\s*default\s+("(?:[^"\\]|\\.)*")\s*)?$`)
+
+	// Stands in for a placeholder while the file is parsed
+	templateTokenRegex = regexp.MustCompile(`__tyger_placeholder_(\d+)__`)
+)
+
+// parseTemplateValues parses NAME=VALUE entries given with --set. Only the first '=' separates
+// the name from the value, so values can contain '=' as well as commas.
+func parseTemplateValues(entries []string) (map[string]string, error) {
+	values := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("--set values must be in the form NAME=VALUE, got '%s'", entry)
+		}
+		values[name] = value
+	}
+
+	return values, nil
+}
+
+// expandTemplate replaces the {{ name }} and {{ name | default "value" }} placeholders in a
+// specification file with the given values. A parameter without a default is required.
+// Text between double braces that is not in one of these forms is left as it is.
+// All placeholders that cannot be resolved are reported together, and so are values given
+// for parameters that the file does not use.
+//
+// Values are substituted into the parsed YAML document rather than into its text, so a value
+// is always a single scalar and cannot change the structure of the document.
+func expandTemplate(content []byte, values map[string]string) ([]byte, error) {
+	usedParameters := make(map[string]bool)
+	problems := make([]string, 0)
+	substitutions := make([]string, 0)
+
+	expanded := &bytes.Buffer{}
+	previousEnd := 0
+	for _, loc := range templatePlaceholderRegex.FindAllSubmatchIndex(content, -1) {
+		expanded.Write(content[previousEnd:loc[0]])
+		previousEnd = loc[1]
+
+		placeholder := content[loc[0]:loc[1]]
+		line := bytes.Count(content[:loc[0]], []byte("\n")) + 1
+
+		match := templateParameterRegex.FindSubmatch(content[loc[2]:loc[3]])
+		if match == nil {
+			// Not a placeholder, for example a Go template in a command line
+			expanded.Write(placeholder)
+			continue
+		}
+
+		name := string(match[1])
+		usedParameters[name] = true
+		if value, ok := values[name]; ok {
+			expanded.WriteString(templateToken(&substitutions, value))
+			continue
+		}
+
+		if match[2] == nil {
+			problems = append(problems, fmt.Sprintf("line %d: the required parameter '%s' has no value. Provide one with --set %s=VALUE", line, name, name))
+			continue
+		}
+
+		defaultValue, err := strconv.Unquote(string(match[2]))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: invalid default value for parameter '%s': %v", line, name, err))
+			continue
+		}
+		expanded.WriteString(templateToken(&substitutions, defaultValue))
+	}
+	expanded.Write(content[previousEnd:])
+
+	unusedParameters := make([]string, 0)
+	for name := range values {
+		if !usedParameters[name] {
+			unusedParameters = append(unusedParameters, name)
+		}
+	}
+	sort.Strings(unusedParameters)
+	for _, name := range unusedParameters {
+		problems = append(problems, fmt.Sprintf("the parameter '%s' given with --set is not used by the file", name))
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("unable to resolve the template:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	if len(substitutions) == 0 {
+		return content, nil
+	}
+
+	return substituteTemplateTokens(expanded.Bytes(), substitutions)
+}
+
+// templateToken records a value to substitute and returns the token that stands in for it.
+func templateToken(substitutions *[]string, value string) string {
+	*substitutions = append(*substitutions, value)
+	return fmt.Sprintf("__tyger_placeholder_%d__", len(*substitutions)-1)
+}
+
+// substituteTemplateTokens parses a YAML document in which placeholders have been replaced by tokens
+// and replaces the tokens in its scalars with their values. An unquoted scalar that is only a placeholder
+// takes the type of its value, so that numbers and booleans can be given, but never becomes a mapping
+// or a sequence. Everywhere else, values are substituted as text.
+func substituteTemplateTokens(content []byte, substitutions []string) ([]byte, error) {
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	var substitute func(node *yaml.Node)
+	substitute = func(node *yaml.Node) {
+		if node.Kind == yaml.ScalarNode && templateTokenRegex.MatchString(node.Value) {
+			onlyToken := templateTokenRegex.FindString(node.Value) == node.Value
+			node.Value = templateTokenRegex.ReplaceAllStringFunc(node.Value, func(token string) string {
+				index, _ := strconv.Atoi(templateTokenRegex.FindStringSubmatch(token)[1])
+				return substitutions[index]
+			})
+
+			if onlyToken && node.Style == 0 {
+				node.Tag = templateValueTag(node.Value)
+			} else {
+				node.Tag = "!!str"
+			}
+		}
+
+		for _, child := range node.Content {
+			substitute(child)
+		}
+	}
+	substitute(&document)
+
+	return yaml.Marshal(&document)
+}
+
+// templateValueTag returns the tag of the number or boolean that an unquoted YAML value would be,
+// and the string tag for any other value.
+func templateValueTag(value string) string {
+	node := yaml.Node{}
+	if err := yaml.Unmarshal([]byte(value), &node); err == nil && len(node.Content) == 1 {
+		if scalar := node.Content[0]; scalar.Kind == yaml.ScalarNode && scalar.Style == 0 && scalar.Value == value {
+			switch scalar.Tag {
+			case "!!int", "!!float", "!!bool":
+				return scalar.Tag
+			}
+		}
+	}
+
+	return "!!str"
+}
@@ -0,0 +1,207 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/microsoft/tyger/cli/internal/controlplane"
+	"github.com/microsoft/tyger/cli/internal/controlplane/model"
+	"github.com/spf13/cobra"
+)
+
+// runUsageSummary is the resource usage of a run or the total usage of a group of runs.
+type runUsageSummary struct {
+	Group                string  `json:"group"`
+	Runs                 int     `json:"runs"`
+	WallTimeSeconds      float64 `json:"wallTimeSeconds"`
+	CpuSeconds           float64 `json:"cpuSeconds"`
+	GpuSeconds           float64 `json:"gpuSeconds"`
+	MemoryHighWaterBytes int64   `json:"memoryHighWaterBytes"`
+	InputBufferBytes     int64   `json:"inputBufferBytes"`
+	OutputBufferBytes    int64   `json:"outputBufferBytes"`
+}
+
+func (s *runUsageSummary) add(run model.Run) {
+	s.Runs++
+	if run.Usage == nil {
+		return
+	}
+
+	for _, r := range run.Usage.Replicas {
+		if r.WallTimeSeconds != nil {
+			s.WallTimeSeconds += *r.WallTimeSeconds
+		}
+		if r.CpuSeconds != nil {
+			s.CpuSeconds += *r.CpuSeconds
+		}
+		if r.GpuSeconds != nil {
+			s.GpuSeconds += *r.GpuSeconds
+		}
+		if r.MemoryHighWaterBytes != nil {
+			s.MemoryHighWaterBytes = max(s.MemoryHighWaterBytes, *r.MemoryHighWaterBytes)
+		}
+	}
+
+	for _, b := range run.Usage.Buffers {
+		if b.SizeBytes == nil {
+			continue
+		}
+		if b.Direction == "output" {
+			s.OutputBufferBytes += *b.SizeBytes
+		} else {
+			s.InputBufferBytes += *b.SizeBytes
+		}
+	}
+}
+
+var runUsageTableColumns = []tableColumn[runUsageSummary]{
+	{"GROUP", func(s runUsageSummary) string { return s.Group }},
+	{"RUNS", func(s runUsageSummary) string { return strconv.Itoa(s.Runs) }},
+	{"WALL TIME", func(s runUsageSummary) string { return formatUsageSeconds(s.WallTimeSeconds) }},
+	{"CPU TIME", func(s runUsageSummary) string { return formatUsageSeconds(s.CpuSeconds) }},
+	{"GPU TIME", func(s runUsageSummary) string { return formatUsageSeconds(s.GpuSeconds) }},
+	{"PEAK MEMORY", func(s runUsageSummary) string { return units.Base2Bytes(s.MemoryHighWaterBytes).String() }},
+	{"INPUT BUFFERS", func(s runUsageSummary) string { return units.Base2Bytes(s.InputBufferBytes).String() }},
+	{"OUTPUT BUFFERS", func(s runUsageSummary) string { return units.Base2Bytes(s.OutputBufferBytes).String() }},
+}
+
+func formatUsageSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
+
+func newRunUsageCommand() *cobra.Command {
+	var flags struct {
+		runFilterFlags
+		groupBy string
+		limit   int
+		output  string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "usage { ID ... | " + runFilterFlagsUsage + " { --group-by codespec|tag:KEY | --limit COUNT } } [--output FORMAT]",
+		Short: "Show the resources consumed by runs",
+		Long: `Show the resources consumed by runs: the wall time and CPU time of their replicas, summed over replicas,
+their GPU time, their peak memory usage, and the sizes of their input and output buffers.
+
+Either give the IDs of runs, or select runs with the same filters as 'tyger run list'. The usage is shown for each run,
+or, with --group-by, totaled for each codespec or for each value of a tag. Totals include every matching run,
+so --limit only applies when the usage of each run is shown.
+
+The CPU time and memory usage of the main container are measured periodically while runs execute, so the peak memory
+is the highest sampled value and can miss short spikes. Buffer sizes are the stored sizes of the buffers, not the bytes
+the runs transferred. The wall time, GPU time and buffer sizes are recorded when runs complete. Use 'tyger run show' to see the usage of each replica and buffer of a run.`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printer, err := newOutputPrinter(flags.output, runUsageTableColumns)
+			if err != nil {
+				return err
+			}
+
+			groupKey := func(run model.Run) string { return strconv.FormatInt(run.Id, 10) }
+			switch {
+			case flags.groupBy == "":
+			case flags.groupBy == "codespec":
+				groupKey = func(run model.Run) string { return codespecName(run.Job.Codespec) }
+			case strings.HasPrefix(flags.groupBy, "tag:") && len(flags.groupBy) > len("tag:"):
+				tagKey := strings.TrimPrefix(flags.groupBy, "tag:")
+				groupKey = func(run model.Run) string {
+					if value, ok := run.Job.Tags[tagKey]; ok {
+						return value
+					}
+					return "(none)"
+				}
+			default:
+				return fmt.Errorf("invalid --group-by value '%s'. It must be 'codespec' or 'tag:KEY'", flags.groupBy)
+			}
+
+			var runs []model.Run
+			if len(args) > 0 {
+				for _, name := range []string{"since", "status", "codespec", "version", "tag", "finished-before", "finished-after", "limit"} {
+					if cmd.Flags().Changed(name) {
+						return fmt.Errorf("run IDs cannot be combined with --%s", name)
+					}
+				}
+
+				for _, id := range args {
+					run := model.Run{}
+					if _, err := controlplane.InvokeRequest(cmd.Context(), http.MethodGet, fmt.Sprintf("v1/runs/%s", id), nil, &run); err != nil {
+						return err
+					}
+					runs = append(runs, run)
+				}
+			} else {
+				queryOptions, err := flags.queryOptions()
+				if err != nil {
+					return err
+				}
+
+				if flags.groupBy != "" {
+					// Totals would be wrong if only some of the runs were included
+					if cmd.Flags().Changed("limit") {
+						return errors.New("--limit cannot be combined with --group-by, since totals include every matching run")
+					}
+					flags.limit = math.MaxInt
+					queryOptions.Add("limit", "200")
+				} else if flags.limit > 0 {
+					queryOptions.Add("limit", strconv.Itoa(flags.limit))
+				} else {
+					flags.limit = math.MaxInt
+				}
+
+				runs, err = controlplane.GetPages[model.Run](cmd.Context(), fmt.Sprintf("v1/runs?%s", queryOptions.Encode()), flags.limit, !cmd.Flags().Lookup("limit").Changed)
+				if err != nil {
+					return err
+				}
+			}
+
+			summaries := make([]runUsageSummary, 0)
+			summariesByGroup := make(map[string]int)
+			for _, run := range runs {
+				group := groupKey(run)
+				i, ok := summariesByGroup[group]
+				if !ok {
+					i = len(summaries)
+					summariesByGroup[group] = i
+					summaries = append(summaries, runUsageSummary{Group: group})
+				}
+				summaries[i].add(run)
+			}
+
+			if flags.groupBy != "" {
+				sort.Slice(summaries, func(i, j int) bool { return summaries[i].Group < summaries[j].Group })
+			}
+
+			return printer.printList(summaries)
+		},
+	}
+
+	flags.addFlags(cmd)
+	cmd.Flags().StringVar(&flags.groupBy, "group-by", "", "Total the usage for each codespec ('codespec') or for each value of a job tag ('tag:KEY')")
+	cmd.Flags().IntVarP(&flags.limit, "limit", "l", 1000, "The maximum number of runs to show when --group-by is not given. Default 1000")
+	addOutputFlag(cmd, &flags.output)
+
+	return cmd
+}
+
+// codespecName returns the name of the codespec of a run, or "(inline)" if the codespec was given inline.
+func codespecName(ref model.CodespecRef) string {
+	switch {
+	case ref.Named != nil:
+		name, _, _ := strings.Cut(string(*ref.Named), "/versions/")
+		return name
+	case ref.Inline != nil && ref.Inline.Name != "":
+		return ref.Inline.Name
+	default:
+		return "(inline)"
+	}
+}
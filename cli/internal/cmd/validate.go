@@ -0,0 +1,131 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/microsoft/tyger/cli/internal/controlplane"
+	"github.com/microsoft/tyger/cli/internal/controlplane/model"
+	"github.com/microsoft/tyger/cli/internal/registry"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+func newCodespecValidateCommand() *cobra.Command {
+	var flags struct {
+		specFile string
+		cluster  string
+		nodePool string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "validate --file YAML_SPEC [--cluster CLUSTER] [--node-pool NODEPOOL]",
+		Short: "Check a codespec for problems without creating it",
+		Long: `Check a codespec specification file for problems without creating it. The server checks the codespec
+against the rules for its kind, the secrets and datasets it references, and the node pools of the cluster
+that runs would target, including whether its CPU, memory, and GPU requests fit on any of their nodes.
+The image is looked up in its container registry. All the problems found are reported at once.
+
+The image is looked up from this machine with the credentials of the local Docker configuration, if any,
+not with the identity that the cluster pulls images with. A registry that denies access here may still
+allow the cluster to pull the image, and the other way around.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.specFile == "" {
+				return errors.New("a codespec specification file must be given with --file")
+			}
+
+			bytes, err := os.ReadFile(flags.specFile)
+			if err != nil {
+				return fmt.Errorf("failed to read file %s: %w", flags.specFile, err)
+			}
+
+			codespec := model.Codespec{}
+			if err := yaml.UnmarshalStrict(bytes, &codespec); err != nil {
+				return fmt.Errorf("failed to parse file %s: %w", flags.specFile, err)
+			}
+
+			if codespec.Kind == "" {
+				codespec.Kind = "job"
+			}
+			codespec.Kind = strings.ToLower(codespec.Kind)
+
+			queryOptions := url.Values{}
+			if flags.cluster != "" {
+				queryOptions.Add("cluster", flags.cluster)
+			}
+			if flags.nodePool != "" {
+				queryOptions.Add("nodePool", flags.nodePool)
+			}
+
+			relativeUri := fmt.Sprintf("v1/codespecs/validate?%s", queryOptions.Encode())
+
+			result := model.CodespecValidationResult{}
+			if _, err := controlplane.InvokeRequest(cmd.Context(), http.MethodPost, relativeUri, codespec, &result); err != nil {
+				return err
+			}
+
+			problems := result.Problems
+			if codespec.Image != "" {
+				problem, err := checkImageExists(cmd.Context(), codespec.Image)
+				if err != nil {
+					log.Warn().Err(err).Str("image", codespec.Image).Msg("Unable to check that the image can be pulled with the local Docker credentials")
+				} else if problem != "" {
+					problems = append(problems, model.CodespecProblem{Field: "image", Message: problem})
+				}
+			}
+
+			if len(problems) == 0 {
+				fmt.Println("The codespec is valid.")
+				return nil
+			}
+
+			sb := strings.Builder{}
+			if len(problems) == 1 {
+				sb.WriteString("the codespec has 1 problem:")
+			} else {
+				fmt.Fprintf(&sb, "the codespec has %d problems:", len(problems))
+			}
+			for _, p := range problems {
+				fmt.Fprintf(&sb, "\n  %s: %s", p.Field, p.Message)
+			}
+
+			return errors.New(sb.String())
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.specFile, "file", "f", "", "A YAML file with the codespec specification (required)")
+	cmd.Flags().StringVar(&flags.cluster, "cluster", "", "The cluster that runs would target. Defaults to the default cluster.")
+	cmd.Flags().StringVar(&flags.nodePool, "node-pool", "", "The node pool that runs would target")
+
+	return cmd
+}
+
+// checkImageExists looks up the manifest of an image in its registry using the local Docker credentials.
+// It returns a problem if the image reference is invalid or the image does not exist, and an error if
+// the registry could not be queried, for example because it denied access.
+func checkImageExists(ctx context.Context, image string) (problem string, err error) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return fmt.Sprintf("the image reference '%s' is invalid: %v", image, err), nil
+	}
+
+	exists, err := registry.ImageExists(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return fmt.Sprintf("the image '%s' was not found in the registry %s with the local Docker credentials", image, ref.Domain), nil
+	}
+	return "", nil
+}
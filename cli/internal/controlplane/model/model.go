@@ -6,6 +6,7 @@ package model
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -20,10 +21,16 @@ type ServiceMetadata struct {
 }
 
 type Buffer struct {
-	Id        string            `json:"id"`
-	ETag      string            `json:"etag"`
-	CreatedAt time.Time         `json:"createdAt"`
-	Tags      map[string]string `json:"tags,omitempty"`
+	Id         string            `json:"id"`
+	ETag       string            `json:"etag"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	TtlSeconds *int              `json:"ttlSeconds,omitempty"`
+	ExpiresAt  *time.Time        `json:"expiresAt,omitempty"`
+	DeletedAt  *time.Time        `json:"deletedAt,omitempty"`
+	Status     string            `json:"status,omitempty"`
+	ByteCount  *int64            `json:"byteCount,omitempty"`
+	BlobCount  *int64            `json:"blobCount,omitempty"`
 }
 
 type BufferAccess struct {
@@ -49,15 +56,59 @@ type CodespecResources struct {
 type Codespec struct {
 	Kind             string `json:"kind"`
 	CodespecMetadata `json:",inline"`
-	Buffers          *BufferParameters  `json:"buffers,omitempty"`
-	Image            string             `json:"image"`
-	Command          []string           `json:"command,omitempty"`
-	Args             []string           `json:"args,omitempty"`
-	WorkingDir       string             `json:"workingDir,omitempty"`
-	Env              map[string]string  `json:"env,omitempty"`
-	Resources        *CodespecResources `json:"resources,omitempty"`
-	MaxReplicas      *int               `json:"maxReplicas,omitempty"`
-	Endpoints        map[string]int     `json:"endpoints,omitempty"`
+	Buffers          *BufferParameters   `json:"buffers,omitempty"`
+	Image            string              `json:"image"`
+	Command          []string            `json:"command,omitempty"`
+	Args             []string            `json:"args,omitempty"`
+	WorkingDir       string              `json:"workingDir,omitempty"`
+	Env              map[string]EnvValue `json:"env,omitempty"`
+	Resources        *CodespecResources  `json:"resources,omitempty"`
+	MaxReplicas      *int                `json:"maxReplicas,omitempty"`
+	Mounts           []DatasetMount      `json:"mounts,omitempty"`
+	Endpoints        map[string]int      `json:"endpoints,omitempty"`
+}
+
+// DatasetMount mounts a version of a dataset read-only into the container at Path.
+// Without a version, the latest version when the run is created is used.
+type DatasetMount struct {
+	Dataset string `json:"dataset"`
+	Version *int   `json:"version,omitempty"`
+	Path    string `json:"path"`
+}
+
+// EnvValue is the value of an environment variable of a codespec. It is either a literal value
+// or a reference to a key of a secret in the form name/key, which is written as {"secretRef": "name/key"}.
+type EnvValue struct {
+	Value     string
+	SecretRef string
+}
+
+func (v EnvValue) MarshalJSON() ([]byte, error) {
+	if v.SecretRef != "" {
+		return json.Marshal(struct {
+			SecretRef string `json:"secretRef"`
+		}{v.SecretRef})
+	}
+	return json.Marshal(v.Value)
+}
+
+func (v *EnvValue) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &v.Value); err == nil {
+		v.SecretRef = ""
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	secretRef := struct {
+		SecretRef string `json:"secretRef"`
+	}{}
+	if err := decoder.Decode(&secretRef); err != nil || secretRef.SecretRef == "" {
+		return errors.New("an environment variable must be a string or an object with a secretRef in the form name/key")
+	}
+
+	*v = EnvValue{SecretRef: secretRef.SecretRef}
+	return nil
 }
 
 type CodespecMetadata struct {
@@ -77,6 +128,7 @@ type RunCodeTarget struct {
 	Tags     map[string]string `json:"tags,omitempty"`
 	NodePool string            `json:"nodePool,omitempty"`
 	Replicas int               `json:"replicas,omitempty"`
+	Datasets map[string]int    `json:"datasets,omitempty"`
 }
 
 type CodespecRef struct {
@@ -122,6 +174,46 @@ type Run struct {
 	Worker         *RunCodeTarget `json:"worker,omitempty"`
 	Cluster        string         `json:"cluster,omitempty"`
 	TimeoutSeconds *int           `json:"timeoutSeconds,omitempty"`
+	RetryPolicy    *RetryPolicy   `json:"retryPolicy,omitempty"`
+	Usage          *RunUsage      `json:"usage,omitempty"`
+}
+
+type RunUsage struct {
+	Replicas []ReplicaUsage `json:"replicas,omitempty"`
+	Buffers  []BufferUsage  `json:"buffers,omitempty"`
+}
+
+type ReplicaUsage struct {
+	Target               string     `json:"target"`
+	Replica              int        `json:"replica"`
+	WallTimeSeconds      *float64   `json:"wallTimeSeconds,omitempty"`
+	CpuSeconds           *float64   `json:"cpuSeconds,omitempty"`
+	MemoryHighWaterBytes *int64     `json:"memoryHighWaterBytes,omitempty"`
+	GpuSeconds           *float64   `json:"gpuSeconds,omitempty"`
+	MeasuredAt           *time.Time `json:"measuredAt,omitempty"`
+}
+
+type BufferUsage struct {
+	Parameter string `json:"parameter"`
+	BufferId  string `json:"bufferId"`
+	Direction string `json:"direction"`
+	SizeBytes *int64 `json:"sizeBytes,omitempty"`
+}
+
+type RunEvent struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Target    string    `json:"target,omitempty"`
+	Replica   *int      `json:"replica,omitempty"`
+	Buffer    string    `json:"buffer,omitempty"`
+	ExitCode  *int      `json:"exitCode,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+type RetryPolicy struct {
+	MaxAttempts      int      `json:"maxAttempts,omitempty"`
+	RetryableReasons []string `json:"retryableReasons,omitempty"`
+	BackoffSeconds   *int     `json:"backoffSeconds,omitempty"`
 }
 
 type RunStatus int
@@ -192,11 +284,21 @@ func (status *RunStatus) UnmarshalJSON(b []byte) error {
 }
 
 type RunMetadata struct {
-	Id           int64      `json:"id,omitempty"`
-	Status       *RunStatus `json:"status,omitempty"`
+	Id           int64        `json:"id,omitempty"`
+	Status       *RunStatus   `json:"status,omitempty"`
+	StatusReason string       `json:"statusReason,omitempty"`
+	RunningCount *int         `json:"runningCount,omitempty"`
+	CreatedAt    time.Time    `json:"createdAt,omitempty"`
+	FinishedAt   *time.Time   `json:"finishedAt,omitempty"`
+	Attempts     []RunAttempt `json:"attempts,omitempty"`
+}
+
+type RunAttempt struct {
+	Replica      int        `json:"replica"`
+	Attempt      int        `json:"attempt"`
+	Status       RunStatus  `json:"status"`
 	StatusReason string     `json:"statusReason,omitempty"`
-	RunningCount *int       `json:"runningCount,omitempty"`
-	CreatedAt    time.Time  `json:"createdAt,omitempty"`
+	StartedAt    *time.Time `json:"startedAt,omitempty"`
 	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
 }
 
@@ -214,6 +316,39 @@ type NodePool struct {
 	VmSize string `json:"vmSize"`
 }
 
+type Dataset struct {
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	BufferId  string    `json:"bufferId"`
+	ByteCount *int64    `json:"byteCount,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type NewDatasetVersion struct {
+	BufferId string `json:"bufferId"`
+}
+
+type Secret struct {
+	Name      string     `json:"name"`
+	Keys      []string   `json:"keys"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type SecretData struct {
+	Data map[string]string `json:"data"`
+}
+
+// CodespecProblem is an issue with a codespec that would cause it to be rejected
+// or a run using it to fail.
+type CodespecProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type CodespecValidationResult struct {
+	Problems []CodespecProblem `json:"problems"`
+}
+
 type Cluster struct {
 	Name      string     `json:"name"`
 	Location  string     `json:"location"`
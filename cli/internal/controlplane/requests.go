@@ -161,8 +161,43 @@ End:
 	fmt.Println("\n]")
 
 	if warnIfTruncated && truncated {
-		color.New(color.FgYellow).Fprintln(os.Stderr, "Warning: the output was truncated. Specify the --limit parameter to increase the number of elements.")
+		warnOutputTruncated()
 	}
 
 	return nil
 }
+
+// GetPages follows the pages of a list request and returns up to limit items.
+// Unlike InvokePageRequests, nothing is written until all pages have been read.
+func GetPages[T any](ctx context.Context, uri string, limit int, warnIfTruncated bool) ([]T, error) {
+	items := make([]T, 0)
+	truncated := false
+
+	for uri != "" {
+		page := model.Page[T]{}
+		_, err := InvokeRequest(ctx, http.MethodGet, uri, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, item := range page.Items {
+			items = append(items, item)
+			if len(items) == limit {
+				truncated = i < len(page.Items)-1 || page.NextLink != ""
+				goto End
+			}
+		}
+
+		uri = strings.TrimLeft(page.NextLink, "/")
+	}
+End:
+	if warnIfTruncated && truncated {
+		warnOutputTruncated()
+	}
+
+	return items, nil
+}
+
+func warnOutputTruncated() {
+	color.New(color.FgYellow).Fprintln(os.Stderr, "Warning: the output was truncated. Specify the --limit parameter to increase the number of elements.")
+}
@@ -0,0 +1,137 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package controlplane
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/microsoft/tyger/cli/internal/settings"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/net/websocket"
+)
+
+// DialWebSocket opens a WebSocket connection to the given endpoint of the Tyger server,
+// using the same credentials, proxy, and TLS settings as other requests.
+func DialWebSocket(ctx context.Context, relativeUri string) (*websocket.Conn, error) {
+	serviceInfo, err := settings.GetServiceInfoFromContext(ctx)
+	if err != nil || serviceInfo.GetServerUri() == nil {
+		return nil, errors.New("run 'tyger login' to connect to a Tyger server")
+	}
+
+	token, err := serviceInfo.GetAccessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("run `tyger login` to login to a server: %v", err)
+	}
+
+	httpUrl, err := url.Parse(fmt.Sprintf("%s/%s", serviceInfo.GetServerUri(), relativeUri))
+	if err != nil {
+		return nil, err
+	}
+
+	wsUrl := *httpUrl
+	if httpUrl.Scheme == "https" {
+		wsUrl.Scheme = "wss"
+	} else {
+		wsUrl.Scheme = "ws"
+	}
+
+	config, err := websocket.NewConfig(wsUrl.String(), serviceInfo.GetServerUri().String())
+	if err != nil {
+		return nil, err
+	}
+
+	propagation.Baggage{}.Inject(ctx, propagation.HeaderCarrier(config.Header))
+	if token != "" {
+		config.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	conn, err := dialServer(ctx, httpUrl, serviceInfo)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to server: %v", err)
+	}
+
+	wsConn, err := websocket.NewClient(config, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to open a WebSocket connection to the server: %v", err)
+	}
+
+	return wsConn, nil
+}
+
+// dialServer opens a connection to the server, tunneling through the configured proxy if there is one.
+func dialServer(ctx context.Context, serverUrl *url.URL, serviceInfo settings.ServiceInfo) (net.Conn, error) {
+	address := serverUrl.Host
+	if serverUrl.Port() == "" {
+		if serverUrl.Scheme == "https" {
+			address = net.JoinHostPort(serverUrl.Hostname(), "443")
+		} else {
+			address = net.JoinHostPort(serverUrl.Hostname(), "80")
+		}
+	}
+
+	proxyUrl, err := serviceInfo.GetProxyFunc()(&http.Request{URL: serverUrl})
+	if err != nil {
+		return nil, fmt.Errorf("error getting proxy: %w", err)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if proxyUrl == nil {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", proxyUrl.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		connectReq := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: address},
+			Host:   address,
+			Header: http.Header{},
+		}
+		if err := connectReq.Write(conn); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to send CONNECT request: %w", err)
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to send CONNECT request: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("received unexpected status from CONNECT request: %s", resp.Status)
+		}
+	}
+
+	if serverUrl.Scheme != "https" {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverUrl.Hostname(),
+		InsecureSkipVerify: serviceInfo.GetDisableTlsCertificateValidation(),
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
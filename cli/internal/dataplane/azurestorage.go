@@ -0,0 +1,181 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/microsoft/tyger/cli/internal/httpclient"
+)
+
+// azureBlobStorage stores blobs in an Azure Storage container using a SAS URI.
+type azureBlobStorage struct {
+	httpClient *retryablehttp.Client
+}
+
+func (s *azureBlobStorage) putBlob(ctx context.Context, blobUri string, contents []byte, encodedMD5Hash string, encodedHashChain string, uncompressedLength int64) error {
+	var body any = contents
+	if len(contents) == 0 {
+		// This is a bit subtle, but if we send an empty or nil []byte body,
+		// we will empty with the Transfer-Encoding: chunked header, which
+		// the blob service does not support.  So we send a nil body instead.
+		body = nil
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPut, blobUri, body)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	AddCommonBlobRequestHeaders(req.Header)
+	req.Header.Add("x-ms-blob-type", "BlockBlob")
+
+	req.Header.Add(ContentMD5Header, encodedMD5Hash)
+	if encodedHashChain != "" {
+		req.Header.Add(HashChainHeader, encodedHashChain)
+	}
+	if uncompressedLength > 0 {
+		req.Header.Add(UncompressedLengthHeader, strconv.FormatInt(uncompressedLength, 10))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send request: %w", httpclient.RedactHttpError(err))
+	}
+
+	return handleWriteResponse(resp)
+}
+
+func (s *azureBlobStorage) getBlob(ctx context.Context, blobUri string) (*readData, error) {
+	newRequest := func() (*retryablehttp.Request, error) {
+		req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, blobUri, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		AddCommonBlobRequestHeaders(req.Header)
+		return req, nil
+	}
+
+	return getBlobWithRetry(ctx, s.httpClient, newRequest, handleReadResponse)
+}
+
+// getBlobInfo issues a HEAD request for a blob and returns its size, Content-MD5 and cumulative
+// hash chain headers. ErrNotFound is returned if the blob does not exist.
+func (s *azureBlobStorage) getBlobInfo(ctx context.Context, blobUri string) (blobInfo, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodHead, blobUri, nil)
+	if err != nil {
+		return blobInfo{}, fmt.Errorf("unable to create HEAD request: %w", err)
+	}
+
+	AddCommonBlobRequestHeaders(req.Header)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return blobInfo{}, fmt.Errorf("HEAD request failed: %w", httpclient.RedactHttpError(err))
+	}
+
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		info := blobInfo{
+			Size:             resp.ContentLength,
+			EncodedMD5Hash:   resp.Header.Get(ContentMD5Header),
+			EncodedHashChain: resp.Header.Get(HashChainHeader),
+		}
+		if uncompressedLength, err := parseUncompressedLengthHeader(resp.Header); err == nil {
+			info.UncompressedLength = uncompressedLength
+		}
+		return info, nil
+	case http.StatusNotFound:
+		if resp.Header.Get("x-ms-error-code") == "ContainerNotFound" {
+			return blobInfo{}, errBufferDoesNotExist
+		}
+		return blobInfo{}, ErrNotFound
+	default:
+		return blobInfo{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+}
+
+func handleReadResponse(resp *http.Response) (*readData, error) {
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		md5Header := resp.Header.Get(ContentMD5Header)
+		buf, err := readBlobResponseBody(resp, md5Header)
+		if err != nil {
+			return nil, err
+		}
+
+		response := readData{
+			Data:             buf,
+			EncodedMD5Hash:   md5Header,
+			EncodedHashChain: resp.Header.Get(HashChainHeader),
+		}
+		if uncompressedLength, err := parseUncompressedLengthHeader(resp.Header); err == nil {
+			response.UncompressedLength = uncompressedLength
+		}
+
+		return &response, nil
+	case http.StatusNotFound:
+		io.Copy(io.Discard, resp.Body)
+		switch resp.Header.Get("x-ms-error-code") {
+		case "BlobNotFound":
+			return nil, ErrNotFound
+		case "ContainerNotFound":
+			return nil, errBufferDoesNotExist
+		}
+		fallthrough
+	default:
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+}
+
+func handleWriteResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	case http.StatusNotFound:
+		if resp.Header.Get("x-ms-error-code") == "ContainerNotFound" {
+			return errBufferDoesNotExist
+		}
+		fallthrough
+	case http.StatusBadRequest:
+		if resp.Header.Get("x-ms-error-code") == "Md5Mismatch" {
+			io.Copy(io.Discard, resp.Body)
+			return errMd5Mismatch
+		}
+		fallthrough
+	case http.StatusForbidden:
+		if resp.Header.Get("x-ms-error-code") == "UnauthorizedBlobOverwrite" {
+			io.Copy(io.Discard, resp.Body)
+			return errBlobOverwrite
+		}
+		fallthrough
+	default:
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+}
+
+func parseUncompressedLengthHeader(header http.Header) (int64, error) {
+	value := header.Get(UncompressedLengthHeader)
+	if value == "" {
+		return 0, fmt.Errorf("expected %s header missing", UncompressedLengthHeader)
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
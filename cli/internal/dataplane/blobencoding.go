@@ -0,0 +1,141 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"errors"
+	"fmt"
+
+	pool "github.com/libp2p/go-buffer-pool"
+)
+
+// blobEncoding describes how the contents of a buffer's blobs are transformed before they are stored:
+// they are compressed and then encrypted, and either step may be absent. MD5 hashes and the hash chain
+// are always computed over the stored bytes.
+type blobEncoding struct {
+	codec  blobCodec
+	cipher *blobCipher
+}
+
+// newBufferEncoding returns the start metadata for a new buffer along with the encoding of its blobs.
+// The start metadata has the oldest format version that supports the encoding, so that readers
+// that predate compression or encryption can still read buffers that use neither.
+func newBufferEncoding(compression string, keyProvider KeyProvider) (BufferStartMetadata, *blobEncoding, error) {
+	bufferStartMetadata := BufferStartMetadata{Version: baseBufferFormatVersion}
+	encoding := &blobEncoding{}
+
+	codec, err := getBlobCodec(compression)
+	if err != nil {
+		return bufferStartMetadata, nil, err
+	}
+	if codec != nil {
+		encoding.codec = codec
+		bufferStartMetadata.Compression = compression
+		bufferStartMetadata.Version = CurrentBufferFormatVersion
+	}
+
+	if keyProvider != nil {
+		bufferStartMetadata.Encryption, encoding.cipher, err = newBufferEncryption(keyProvider)
+		if err != nil {
+			return bufferStartMetadata, nil, err
+		}
+		if codec == nil {
+			bufferStartMetadata.Version = encryptedBufferFormatVersion
+		}
+	}
+
+	return bufferStartMetadata, encoding, nil
+}
+
+// getBlobEncoding returns the encoding of the blobs of an existing buffer.
+func getBlobEncoding(bufferStartMetadata *BufferStartMetadata, keyProvider KeyProvider) (*blobEncoding, error) {
+	codec, err := getBlobCodec(bufferStartMetadata.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err := getBlobCipher(bufferStartMetadata.Encryption, keyProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blobEncoding{codec: codec, cipher: cipher}, nil
+}
+
+func (e *blobEncoding) isIdentity() bool {
+	return e.codec == nil && e.cipher == nil
+}
+
+// encode returns the bytes to store for the contents of a blob in a buffer obtained from the pool.
+// The contents are not modified.
+func (e *blobEncoding) encode(blobNumber int64, contents []byte) []byte {
+	encoded := contents
+	if e.codec != nil {
+		encoded = e.codec.compress(contents)
+	}
+
+	if e.cipher != nil {
+		encrypted := e.cipher.encrypt(blobNumber, encoded)
+		if e.codec != nil {
+			pool.Put(encoded)
+		}
+		encoded = encrypted
+	}
+
+	return encoded
+}
+
+// decode returns the original contents of a blob from its stored bytes in a buffer obtained from the pool.
+// The stored bytes are not modified.
+func (e *blobEncoding) decode(blobNumber int64, stored []byte, uncompressedLength int64) ([]byte, error) {
+	decoded := stored
+	if e.cipher != nil {
+		decrypted, err := e.cipher.decrypt(blobNumber, stored)
+		if err != nil {
+			return nil, err
+		}
+		decoded = decrypted
+	}
+
+	if e.codec != nil {
+		var decompressed []byte
+		var err error
+		if uncompressedLength <= 0 {
+			err = errors.New("the uncompressed length is missing")
+		} else if uncompressedLength > MaxBlobSizeBytes {
+			// The uncompressed length is not covered by the hash chain, so it is checked before allocating.
+			err = fmt.Errorf("the uncompressed length %d is greater than the maximum blob size", uncompressedLength)
+		} else {
+			decompressed, err = e.codec.decompress(decoded, int(uncompressedLength))
+		}
+
+		if e.cipher != nil {
+			pool.Put(decoded)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress blob %d: %w", blobNumber, err)
+		}
+
+		decoded = decompressed
+	}
+
+	return decoded, nil
+}
+
+// decodedSize returns the size of the original contents of a non-empty blob.
+func (e *blobEncoding) decodedSize(info blobInfo) (int64, error) {
+	if e.codec != nil {
+		if info.UncompressedLength <= 0 {
+			return 0, fmt.Errorf("blob %d is missing its uncompressed length", info.BlobNumber)
+		}
+		return info.UncompressedLength, nil
+	}
+
+	if e.cipher != nil {
+		return info.Size - int64(e.cipher.overhead()), nil
+	}
+
+	return info.Size, nil
+}
@@ -4,6 +4,8 @@
 package dataplane
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"math/bits"
@@ -17,18 +19,32 @@ import (
 )
 
 const (
-	CurrentBufferFormatVersion = "0.3.0"
+	CurrentBufferFormatVersion = "0.5.0"
 
 	BufferStatusComplete = "complete"
 	BufferStatusFailed   = "failed"
 
-	HashChainHeader  = "x-ms-meta-cumulative_hash_chain"
-	ContentMD5Header = "Content-MD5"
+	HashChainHeader          = "x-ms-meta-" + hashChainMetadataName
+	UncompressedLengthHeader = "x-ms-meta-" + uncompressedLengthMetadataName
+	ContentMD5Header         = "Content-MD5"
+
+	hashChainMetadataName          = "cumulative_hash_chain"
+	uncompressedLengthMetadataName = "uncompressed_length"
 
 	StartMetadataBlobName = ".bufferstart"
 	EndMetadataBlobName   = ".bufferend"
 )
 
+// The buffer format versions that can be read, in order. Version 0.4.0 added encryption and
+// version 0.5.0 added compression. Buffers that use neither are written with the oldest version
+// so that older readers can still read them.
+var supportedBufferFormatVersions = []string{baseBufferFormatVersion, encryptedBufferFormatVersion, CurrentBufferFormatVersion}
+
+const (
+	baseBufferFormatVersion      = "0.3.0"
+	encryptedBufferFormatVersion = "0.4.0"
+)
+
 var (
 	errMd5Mismatch        = errors.New("MD5 mismatch")
 	errBufferDoesNotExist = errors.New("the buffer does not exist")
@@ -46,18 +62,53 @@ type BufferBlob struct {
 	// For Reading
 	EncodedMD5Hash      string
 	EncodedMD5ChainHash string
+	UncompressedLength  int64
+}
+
+// The metadata of a blob, obtained without downloading its contents.
+type blobInfo struct {
+	BlobNumber         int64
+	Size               int64
+	UncompressedLength int64
+	EncodedMD5Hash     string
+	EncodedHashChain   string
+	Error              error
 }
 
 type BufferStartMetadata struct {
-	Version string `json:"version"`
+	Version     string              `json:"version"`
+	Compression string              `json:"compression,omitempty"`
+	Encryption  *EncryptionMetadata `json:"encryption,omitempty"`
 }
 
 type BufferEndMetadata struct {
 	Status string `json:"status"`
+
+	// The number of blobs in the buffer, not counting the final empty blob. Only recorded for complete buffers.
+	BlobCount *int64 `json:"blobCount,omitempty"`
+
+	// The number of stored bytes in the buffer. Only recorded for complete buffers.
+	ByteCount *int64 `json:"byteCount,omitempty"`
+}
+
+func newCompleteBufferEndMetadata(blobCount int64, byteCount int64) BufferEndMetadata {
+	return BufferEndMetadata{
+		Status:    BufferStatusComplete,
+		BlobCount: &blobCount,
+		ByteCount: &byteCount,
+	}
 }
 
 type Container struct {
 	*url.URL
+	storage blobStorage
+}
+
+// calculateNextHashChain returns the cumulative hash chain value of a blob given the value
+// of the previous blob and the encoded MD5 hash of the blob's contents.
+func calculateNextHashChain(previousEncodedHashChain string, encodedMD5Hash string) string {
+	hashChain := sha256.Sum256([]byte(previousEncodedHashChain + encodedMD5Hash))
+	return base64.StdEncoding.EncodeToString(hashChain[:])
 }
 
 func clearBit(value int64, pos int) int64 {
@@ -143,7 +194,12 @@ func NewContainer(sasUri string, httpClient *retryablehttp.Client) (*Container,
 		return nil, err
 	}
 
-	return &Container{parsedUri}, nil
+	storage, err := newBlobStorage(parsedUri, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Container{URL: parsedUri, storage: storage}, nil
 }
 
 func AddCommonBlobRequestHeaders(header http.Header) {
@@ -0,0 +1,36 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBufferStartMetadataVersions(t *testing.T) {
+	testCases := []struct {
+		version   string
+		supported bool
+	}{
+		{"0.3.0", true},
+		{"0.4.0", true},
+		{CurrentBufferFormatVersion, true},
+		{"0.2.0", false},
+		{"0.6.0", false},
+		{"1.0.0", false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.version, func(t *testing.T) {
+			metadata, err := parseBufferStartMetadata([]byte(fmt.Sprintf(`{"version":"%s"}`, tC.version)))
+			if tC.supported {
+				require.NoError(t, err)
+				require.Equal(t, tC.version, metadata.Version)
+			} else {
+				require.ErrorContains(t, err, "unsupported format buffer version")
+			}
+		})
+	}
+}
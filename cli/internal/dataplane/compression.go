@@ -0,0 +1,111 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/klauspost/compress/zstd"
+	pool "github.com/libp2p/go-buffer-pool"
+	"github.com/pierrec/lz4/v4"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionZstd = "zstd"
+	CompressionLz4  = "lz4"
+)
+
+var SupportedCompressions = []string{CompressionNone, CompressionZstd, CompressionLz4}
+
+// A blobCodec compresses and decompresses the contents of individual blobs.
+// The returned slices are obtained from the buffer pool.
+type blobCodec interface {
+	compress(src []byte) []byte
+	decompress(src []byte, uncompressedLength int) ([]byte, error)
+}
+
+func normalizeCompression(compression string) string {
+	if compression == "" {
+		return CompressionNone
+	}
+	return compression
+}
+
+// getBlobCodec returns the codec for the given compression, or nil if no compression is to be applied.
+func getBlobCodec(compression string) (blobCodec, error) {
+	switch compression {
+	case "", CompressionNone:
+		return nil, nil
+	case CompressionZstd:
+		// The codec is shared by all the upload workers. EncodeAll uses one of up to GOMAXPROCS encoders,
+		// so that blobs are compressed in parallel.
+		encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(runtime.GOMAXPROCS(0)))
+		if err != nil {
+			return nil, fmt.Errorf("unable to create zstd encoder: %w", err)
+		}
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(MaxBlobSizeBytes))
+		if err != nil {
+			return nil, fmt.Errorf("unable to create zstd decoder: %w", err)
+		}
+		return &zstdCodec{encoder: encoder, decoder: decoder}, nil
+	case CompressionLz4:
+		return lz4Codec{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression '%s'. Supported values are %v", compression, SupportedCompressions)
+	}
+}
+
+type zstdCodec struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (c *zstdCodec) compress(src []byte) []byte {
+	return c.encoder.EncodeAll(src, pool.Get(len(src))[:0])
+}
+
+func (c *zstdCodec) decompress(src []byte, uncompressedLength int) ([]byte, error) {
+	buf := pool.Get(uncompressedLength)
+	dst, err := c.decoder.DecodeAll(src, buf[:0])
+	if err != nil {
+		pool.Put(buf)
+		return nil, err
+	}
+	if len(dst) != uncompressedLength {
+		pool.Put(dst)
+		return nil, fmt.Errorf("expected %d bytes after decompression but got %d", uncompressedLength, len(dst))
+	}
+
+	return dst, nil
+}
+
+type lz4Codec struct{}
+
+func (lz4Codec) compress(src []byte) []byte {
+	buf := pool.Get(lz4.CompressBlockBound(len(src)))
+	n, err := lz4.CompressBlock(src, buf, nil)
+	if err != nil {
+		// This only happens if the destination is too small, which the bound prevents.
+		panic(fmt.Errorf("lz4 compression failed: %w", err))
+	}
+
+	return buf[:n]
+}
+
+func (lz4Codec) decompress(src []byte, uncompressedLength int) ([]byte, error) {
+	buf := pool.Get(uncompressedLength)
+	n, err := lz4.UncompressBlock(src, buf)
+	if err != nil {
+		pool.Put(buf)
+		return nil, err
+	}
+	if n != uncompressedLength {
+		pool.Put(buf)
+		return nil, fmt.Errorf("expected %d bytes after decompression but got %d", uncompressedLength, n)
+	}
+
+	return buf, nil
+}
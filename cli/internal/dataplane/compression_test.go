@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobCodecRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	contents := bytes.Repeat([]byte("k-space sample "), 1000)
+
+	for _, compression := range []string{CompressionZstd, CompressionLz4} {
+		t.Run(compression, func(t *testing.T) {
+			codec, err := getBlobCodec(compression)
+			require.NoError(t, err)
+
+			compressed := codec.compress(contents)
+			assert.Less(t, len(compressed), len(contents))
+			assert.Equal(t, compressed, codec.compress(contents), "compression must be deterministic for resuming writes")
+
+			decompressed, err := codec.decompress(compressed, len(contents))
+			require.NoError(t, err)
+			assert.Equal(t, contents, decompressed)
+
+			_, err = codec.decompress(compressed, len(contents)+1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestZstdCodecCompressesConcurrently(t *testing.T) {
+	t.Parallel()
+
+	codec, err := getBlobCodec(CompressionZstd)
+	require.NoError(t, err)
+
+	contents := bytes.Repeat([]byte("k-space sample "), 1000)
+	expected := codec.compress(contents)
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = codec.compress(contents)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		assert.Equal(t, expected, result)
+	}
+}
+
+func TestBlobEncodingCompressesBeforeEncrypting(t *testing.T) {
+	t.Parallel()
+
+	bufferStartMetadata, encoding, err := newBufferEncoding(CompressionZstd, newTestKeyProvider(t))
+	require.NoError(t, err)
+	assert.Equal(t, CompressionZstd, bufferStartMetadata.Compression)
+	assert.NotNil(t, bufferStartMetadata.Encryption)
+
+	contents := bytes.Repeat([]byte("k-space sample "), 1000)
+	encoded := encoding.encode(7, contents)
+	assert.Less(t, len(encoded), len(contents))
+
+	decoded, err := encoding.decode(7, encoded, int64(len(contents)))
+	require.NoError(t, err)
+	assert.Equal(t, contents, decoded)
+
+	_, err = encoding.decode(7, encoded, 0)
+	assert.ErrorContains(t, err, "unable to decompress blob 7")
+
+	_, err = encoding.decode(7, encoded, MaxBlobSizeBytes+1)
+	assert.ErrorContains(t, err, "greater than the maximum blob size")
+}
+
+func TestBufferFormatVersionDependsOnEncoding(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		compression string
+		encrypted   bool
+		version     string
+	}{
+		{"identity", CompressionNone, false, "0.3.0"},
+		{"encrypted", CompressionNone, true, "0.4.0"},
+		{"compressed", CompressionZstd, false, "0.5.0"},
+		{"compressed and encrypted", CompressionZstd, true, "0.5.0"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			var keyProvider KeyProvider
+			if tC.encrypted {
+				keyProvider = newTestKeyProvider(t)
+			}
+
+			bufferStartMetadata, _, err := newBufferEncoding(tC.compression, keyProvider)
+			require.NoError(t, err)
+			assert.Equal(t, tC.version, bufferStartMetadata.Version)
+		})
+	}
+}
+
+func TestUnsupportedCompression(t *testing.T) {
+	t.Parallel()
+
+	_, err := getBlobCodec("brotli")
+	assert.ErrorContains(t, err, "unsupported compression 'brotli'")
+}
@@ -0,0 +1,271 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	pool "github.com/libp2p/go-buffer-pool"
+	"github.com/microsoft/tyger/cli/internal/httpclient"
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultCopyDop = 32
+
+type copyOptions struct {
+	dop        int
+	httpClient *retryablehttp.Client
+}
+
+type CopyOption func(o *copyOptions)
+
+func WithCopyDop(dop int) CopyOption {
+	return func(o *copyOptions) {
+		o.dop = dop
+	}
+}
+
+func WithCopyHttpClient(httpClient *retryablehttp.Client) CopyOption {
+	return func(o *copyOptions) {
+		o.httpClient = httpClient
+	}
+}
+
+// Copy copies the blobs of a buffer to another buffer, which must not have been written to.
+// The stored bytes of each blob are copied as they are, so compressed and encrypted buffers
+// are copied without being decoded and no encryption key is needed. The hash chain of the source
+// is verified as the blobs are read. Like Read, Copy waits for blobs that have not been written
+// yet, and the end metadata of the destination is written once the source has been marked as complete.
+func Copy(ctx context.Context, sourceUri string, destinationUri string, options ...CopyOption) error {
+	copyOptions := &copyOptions{
+		dop: DefaultCopyDop,
+	}
+	for _, o := range options {
+		o(copyOptions)
+	}
+
+	if copyOptions.httpClient == nil {
+		copyOptions.httpClient = httpclient.NewRetryableClient()
+		copyOptions.httpClient.HTTPClient.Timeout = ResponseTimeout
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx = log.With().Str("operation", "buffer copy").Logger().WithContext(ctx)
+
+	source, err := NewContainer(sourceUri, copyOptions.httpClient)
+	if err != nil {
+		return fmt.Errorf("invalid source URL: %w", err)
+	}
+
+	destination, err := NewContainer(destinationUri, copyOptions.httpClient)
+	if err != nil {
+		return fmt.Errorf("invalid destination URL: %w", err)
+	}
+
+	bufferStartMetadata, err := readBufferStart(ctx, source)
+	if err != nil {
+		return fmt.Errorf("unable to read the source buffer: %w", err)
+	}
+
+	if err := writeStartMetadata(ctx, destination, *bufferStartMetadata); err != nil {
+		return err
+	}
+
+	waitForBlobs := atomic.Bool{}
+	waitForBlobs.Store(true)
+
+	bufferEndChannel := make(chan error, 1)
+	go func() {
+		err := pollForBufferEnd(ctx, source)
+		if err == nil {
+			// All blobs should have been written successfully by now.
+			waitForBlobs.Store(false)
+		}
+		bufferEndChannel <- err
+	}()
+
+	metrics := TransferMetrics{
+		Context:   ctx,
+		Container: destination,
+	}
+	metrics.Start()
+
+	var blobCount, byteCount int64
+	copyChannel := make(chan error, 1)
+	go func() {
+		var err error
+		blobCount, byteCount, err = copyBlobs(ctx, source, destination, copyOptions.dop, &waitForBlobs, &metrics)
+		copyChannel <- err
+	}()
+
+	// The destination is only marked as complete once all blobs have been copied and the source has been marked as complete.
+	select {
+	case err = <-copyChannel:
+		if err == nil {
+			err = <-bufferEndChannel
+		}
+	case err = <-bufferEndChannel:
+		if err != nil {
+			// Stop copying blobs before marking the destination as failed.
+			cancel()
+		}
+		if copyErr := <-copyChannel; err == nil {
+			err = copyErr
+		}
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			// use a new context to write the end metadata
+			newCtx, cancel := context.WithTimeout(&MergedContext{Context: context.Background(), valueSource: ctx}, 3*time.Second)
+			defer cancel()
+			ctx = newCtx
+		}
+		writeEndMetadata(ctx, destination, BufferEndMetadata{Status: BufferStatusFailed})
+		return err
+	}
+
+	writeEndMetadata(ctx, destination, newCompleteBufferEndMetadata(blobCount, byteCount))
+	metrics.Stop()
+	return nil
+}
+
+// copyBlobs downloads the blobs of the source concurrently, verifies the hash chain in order,
+// and uploads the verified blobs to the destination concurrently. It returns the number of blobs
+// copied, not counting the final empty blob, and the number of bytes copied.
+func copyBlobs(ctx context.Context, source *Container, destination *Container, dop int, waitForBlobs *atomic.Bool, metrics *TransferMetrics) (blobCount int64, byteCount int64, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	finalBlobNumber := atomic.Int64{}
+	finalBlobNumber.Store(-1)
+
+	downloadChannel := make(chan chan BufferBlob, dop)
+	go func() {
+		defer close(downloadChannel)
+		for blobNumber := int64(0); ; blobNumber++ {
+			if final := finalBlobNumber.Load(); final >= 0 && blobNumber > final {
+				return
+			}
+
+			c := make(chan BufferBlob, 1)
+			select {
+			case downloadChannel <- c:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(blobNumber int64) {
+				ctx := log.Ctx(ctx).With().Int64("blobNumber", blobNumber).Logger().WithContext(ctx)
+				respData, err := DownloadBlob(ctx, source, source.GetBlobUri(blobNumber), waitForBlobs, &blobNumber, &finalBlobNumber)
+				if err != nil {
+					if err == ErrNotFound {
+						err = fmt.Errorf("blob number %d was expected to exist but does not", blobNumber)
+					}
+					c <- BufferBlob{BlobNumber: blobNumber, Error: err}
+					return
+				}
+
+				c <- BufferBlob{
+					BlobNumber:          blobNumber,
+					Contents:            respData.Data,
+					EncodedMD5Hash:      respData.EncodedMD5Hash,
+					EncodedMD5ChainHash: respData.EncodedHashChain,
+					UncompressedLength:  respData.UncompressedLength,
+				}
+			}(blobNumber)
+		}
+	}()
+
+	uploadChannel := make(chan BufferBlob, dop)
+	errorChannel := make(chan error, dop+1)
+
+	wg := sync.WaitGroup{}
+	wg.Add(dop)
+	for i := 0; i < dop; i++ {
+		go func() {
+			defer wg.Done()
+			for bb := range uploadChannel {
+				ctx := log.Ctx(ctx).With().Int64("blobNumber", bb.BlobNumber).Logger().WithContext(ctx)
+				err := uploadBlobWithRetry(ctx, destination, destination.GetBlobUri(bb.BlobNumber), bb.Contents, bb.EncodedMD5Hash, bb.EncodedMD5ChainHash, bb.UncompressedLength)
+				if err != nil {
+					errorChannel <- err
+					cancel()
+					return
+				}
+
+				metrics.Update(uint64(len(bb.Contents)))
+				pool.Put(bb.Contents)
+			}
+		}()
+	}
+
+	encodedHashChain := EncodedHashChainInitialValue
+	verifyErr := func() error {
+		defer close(uploadChannel)
+		for c := range downloadChannel {
+			var blob BufferBlob
+			select {
+			case blob = <-c:
+			case <-ctx.Done():
+				return nil
+			}
+
+			if blob.Error != nil {
+				if blob.Error == errPastEndOfBlob {
+					continue
+				}
+				return fmt.Errorf("error downloading blob: %w", blob.Error)
+			}
+
+			if blob.EncodedMD5ChainHash == "" {
+				return fmt.Errorf("blob %d is missing its hash chain", blob.BlobNumber)
+			}
+
+			encodedHashChain = calculateNextHashChain(encodedHashChain, blob.EncodedMD5Hash)
+			if blob.EncodedMD5ChainHash != encodedHashChain {
+				return fmt.Errorf("hash chain mismatch at blob %d", blob.BlobNumber)
+			}
+
+			select {
+			case uploadChannel <- blob:
+			case <-ctx.Done():
+				return nil
+			}
+
+			if len(blob.Contents) == 0 {
+				return nil
+			}
+
+			blobCount++
+			byteCount += int64(len(blob.Contents))
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		return errors.New("the source buffer ended without a final blob")
+	}()
+
+	wg.Wait()
+	close(errorChannel)
+
+	if verifyErr != nil {
+		return 0, 0, verifyErr
+	}
+
+	if err, ok := <-errorChannel; ok {
+		return 0, 0, err
+	}
+
+	return blobCount, byteCount, ctx.Err()
+}
@@ -0,0 +1,82 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyEncryptedBuffer(t *testing.T) {
+	t.Parallel()
+
+	sourceUri, _ := newLocalBufferUri(t)
+	destinationUri, _ := newLocalBufferUri(t)
+	keyProvider := newTestKeyProvider(t)
+
+	input := make([]byte, 10*1024+17)
+	_, err := rand.Read(input)
+	require.NoError(t, err)
+
+	err = Write(context.Background(), sourceUri, bytes.NewReader(input), WithWriteBlockSize(1024), WithWriteKeyProvider(keyProvider), WithWriteCompression(CompressionZstd))
+	require.NoError(t, err)
+
+	// No key is needed to copy
+	err = Copy(context.Background(), sourceUri, destinationUri, WithCopyDop(4))
+	require.NoError(t, err)
+
+	bufferEndMetadata := readLocalEndMetadata(t, destinationUri)
+	assert.Equal(t, BufferStatusComplete, bufferEndMetadata.Status)
+
+	result, err := Verify(context.Background(), sourceUri)
+	require.NoError(t, err)
+	require.True(t, result.Valid)
+	require.NotNil(t, bufferEndMetadata.BlobCount)
+	require.NotNil(t, bufferEndMetadata.ByteCount)
+	assert.Equal(t, int64(11), *bufferEndMetadata.BlobCount)
+	assert.Equal(t, result.TotalBytes, *bufferEndMetadata.ByteCount)
+
+	output := &bytes.Buffer{}
+	err = Read(context.Background(), destinationUri, output, WithReadKeyProvider(keyProvider))
+	require.NoError(t, err)
+	assert.Equal(t, input, output.Bytes())
+
+	err = Copy(context.Background(), sourceUri, destinationUri)
+	assert.ErrorContains(t, err, "buffer cannot be overwritten")
+}
+
+func TestCopyDetectsHashChainMismatch(t *testing.T) {
+	t.Parallel()
+
+	sourceUri, sourceDir := newLocalBufferUri(t)
+	destinationUri, destinationDir := newLocalBufferUri(t)
+
+	err := Write(context.Background(), sourceUri, bytes.NewReader(make([]byte, 5000)), WithWriteBlockSize(1024))
+	require.NoError(t, err)
+
+	blobPath := filepath.Join(sourceDir, "00", "002")
+	contents, err := os.ReadFile(blobPath)
+	require.NoError(t, err)
+	header, data, _ := bytes.Cut(contents, []byte{'\n'})
+	var metadata map[string]string
+	require.NoError(t, json.Unmarshal(header, &metadata))
+	metadata[hashChainMetadataName] = "invalid"
+	header, err = json.Marshal(metadata)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(blobPath, append(append(header, '\n'), data...), 0644))
+
+	err = Copy(context.Background(), sourceUri, destinationUri)
+	assert.ErrorContains(t, err, "hash chain mismatch at blob 2")
+
+	assert.Equal(t, BufferStatusFailed, readLocalEndMetadata(t, destinationUri).Status)
+	assert.NoFileExists(t, filepath.Join(destinationDir, "00", "002"))
+}
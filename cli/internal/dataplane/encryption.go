@@ -0,0 +1,221 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	pool "github.com/libp2p/go-buffer-pool"
+)
+
+const (
+	EncryptionSchemeAes256Gcm = "AES-256-GCM"
+
+	encryptionKeySize = 32
+)
+
+var (
+	errBufferEncrypted = errors.New("the buffer is encrypted and an encryption key must be provided")
+)
+
+// EncryptionMetadata describes how the blobs of a buffer are encrypted and is recorded
+// in the buffer's start metadata. Each buffer has its own randomly generated data key,
+// which is stored wrapped (encrypted) by the key identified by KeyId.
+type EncryptionMetadata struct {
+	Scheme     string `json:"scheme"`
+	KeyId      string `json:"keyId"`
+	WrappedKey string `json:"wrappedKey"`
+}
+
+// A KeyProvider wraps and unwraps the data keys used to encrypt buffers.
+type KeyProvider interface {
+	KeyId() string
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(keyId string, wrappedKey []byte) ([]byte, error)
+}
+
+// localKeyProvider wraps data keys with a 256-bit key read from a local file.
+type localKeyProvider struct {
+	keyId string
+	aead  cipher.AEAD
+}
+
+// NewKeyProviderFromFile creates a KeyProvider from a file containing a 256-bit key,
+// either as 32 raw bytes or base64-encoded.
+func NewKeyProviderFromFile(path string) (KeyProvider, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read encryption key file: %w", err)
+	}
+
+	key := contents
+	if len(key) != encryptionKeySize {
+		key, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(contents)))
+		if err != nil || len(key) != encryptionKeySize {
+			return nil, fmt.Errorf("the encryption key file must contain a %d-byte key, either raw or base64-encoded", encryptionKeySize)
+		}
+	}
+
+	aead, err := newAesGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyHash := sha256.Sum256(key)
+	return &localKeyProvider{
+		keyId: "local:" + hex.EncodeToString(keyHash[:8]),
+		aead:  aead,
+	}, nil
+}
+
+func (p *localKeyProvider) KeyId() string {
+	return p.keyId
+}
+
+func (p *localKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %w", err)
+	}
+
+	return p.aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func (p *localKeyProvider) UnwrapKey(keyId string, wrappedKey []byte) ([]byte, error) {
+	if keyId != p.keyId {
+		return nil, fmt.Errorf("the buffer is encrypted with key '%s' but the key provided is '%s'", keyId, p.keyId)
+	}
+
+	nonceSize := p.aead.NonceSize()
+	if len(wrappedKey) < nonceSize {
+		return nil, errors.New("the wrapped data key is invalid")
+	}
+
+	dataKey, err := p.aead.Open(nil, wrappedKey[:nonceSize], wrappedKey[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unwrap the data key: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+// blobCipher encrypts and decrypts the contents of individual blobs with a buffer's data key.
+// The nonce is derived from the blob number, so encrypting the same input always produces the
+// same blob, which is required for resuming writes. Since each buffer has its own data key,
+// a nonce is never reused with different contents.
+type blobCipher struct {
+	aead cipher.AEAD
+}
+
+// newBufferEncryption generates a data key for a new buffer and returns the metadata to record
+// in the buffer's start metadata along with the cipher to encrypt its blobs.
+func newBufferEncryption(keyProvider KeyProvider) (*EncryptionMetadata, *blobCipher, error) {
+	dataKey := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("unable to generate data key: %w", err)
+	}
+
+	wrappedKey, err := keyProvider.WrapKey(dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to wrap data key: %w", err)
+	}
+
+	aead, err := newAesGcm(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := &EncryptionMetadata{
+		Scheme:     EncryptionSchemeAes256Gcm,
+		KeyId:      keyProvider.KeyId(),
+		WrappedKey: base64.StdEncoding.EncodeToString(wrappedKey),
+	}
+
+	return metadata, &blobCipher{aead: aead}, nil
+}
+
+// getBlobCipher returns the cipher for the blobs of a buffer given its encryption metadata,
+// or nil if the buffer is not encrypted.
+func getBlobCipher(metadata *EncryptionMetadata, keyProvider KeyProvider) (*blobCipher, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+
+	if metadata.Scheme != EncryptionSchemeAes256Gcm {
+		return nil, fmt.Errorf("unsupported buffer encryption scheme '%s'", metadata.Scheme)
+	}
+
+	if keyProvider == nil {
+		return nil, errBufferEncrypted
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(metadata.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("the wrapped data key is invalid: %w", err)
+	}
+
+	dataKey, err := keyProvider.UnwrapKey(metadata.KeyId, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAesGcm(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blobCipher{aead: aead}, nil
+}
+
+func newAesGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// The number of bytes that encryption adds to a blob.
+func (c *blobCipher) overhead() int {
+	return c.aead.Overhead()
+}
+
+func (c *blobCipher) nonce(blobNumber int64) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], uint64(blobNumber))
+	return nonce
+}
+
+// encrypt returns the encrypted contents in a buffer obtained from the pool.
+func (c *blobCipher) encrypt(blobNumber int64, plaintext []byte) []byte {
+	buf := pool.Get(len(plaintext) + c.aead.Overhead())
+	return c.aead.Seal(buf[:0], c.nonce(blobNumber), plaintext, nil)
+}
+
+// decrypt returns the decrypted contents in a buffer obtained from the pool.
+func (c *blobCipher) decrypt(blobNumber int64, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.aead.Overhead() {
+		return nil, fmt.Errorf("blob %d is too small to be encrypted", blobNumber)
+	}
+
+	buf := pool.Get(len(ciphertext) - c.aead.Overhead())
+	plaintext, err := c.aead.Open(buf[:0], c.nonce(blobNumber), ciphertext, nil)
+	if err != nil {
+		pool.Put(buf)
+		return nil, fmt.Errorf("unable to decrypt blob %d: %w", blobNumber, err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyProvider(t *testing.T) KeyProvider {
+	key := make([]byte, encryptionKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	keyFilePath := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFilePath, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600))
+
+	keyProvider, err := NewKeyProviderFromFile(keyFilePath)
+	require.NoError(t, err)
+	return keyProvider
+}
+
+func TestBlobEncryptionRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	keyProvider := newTestKeyProvider(t)
+	metadata, encryptingCipher, err := newBufferEncryption(keyProvider)
+	require.NoError(t, err)
+	assert.Equal(t, EncryptionSchemeAes256Gcm, metadata.Scheme)
+	assert.Equal(t, keyProvider.KeyId(), metadata.KeyId)
+
+	decryptingCipher, err := getBlobCipher(metadata, keyProvider)
+	require.NoError(t, err)
+
+	plaintext := []byte("some signal data")
+	ciphertext := encryptingCipher.encrypt(3, plaintext)
+	assert.Len(t, ciphertext, len(plaintext)+encryptingCipher.overhead())
+	assert.Equal(t, ciphertext, encryptingCipher.encrypt(3, plaintext), "encryption must be deterministic for resuming writes")
+
+	decrypted, err := decryptingCipher.decrypt(3, ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	_, err = decryptingCipher.decrypt(4, ciphertext)
+	assert.ErrorContains(t, err, "unable to decrypt blob 4")
+}
+
+func TestBlobEncryptionRequiresMatchingKey(t *testing.T) {
+	t.Parallel()
+
+	metadata, _, err := newBufferEncryption(newTestKeyProvider(t))
+	require.NoError(t, err)
+
+	_, err = getBlobCipher(metadata, nil)
+	assert.ErrorIs(t, err, errBufferEncrypted)
+
+	_, err = getBlobCipher(metadata, newTestKeyProvider(t))
+	assert.ErrorContains(t, err, "the buffer is encrypted with key")
+
+	metadata.Scheme = "ROT13"
+	_, err = getBlobCipher(metadata, nil)
+	assert.ErrorContains(t, err, "unsupported buffer encryption scheme")
+}
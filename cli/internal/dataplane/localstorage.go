@@ -0,0 +1,233 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	FileUriScheme = "file"
+
+	// The name of the field in a local blob's header that holds the MD5 hash of its contents.
+	localMD5HeaderName = "content_md5"
+)
+
+// localBlobStorage stores blobs in a directory on the local filesystem, with the same layout as
+// in a storage container. Each blob file starts with a single line of JSON holding the blob's MD5
+// hash and its metadata (including the hash chain), followed by the blob's contents. Because the
+// metadata is part of the file, a blob and its metadata are created together in a single step.
+type localBlobStorage struct {
+	root string
+}
+
+func newLocalBlobStorage(uri *url.URL) (*localBlobStorage, error) {
+	if uri.Host != "" && uri.Host != "localhost" {
+		return nil, fmt.Errorf("file URIs must refer to the local machine, but the host is '%s'", uri.Host)
+	}
+
+	return &localBlobStorage{root: localPathFromUrl(uri)}, nil
+}
+
+// localPathFromUrl converts a file:// URL to a local filesystem path.
+func localPathFromUrl(u *url.URL) string {
+	p := u.Path
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		// file:///C:/dir has the path /C:/dir
+		p = p[1:]
+	}
+
+	return filepath.FromSlash(p)
+}
+
+func (s *localBlobStorage) blobPath(blobUri string) (string, error) {
+	u, err := url.Parse(blobUri)
+	if err != nil {
+		return "", err
+	}
+
+	blobPath := localPathFromUrl(u)
+	if rel, err := filepath.Rel(s.root, blobPath); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("the path %s is not in the buffer directory %s", blobPath, s.root)
+	}
+
+	return blobPath, nil
+}
+
+func (s *localBlobStorage) putBlob(ctx context.Context, blobUri string, contents []byte, encodedMD5Hash string, encodedHashChain string, uncompressedLength int64) error {
+	blobPath, err := s.blobPath(blobUri)
+	if err != nil {
+		return err
+	}
+
+	if err := checkMD5(contents, encodedMD5Hash); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(blobPath); err == nil {
+		return errBlobOverwrite
+	}
+
+	metadata := map[string]string{localMD5HeaderName: encodedMD5Hash}
+	if encodedHashChain != "" {
+		metadata[hashChainMetadataName] = encodedHashChain
+	}
+	if uncompressedLength > 0 {
+		metadata[uncompressedLengthMetadataName] = strconv.FormatInt(uncompressedLength, 10)
+	}
+
+	header, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal blob metadata: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Linking fails if the blob already exists, so a blob and its metadata are never overwritten even with concurrent writers.
+	tempPath, err := writeLocalTempFile(blobPath, append(append(header, '\n'), contents...))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+
+	if err := os.Link(tempPath, blobPath); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return errBlobOverwrite
+		}
+		return fmt.Errorf("failed to create blob file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *localBlobStorage) getBlob(ctx context.Context, blobUri string) (*readData, error) {
+	blobPath, err := s.blobPath(blobUri)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.ReadFile(blobPath)
+	if err != nil {
+		return nil, s.notFoundError(err)
+	}
+
+	header, contents, found := bytes.Cut(file, []byte{'\n'})
+	if !found {
+		return nil, fmt.Errorf("the blob %s is missing its metadata", blobPath)
+	}
+
+	info, err := parseLocalBlobHeader(blobPath, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMD5(contents, info.EncodedMD5Hash); err != nil {
+		return nil, err
+	}
+
+	return &readData{
+		Data:               contents,
+		EncodedMD5Hash:     info.EncodedMD5Hash,
+		EncodedHashChain:   info.EncodedHashChain,
+		UncompressedLength: info.UncompressedLength,
+	}, nil
+}
+
+func (s *localBlobStorage) getBlobInfo(ctx context.Context, blobUri string) (blobInfo, error) {
+	blobPath, err := s.blobPath(blobUri)
+	if err != nil {
+		return blobInfo{}, err
+	}
+
+	file, err := os.Open(blobPath)
+	if err != nil {
+		return blobInfo{}, s.notFoundError(err)
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return blobInfo{}, err
+	}
+
+	header, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return blobInfo{}, fmt.Errorf("the blob %s is missing its metadata", blobPath)
+	}
+
+	info, err := parseLocalBlobHeader(blobPath, header)
+	if err != nil {
+		return blobInfo{}, err
+	}
+
+	info.Size = fi.Size() - int64(len(header))
+	return info, nil
+}
+
+// notFoundError maps a missing file to ErrNotFound, or to errBufferDoesNotExist if the directory of the buffer is missing.
+func (s *localBlobStorage) notFoundError(err error) error {
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if _, err := os.Stat(s.root); errors.Is(err, fs.ErrNotExist) {
+		return errBufferDoesNotExist
+	}
+
+	return ErrNotFound
+}
+
+// parseLocalBlobHeader parses the line of JSON at the start of a local blob file.
+func parseLocalBlobHeader(blobPath string, header []byte) (blobInfo, error) {
+	metadata := map[string]string{}
+	if err := json.Unmarshal(header, &metadata); err != nil {
+		return blobInfo{}, fmt.Errorf("failed to parse the metadata of blob %s: %w", blobPath, err)
+	}
+
+	info := blobInfo{
+		EncodedMD5Hash:   metadata[localMD5HeaderName],
+		EncodedHashChain: metadata[hashChainMetadataName],
+	}
+	if value, ok := metadata[uncompressedLengthMetadataName]; ok {
+		var err error
+		if info.UncompressedLength, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return blobInfo{}, fmt.Errorf("invalid uncompressed length for blob %s: %w", blobPath, err)
+		}
+	}
+
+	return info, nil
+}
+
+// writeLocalTempFile writes the contents to a new temporary file in the same directory as the given path.
+func writeLocalTempFile(path string, contents []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	_, err = f.Write(contents)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	return f.Name(), nil
+}
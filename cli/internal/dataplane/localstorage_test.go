@@ -0,0 +1,170 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLocalBufferUri(t *testing.T) (string, string) {
+	dir := filepath.Join(t.TempDir(), "buffer")
+	return (&url.URL{Scheme: FileUriScheme, Path: filepath.ToSlash(dir)}).String(), dir
+}
+
+func encodeMD5(contents []byte) string {
+	hash := md5.Sum(contents)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func readLocalEndMetadata(t *testing.T, uri string) BufferEndMetadata {
+	container, err := NewContainer(uri, nil)
+	require.NoError(t, err)
+	data, err := container.storage.getBlob(context.Background(), container.GetEndMetadataUri())
+	require.NoError(t, err)
+
+	endMetadata := BufferEndMetadata{}
+	require.NoError(t, json.Unmarshal(data.Data, &endMetadata))
+	return endMetadata
+}
+
+func TestLocalBufferRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	uri, dir := newLocalBufferUri(t)
+
+	input := make([]byte, 10*1024+17)
+	_, err := rand.Read(input)
+	require.NoError(t, err)
+
+	err = Write(context.Background(), uri, bytes.NewReader(input), WithWriteBlockSize(1024))
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(dir, StartMetadataBlobName))
+	assert.FileExists(t, filepath.Join(dir, EndMetadataBlobName))
+	assert.FileExists(t, filepath.Join(dir, "00", "000"))
+	entries, err := os.ReadDir(filepath.Join(dir, "00"))
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".", "each blob should be a single file")
+	}
+
+	output := &bytes.Buffer{}
+	err = Read(context.Background(), uri, output)
+	require.NoError(t, err)
+	assert.Equal(t, input, output.Bytes())
+
+	output.Reset()
+	err = ReadRange(context.Background(), uri, output, 1000, 2000)
+	require.NoError(t, err)
+	assert.Equal(t, input[1000:3000], output.Bytes())
+
+	result, err := Verify(context.Background(), uri)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, int64(11), result.BlobCount)
+	assert.Equal(t, int64(len(input)), result.TotalBytes)
+}
+
+func TestLocalBufferReadRangePastEnd(t *testing.T) {
+	t.Parallel()
+
+	uri, _ := newLocalBufferUri(t)
+	err := Write(context.Background(), uri, bytes.NewReader(make([]byte, 3000)), WithWriteBlockSize(1024))
+	require.NoError(t, err)
+
+	output := &bytes.Buffer{}
+	err = ReadRange(context.Background(), uri, output, 3001, -1)
+	require.ErrorContains(t, err, "past the end of the buffer")
+	assert.Empty(t, output.Bytes())
+
+	err = ReadRange(context.Background(), uri, output, 2000, 5000)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, output.Len())
+}
+
+func TestLocalBufferCannotBeOverwritten(t *testing.T) {
+	t.Parallel()
+
+	uri, _ := newLocalBufferUri(t)
+
+	err := Write(context.Background(), uri, bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+
+	err = Write(context.Background(), uri, bytes.NewReader([]byte("world")))
+	assert.ErrorContains(t, err, "buffer cannot be overwritten")
+}
+
+func TestLocalBlobCannotBeOverwritten(t *testing.T) {
+	t.Parallel()
+
+	uri, _ := newLocalBufferUri(t)
+	container, err := NewContainer(uri, nil)
+	require.NoError(t, err)
+
+	blobUri := container.GetBlobUri(0)
+	first := []byte("hello")
+	require.NoError(t, container.storage.putBlob(context.Background(), blobUri, first, encodeMD5(first), "first", 0))
+
+	second := []byte("world")
+	err = container.storage.putBlob(context.Background(), blobUri, second, encodeMD5(second), "second", 0)
+	require.ErrorIs(t, err, errBlobOverwrite)
+
+	data, err := container.storage.getBlob(context.Background(), blobUri)
+	require.NoError(t, err)
+	assert.Equal(t, first, data.Data)
+	assert.Equal(t, "first", data.EncodedHashChain)
+
+	info, err := container.storage.getBlobInfo(context.Background(), blobUri)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(first)), info.Size)
+	assert.Equal(t, "first", info.EncodedHashChain)
+}
+
+func TestLocalBufferCorruptBlob(t *testing.T) {
+	t.Parallel()
+
+	uri, dir := newLocalBufferUri(t)
+
+	err := Write(context.Background(), uri, bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+
+	blobPath := filepath.Join(dir, "00", "000")
+	contents, err := os.ReadFile(blobPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(blobPath, bytes.Replace(contents, []byte("hello"), []byte("jello"), 1), 0644))
+
+	result, err := Verify(context.Background(), uri)
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	require.NotNil(t, result.FirstInvalidBlob)
+	assert.Equal(t, int64(0), *result.FirstInvalidBlob)
+}
+
+func TestLocalBufferDoesNotExist(t *testing.T) {
+	t.Parallel()
+
+	uri, _ := newLocalBufferUri(t)
+
+	err := ReadRange(context.Background(), uri, &bytes.Buffer{}, 0, 10)
+	assert.ErrorIs(t, err, errBufferDoesNotExist)
+}
+
+func TestFileUriMustBeLocal(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewContainer("file://otherhost/buffer", nil)
+	assert.ErrorContains(t, err, "file URIs must refer to the local machine")
+}
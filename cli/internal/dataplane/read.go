@@ -4,16 +4,12 @@
 package dataplane
 
 import (
-	"bytes"
 	"context"
-	"crypto/md5"
-	"crypto/sha256"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -37,8 +33,9 @@ var (
 )
 
 type readOptions struct {
-	dop        int
-	httpClient *retryablehttp.Client
+	dop         int
+	httpClient  *retryablehttp.Client
+	keyProvider KeyProvider
 }
 
 type ReadOption func(o *readOptions)
@@ -55,6 +52,13 @@ func WithReadHttpClient(httpClient *retryablehttp.Client) ReadOption {
 	}
 }
 
+// The key provider is used to unwrap the data key of an encrypted buffer.
+func WithReadKeyProvider(keyProvider KeyProvider) ReadOption {
+	return func(o *readOptions) {
+		o.keyProvider = keyProvider
+	}
+}
+
 func Read(ctx context.Context, uri string, outputWriter io.Writer, options ...ReadOption) error {
 	readOptions := &readOptions{
 		dop: DefaultReadDop,
@@ -79,7 +83,13 @@ func Read(ctx context.Context, uri string, outputWriter io.Writer, options ...Re
 		log.Ctx(ctx).Fatal().Err(err).Msg("invalid URL:")
 	}
 
-	if err := readBufferStart(ctx, httpClient, container); err != nil {
+	bufferStartMetadata, err := readBufferStart(ctx, container)
+	if err != nil {
+		return err
+	}
+
+	encoding, err := getBlobEncoding(bufferStartMetadata, readOptions.keyProvider)
+	if err != nil {
 		return err
 	}
 
@@ -89,7 +99,7 @@ func Read(ctx context.Context, uri string, outputWriter io.Writer, options ...Re
 	waitForBlobs.Store(true)
 
 	go func() {
-		err := pollForBufferEnd(ctx, httpClient, container)
+		err := pollForBufferEnd(ctx, container)
 		if err != nil {
 			errorChannel <- err
 			return
@@ -123,7 +133,7 @@ func Read(ctx context.Context, uri string, outputWriter io.Writer, options ...Re
 
 				blobUri := container.GetBlobUri(blobNumber)
 				ctx := log.Ctx(ctx).With().Int64("blobNumber", blobNumber).Logger().WithContext(ctx)
-				respData, err := DownloadBlob(ctx, httpClient, blobUri, &waitForBlobs, &blobNumber, &finalBlobNumber)
+				respData, err := DownloadBlob(ctx, container, blobUri, &waitForBlobs, &blobNumber, &finalBlobNumber)
 				if err != nil {
 					if err == errPastEndOfBlob {
 						break
@@ -139,18 +149,23 @@ func Read(ctx context.Context, uri string, outputWriter io.Writer, options ...Re
 				}
 				metrics.Update(uint64(len(respData.Data)))
 
-				md5Header := respData.Header.Get(ContentMD5Header)
-				if md5Header == "" {
-					panic("Content-MD5 header missing. This should have already been checked")
-				}
-
-				hashChainHeader := respData.Header.Get(HashChainHeader)
+				hashChainHeader := respData.EncodedHashChain
 				if hashChainHeader == "" {
 					errorChannel <- &responseBodyReadError{reason: fmt.Errorf("expected %s header missing", HashChainHeader)}
 					return
 				}
 
-				c <- BufferBlob{BlobNumber: blobNumber, Contents: respData.Data, EncodedMD5Hash: md5Header, EncodedMD5ChainHash: hashChainHeader}
+				contents := respData.Data
+				if !encoding.isIdentity() && len(contents) > 0 {
+					contents, err = encoding.decode(blobNumber, respData.Data, respData.UncompressedLength)
+					pool.Put(respData.Data)
+					if err != nil {
+						errorChannel <- err
+						return
+					}
+				}
+
+				c <- BufferBlob{BlobNumber: blobNumber, Contents: contents, EncodedMD5Hash: respData.EncodedMD5Hash, EncodedMD5ChainHash: hashChainHeader}
 			}
 		}()
 	}
@@ -186,8 +201,7 @@ func Read(ctx context.Context, uri string, outputWriter io.Writer, options ...Re
 
 			pool.Put(blobResponse.Contents)
 
-			hashChain := sha256.Sum256([]byte(encodedHashChain + blobResponse.EncodedMD5Hash))
-			encodedHashChain = base64.StdEncoding.EncodeToString(hashChain[:])
+			encodedHashChain = calculateNextHashChain(encodedHashChain, blobResponse.EncodedMD5Hash)
 
 			if blobResponse.EncodedMD5ChainHash != encodedHashChain {
 				errorChannel <- errors.New("hash chain mismatch")
@@ -211,31 +225,36 @@ func Read(ctx context.Context, uri string, outputWriter io.Writer, options ...Re
 	}
 }
 
-func readBufferStart(ctx context.Context, httpClient *retryablehttp.Client, container *Container) error {
+func readBufferStart(ctx context.Context, container *Container) (*BufferStartMetadata, error) {
 	wait := atomic.Bool{}
 	wait.Store(true)
 
-	data, err := DownloadBlob(ctx, httpClient, container.GetStartMetadataUri(), &wait, nil, nil)
+	data, err := DownloadBlob(ctx, container, container.GetStartMetadataUri(), &wait, nil, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
+
+	return parseBufferStartMetadata(data.Data)
+}
+
+func parseBufferStartMetadata(data []byte) (*BufferStartMetadata, error) {
 	bufferStartMetadata := BufferStartMetadata{}
-	if err := json.Unmarshal(data.Data, &bufferStartMetadata); err != nil {
-		return fmt.Errorf("failed to unmarshal buffer start metadata: %w", err)
+	if err := json.Unmarshal(data, &bufferStartMetadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal buffer start metadata: %w", err)
 	}
-	if bufferStartMetadata.Version != CurrentBufferFormatVersion {
-		return fmt.Errorf("unsupported format buffer version '%s'. Expected '%s", bufferStartMetadata.Version, CurrentBufferFormatVersion)
+	if !slices.Contains(supportedBufferFormatVersions, bufferStartMetadata.Version) {
+		return nil, fmt.Errorf("unsupported format buffer version '%s'. Expected one of %v", bufferStartMetadata.Version, supportedBufferFormatVersions)
 	}
 
-	return nil
+	return &bufferStartMetadata, nil
 }
 
-func pollForBufferEnd(ctx context.Context, httpClient *retryablehttp.Client, container *Container) error {
+func pollForBufferEnd(ctx context.Context, container *Container) error {
 	wait := atomic.Bool{}
 	wait.Store(false)
 
 	for ctx.Err() == nil {
-		data, err := DownloadBlob(ctx, httpClient, container.GetEndMetadataUri(), &wait, nil, nil)
+		data, err := DownloadBlob(ctx, container, container.GetEndMetadataUri(), &wait, nil, nil)
 		if err != nil {
 			if err == ErrNotFound {
 				time.Sleep(5 * time.Second)
@@ -262,11 +281,7 @@ func pollForBufferEnd(ctx context.Context, httpClient *retryablehttp.Client, con
 	return nil
 }
 
-func DownloadBlob(ctx context.Context, httpClient *retryablehttp.Client, blobUri string, waitForBlob *atomic.Bool, blobNumber *int64, finalBlobNumber *atomic.Int64) (*readData, error) {
-	// The last error that occurred relating to reading the body. retryablehttp does not retry when these happen
-	// because reading the body happens after the call to HttpClient.Do()
-	var lastBodyReadError *responseBodyReadError
-
+func DownloadBlob(ctx context.Context, container *Container, blobUri string, waitForBlob *atomic.Bool, blobNumber *int64, finalBlobNumber *atomic.Int64) (*readData, error) {
 	for retryCount := 0; ; retryCount++ {
 		start := time.Now()
 
@@ -282,24 +297,10 @@ func DownloadBlob(ctx context.Context, httpClient *retryablehttp.Client, blobUri
 			}
 		}
 
-		req, err := retryablehttp.NewRequest(http.MethodGet, blobUri, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		req = req.WithContext(ctx)
-
-		AddCommonBlobRequestHeaders(req.Header)
-
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			return nil, httpclient.RedactHttpError(err)
-		}
-
-		respData, err := handleReadResponse(ctx, resp)
+		respData, err := container.storage.getBlob(ctx, blobUri)
 		if err == nil {
 			log.Ctx(ctx).Trace().
-				Int("contentLength", int(resp.ContentLength)).
+				Int("contentLength", len(respData.Data)).
 				Dur("duration", time.Since(start)).
 				Msg("Downloaded blob")
 
@@ -314,25 +315,13 @@ func DownloadBlob(ctx context.Context, httpClient *retryablehttp.Client, blobUri
 				log.Ctx(ctx).Debug().Msg("MD5 mismatch, retrying")
 				continue
 			} else {
-				return nil, fmt.Errorf("failed to read blob: %w", httpclient.RedactHttpError(err))
+				return nil, fmt.Errorf("failed to read blob: %w", err)
 			}
 		}
 		if err == ErrNotFound {
 			if waitForBlobSnapshot {
 				log.Ctx(ctx).Trace().Msg("Waiting for blob")
-				io.Copy(io.Discard, resp.Body)
-				resp.Body.Close()
-
-				switch {
-				case retryCount < 10:
-					time.Sleep(100 * time.Millisecond)
-				case retryCount < 100:
-					time.Sleep(500 * time.Millisecond)
-				case retryCount < 1000:
-					time.Sleep(1 * time.Second)
-				default:
-					time.Sleep(5 * time.Second)
-				}
+				time.Sleep(missingBlobRetryDelay(retryCount))
 
 				continue
 			}
@@ -355,100 +344,20 @@ func DownloadBlob(ctx context.Context, httpClient *retryablehttp.Client, blobUri
 			return nil, err
 		}
 
-		if errors.Is(err, ctx.Err()) {
-			// the context has been canceled
-			return nil, err
-		}
-		if err, ok := err.(*responseBodyReadError); ok {
-			if lastBodyReadError == nil {
-				lastBodyReadError = err
-				retryCount = 0
-			}
-
-			if retryCount < MaxRetries {
-				log.Ctx(ctx).Warn().Err(err).Msg("Error reading response body, retrying")
-
-				// wait in the same way as retryablehttp
-				wait := httpClient.Backoff(httpClient.RetryWaitMin, httpClient.RetryWaitMax, retryCount, resp)
-				timer := time.NewTimer(wait)
-				select {
-				case <-req.Context().Done():
-					timer.Stop()
-					httpClient.HTTPClient.CloseIdleConnections()
-					return nil, req.Context().Err()
-				case <-timer.C:
-				}
-
-				continue
-			}
-		}
-
 		return nil, httpclient.RedactHttpError(err)
 	}
 }
 
-type readData struct {
-	Data   []byte
-	Header http.Header
-}
-
-func handleReadResponse(ctx context.Context, resp *http.Response) (*readData, error) {
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		if resp.ContentLength < 0 {
-			io.Copy(io.Discard, resp.Body)
-			return nil, &responseBodyReadError{reason: errors.New("expected Content-Length header missing")}
-		}
-
-		buf := pool.Get(int(resp.ContentLength))
-		_, err := io.ReadFull(resp.Body, buf)
-		if err != nil {
-			// return the buffer to the pool
-			pool.Put(buf)
-			return nil, &responseBodyReadError{reason: err}
-		}
-
-		calculatedMd5 := md5.Sum(buf)
-		md5Header := resp.Header.Get(ContentMD5Header)
-		if md5Header == "" {
-			pool.Put(buf)
-			return nil, errors.New("expected Content-MD5 header missing")
-		}
-
-		md5Bytes, _ := base64.StdEncoding.DecodeString(md5Header)
-		if !bytes.Equal(calculatedMd5[:], md5Bytes) {
-			pool.Put(buf)
-			return nil, errMd5Mismatch
-		}
-
-		response := readData{Data: buf, Header: resp.Header}
-
-		return &response, nil
-	case http.StatusNotFound:
-		io.Copy(io.Discard, resp.Body)
-		switch resp.Header.Get("x-ms-error-code") {
-		case "BlobNotFound":
-			return nil, ErrNotFound
-		case "ContainerNotFound":
-			return nil, errBufferDoesNotExist
-		}
-		fallthrough
+// missingBlobRetryDelay returns how long to wait before checking again for a blob that has not yet been written.
+func missingBlobRetryDelay(retryCount int) time.Duration {
+	switch {
+	case retryCount < 10:
+		return 100 * time.Millisecond
+	case retryCount < 100:
+		return 500 * time.Millisecond
+	case retryCount < 1000:
+		return 1 * time.Second
 	default:
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(bodyBytes))
+		return 5 * time.Second
 	}
 }
-
-type responseBodyReadError struct {
-	reason error
-}
-
-func (e *responseBodyReadError) Error() string {
-	return fmt.Sprintf("error reading response body: %v", e.reason)
-}
-
-func (e *responseBodyReadError) Unwrap() error {
-	return e.reason
-}
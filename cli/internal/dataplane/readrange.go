@@ -0,0 +1,294 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"time"
+
+	pool "github.com/libp2p/go-buffer-pool"
+	"github.com/microsoft/tyger/cli/internal/httpclient"
+	"github.com/rs/zerolog/log"
+)
+
+// The portion of a blob that falls within the requested range.
+type blobRange struct {
+	blobInfo
+	start int64
+	end   int64
+}
+
+type blobRangeResponse struct {
+	data  []byte
+	start int64
+	end   int64
+	err   error
+}
+
+// ReadRange writes length bytes of the buffer starting at offset to outputWriter. If length is negative,
+// the buffer is read to the end. Only the blobs covering the range are downloaded, but the hash chain is
+// verified from the start of the buffer up to the end of the range using the blob metadata.
+func ReadRange(ctx context.Context, uri string, outputWriter io.Writer, offset int64, length int64, options ...ReadOption) error {
+	if offset < 0 {
+		return errors.New("the offset cannot be negative")
+	}
+
+	readOptions := &readOptions{
+		dop: DefaultReadDop,
+	}
+	for _, o := range options {
+		o(readOptions)
+	}
+
+	if readOptions.httpClient == nil {
+		readOptions.httpClient = httpclient.NewRetryableClient()
+		readOptions.httpClient.HTTPClient.Timeout = ResponseTimeout
+	}
+
+	httpClient := readOptions.httpClient
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx = log.With().Str("operation", "buffer read").Logger().WithContext(ctx)
+	container, err := NewContainer(uri, httpClient)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	bufferStartMetadata, err := readBufferStart(ctx, container)
+	if err != nil {
+		return err
+	}
+
+	encoding, err := getBlobEncoding(bufferStartMetadata, readOptions.keyProvider)
+	if err != nil {
+		return err
+	}
+
+	errorChannel := make(chan error, 3)
+
+	waitForBlobs := atomic.Bool{}
+	waitForBlobs.Store(true)
+
+	go func() {
+		err := pollForBufferEnd(ctx, container)
+		if err != nil {
+			errorChannel <- err
+			return
+		}
+		// All blobs should have been written successfully by now.
+		waitForBlobs.Store(false)
+	}()
+
+	rangesChannel := make(chan blobRange, readOptions.dop)
+	go func() {
+		defer close(rangesChannel)
+		if err := findBlobsInRange(ctx, container, encoding, offset, length, readOptions.dop, &waitForBlobs, rangesChannel); err != nil {
+			errorChannel <- err
+		}
+	}()
+
+	metrics := TransferMetrics{
+		Context:   ctx,
+		Container: container,
+	}
+	metrics.Start()
+
+	// Downloads happen concurrently, but the responses are written to the output in order.
+	responseChannel := make(chan chan blobRangeResponse, readOptions.dop)
+	go func() {
+		defer close(responseChannel)
+		for r := range rangesChannel {
+			c := make(chan blobRangeResponse, 1)
+			select {
+			case responseChannel <- c:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(r blobRange) {
+				ctx := log.Ctx(ctx).With().Int64("blobNumber", r.BlobNumber).Logger().WithContext(ctx)
+				respData, err := DownloadBlob(ctx, container, container.GetBlobUri(r.BlobNumber), &atomic.Bool{}, nil, nil)
+				if err != nil {
+					c <- blobRangeResponse{err: fmt.Errorf("error downloading blob: %w", err)}
+					return
+				}
+
+				if respData.EncodedMD5Hash != r.EncodedMD5Hash {
+					pool.Put(respData.Data)
+					c <- blobRangeResponse{err: fmt.Errorf("blob %d was modified while being read", r.BlobNumber)}
+					return
+				}
+
+				metrics.Update(uint64(len(respData.Data)))
+
+				data := respData.Data
+				if !encoding.isIdentity() {
+					data, err = encoding.decode(r.BlobNumber, respData.Data, respData.UncompressedLength)
+					pool.Put(respData.Data)
+					if err != nil {
+						c <- blobRangeResponse{err: err}
+						return
+					}
+				}
+
+				c <- blobRangeResponse{data: data, start: r.start, end: r.end}
+			}(r)
+		}
+	}()
+
+	doneChan := make(chan any)
+	go func() {
+		for c := range responseChannel {
+			response := <-c
+			if response.err != nil {
+				errorChannel <- response.err
+				return
+			}
+
+			if _, err := outputWriter.Write(response.data[response.start:response.end]); err != nil {
+				errorChannel <- fmt.Errorf("error writing to output: %w", err)
+				return
+			}
+
+			pool.Put(response.data)
+		}
+
+		close(doneChan)
+	}()
+
+	select {
+	case <-doneChan:
+		// findBlobsInRange may have failed after sending its last range
+		select {
+		case err := <-errorChannel:
+			return err
+		default:
+		}
+		metrics.Stop()
+		return nil
+	case err := <-errorChannel:
+		return err
+	}
+}
+
+// findBlobsInRange walks the blobs of the buffer in order, verifying the hash chain using only the blob metadata,
+// and sends the portions of the blobs that overlap with the range to rangesChannel.
+func findBlobsInRange(ctx context.Context, container *Container, encoding *blobEncoding, offset int64, length int64, dop int, waitForBlobs *atomic.Bool, rangesChannel chan<- blobRange) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	end := offset + length
+	if length < 0 {
+		end = math.MaxInt64
+	}
+
+	// Fetch the blob metadata concurrently, but process it in order.
+	infoChannel := make(chan chan blobInfo, dop)
+	go func() {
+		defer close(infoChannel)
+		for blobNumber := int64(0); ; blobNumber++ {
+			c := make(chan blobInfo, 1)
+			select {
+			case infoChannel <- c:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(blobNumber int64) {
+				ctx := log.Ctx(ctx).With().Int64("blobNumber", blobNumber).Logger().WithContext(ctx)
+				c <- waitForBlobInfo(ctx, container, blobNumber, waitForBlobs)
+			}(blobNumber)
+		}
+	}()
+
+	encodedHashChain := EncodedHashChainInitialValue
+	var position int64
+	for c := range infoChannel {
+		info := <-c
+		if info.Error != nil {
+			return info.Error
+		}
+
+		encodedHashChain = calculateNextHashChain(encodedHashChain, info.EncodedMD5Hash)
+		if info.EncodedHashChain != encodedHashChain {
+			return errors.New("hash chain mismatch")
+		}
+
+		if info.Size == 0 {
+			if offset > position {
+				return fmt.Errorf("the offset %d is past the end of the buffer, which is %d bytes long", offset, position)
+			}
+			return nil
+		}
+
+		// The range is in terms of the decoded contents
+		size, err := encoding.decodedSize(info)
+		if err != nil {
+			return err
+		}
+
+		blobEnd := position + size
+		if blobEnd > offset && position < end {
+			r := blobRange{
+				blobInfo: info,
+				start:    max(offset, position) - position,
+				end:      min(end, blobEnd) - position,
+			}
+
+			select {
+			case rangesChannel <- r:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		position = blobEnd
+		if position >= end {
+			return nil
+		}
+	}
+
+	return ctx.Err()
+}
+
+// waitForBlobInfo gets the metadata of a blob, waiting for it to be written if the buffer is not yet complete.
+func waitForBlobInfo(ctx context.Context, container *Container, blobNumber int64, waitForBlobs *atomic.Bool) blobInfo {
+	for retryCount := 0; ; retryCount++ {
+		// See DownloadBlob for why we take a snapshot before issuing the request.
+		waitForBlobSnapshot := waitForBlobs.Load()
+
+		info, err := container.storage.getBlobInfo(ctx, container.GetBlobUri(blobNumber))
+		if err == nil {
+			info.BlobNumber = blobNumber
+			if info.EncodedMD5Hash == "" {
+				info.Error = errors.New("expected Content-MD5 header missing")
+			} else if info.EncodedHashChain == "" {
+				info.Error = fmt.Errorf("expected %s header missing", HashChainHeader)
+			}
+			return info
+		}
+
+		if err != ErrNotFound {
+			return blobInfo{BlobNumber: blobNumber, Error: err}
+		}
+
+		if !waitForBlobSnapshot {
+			return blobInfo{BlobNumber: blobNumber, Error: fmt.Errorf("blob number %d was expected to exist but does not", blobNumber)}
+		}
+
+		log.Ctx(ctx).Trace().Msg("Waiting for blob")
+		select {
+		case <-ctx.Done():
+			return blobInfo{BlobNumber: blobNumber, Error: ctx.Err()}
+		case <-time.After(missingBlobRetryDelay(retryCount)):
+		}
+	}
+}
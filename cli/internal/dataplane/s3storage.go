@@ -0,0 +1,360 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/microsoft/tyger/cli/internal/httpclient"
+)
+
+const (
+	// s3://host[:port]/bucket/prefix refers to buffers in an S3-compatible store served over HTTPS.
+	S3UriScheme = "s3"
+
+	// s3+http://host[:port]/bucket/prefix is for stores served over plain HTTP, such as a local MinIO container.
+	S3HttpUriScheme = "s3+http"
+
+	s3DefaultRegion = "us-east-1"
+
+	// How long the presigned URL of each request is valid for.
+	s3PresignExpiry = 15 * time.Minute
+
+	s3MetadataHeaderPrefix       = "x-amz-meta-"
+	s3ContentMD5MetadataHeader   = s3MetadataHeaderPrefix + "content-md5"
+	s3HashChainMetadataHeader    = s3MetadataHeaderPrefix + "cumulative-hash-chain"
+	s3UncompressedLengthMetadata = s3MetadataHeaderPrefix + "uncompressed-length"
+)
+
+type s3Credentials struct {
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// s3BlobStorage stores blobs as objects in an S3-compatible store, using path-style addressing.
+// Each request is sent to a URL presigned with AWS Signature Version 4. Blobs are created with
+// If-None-Match: * so that existing objects are never overwritten, and the MD5 hash of each blob
+// is kept in its metadata since S3 does not return the Content-MD5 of an object.
+type s3BlobStorage struct {
+	httpClient  *retryablehttp.Client
+	scheme      string
+	credentials s3Credentials
+	region      string
+}
+
+// newS3BlobStorage creates the storage for an s3:// or s3+http:// URI. The credentials are read from
+// the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+// The region is given by the URI's region query parameter or the AWS_REGION environment variable.
+func newS3BlobStorage(uri *url.URL, httpClient *retryablehttp.Client) (*s3BlobStorage, error) {
+	if uri.Host == "" || strings.Trim(uri.Path, "/") == "" {
+		return nil, errors.New("S3 URIs must be of the form s3://host[:port]/bucket[/prefix]")
+	}
+
+	credentials := s3Credentials{
+		accessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.accessKeyId == "" || credentials.secretAccessKey == "" {
+		return nil, errors.New("the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables must be set to access buffers in S3")
+	}
+
+	region := uri.Query().Get("region")
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = s3DefaultRegion
+	}
+
+	scheme := "https"
+	if uri.Scheme == S3HttpUriScheme {
+		scheme = "http"
+	}
+
+	return &s3BlobStorage{
+		httpClient:  httpClient,
+		scheme:      scheme,
+		credentials: credentials,
+		region:      region,
+	}, nil
+}
+
+// newRequest creates a request with a presigned URL for the object of a blob. All of the
+// given headers are signed.
+func (s *s3BlobStorage) newRequest(ctx context.Context, method string, blobUri string, header http.Header, body any) (*retryablehttp.Request, error) {
+	u, err := url.Parse(blobUri)
+	if err != nil {
+		return nil, err
+	}
+
+	objectUrl := &url.URL{Scheme: s.scheme, Host: u.Host, Path: u.Path}
+	presignedUrl := presignS3Url(method, objectUrl, header, s.credentials, s.region, time.Now(), s3PresignExpiry)
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, method, presignedUrl.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	return req, nil
+}
+
+func (s *s3BlobStorage) putBlob(ctx context.Context, blobUri string, contents []byte, encodedMD5Hash string, encodedHashChain string, uncompressedLength int64) error {
+	header := http.Header{}
+	header.Set(ContentMD5Header, encodedMD5Hash)
+	header.Set("If-None-Match", "*")
+	header.Set(s3ContentMD5MetadataHeader, encodedMD5Hash)
+	if encodedHashChain != "" {
+		header.Set(s3HashChainMetadataHeader, encodedHashChain)
+	}
+	if uncompressedLength > 0 {
+		header.Set(s3UncompressedLengthMetadata, strconv.FormatInt(uncompressedLength, 10))
+	}
+
+	var body any = contents
+	if len(contents) == 0 {
+		body = nil
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, blobUri, header, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send request: %w", httpclient.RedactHttpError(err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		// A 409 is returned when a concurrent conditional write for the same object is in progress.
+		io.Copy(io.Discard, resp.Body)
+		return errBlobOverwrite
+	}
+
+	s3Err := readS3Error(resp)
+	switch s3Err.Code {
+	case "BadDigest":
+		return errMd5Mismatch
+	case "NoSuchBucket":
+		return errBufferDoesNotExist
+	default:
+		return s3Err
+	}
+}
+
+func (s *s3BlobStorage) getBlob(ctx context.Context, blobUri string) (*readData, error) {
+	newRequest := func() (*retryablehttp.Request, error) {
+		return s.newRequest(ctx, http.MethodGet, blobUri, nil, nil)
+	}
+
+	handleResponse := func(resp *http.Response) (*readData, error) {
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			s3Err := readS3Error(resp)
+			switch s3Err.Code {
+			case "NoSuchKey":
+				return nil, ErrNotFound
+			case "NoSuchBucket":
+				return nil, errBufferDoesNotExist
+			default:
+				return nil, s3Err
+			}
+		}
+
+		info := s3BlobInfo(resp)
+		buf, err := readBlobResponseBody(resp, info.EncodedMD5Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return &readData{
+			Data:               buf,
+			EncodedMD5Hash:     info.EncodedMD5Hash,
+			EncodedHashChain:   info.EncodedHashChain,
+			UncompressedLength: info.UncompressedLength,
+		}, nil
+	}
+
+	return getBlobWithRetry(ctx, s.httpClient, newRequest, handleResponse)
+}
+
+func (s *s3BlobStorage) getBlobInfo(ctx context.Context, blobUri string) (blobInfo, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, blobUri, nil, nil)
+	if err != nil {
+		return blobInfo{}, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return blobInfo{}, fmt.Errorf("HEAD request failed: %w", httpclient.RedactHttpError(err))
+	}
+
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return s3BlobInfo(resp), nil
+	case http.StatusNotFound:
+		// Responses to HEAD requests have no body, so a missing bucket cannot be told apart from a missing object.
+		return blobInfo{}, ErrNotFound
+	default:
+		return blobInfo{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+}
+
+func s3BlobInfo(resp *http.Response) blobInfo {
+	info := blobInfo{
+		Size:             resp.ContentLength,
+		EncodedMD5Hash:   resp.Header.Get(s3ContentMD5MetadataHeader),
+		EncodedHashChain: resp.Header.Get(s3HashChainMetadataHeader),
+	}
+	if uncompressedLength, err := strconv.ParseInt(resp.Header.Get(s3UncompressedLengthMetadata), 10, 64); err == nil {
+		info.UncompressedLength = uncompressedLength
+	}
+
+	return info
+}
+
+type s3Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func readS3Error(resp *http.Response) *s3Error {
+	s3Err := &s3Error{StatusCode: resp.StatusCode}
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	xml.Unmarshal(bodyBytes, s3Err)
+	return s3Err
+}
+
+// presignS3Url returns the URL with the query parameters of AWS Signature Version 4 for
+// a request with the given method and headers.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func presignS3Url(method string, u *url.URL, header http.Header, credentials s3Credentials, region string, signingTime time.Time, expiry time.Duration) *url.URL {
+	amzDate := signingTime.UTC().Format("20060102T150405Z")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", amzDate[:8], region)
+
+	canonicalHeaders := map[string]string{"host": u.Host}
+	for name, values := range header {
+		canonicalHeaders[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	headerNames := make([]string, 0, len(canonicalHeaders))
+	for name := range canonicalHeaders {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	signedHeaders := strings.Join(headerNames, ";")
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    credentials.accessKeyId + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.Itoa(int(expiry.Seconds())),
+		"X-Amz-SignedHeaders": signedHeaders,
+	}
+	if credentials.sessionToken != "" {
+		query["X-Amz-Security-Token"] = credentials.sessionToken
+	}
+
+	queryNames := make([]string, 0, len(query))
+	for name := range query {
+		queryNames = append(queryNames, name)
+	}
+	sort.Strings(queryNames)
+
+	canonicalQuery := strings.Builder{}
+	for i, name := range queryNames {
+		if i > 0 {
+			canonicalQuery.WriteByte('&')
+		}
+		canonicalQuery.WriteString(s3UriEncode(name, true))
+		canonicalQuery.WriteByte('=')
+		canonicalQuery.WriteString(s3UriEncode(query[name], true))
+	}
+
+	canonicalRequest := strings.Builder{}
+	canonicalRequest.WriteString(method + "\n")
+	if u.Path == "" {
+		canonicalRequest.WriteString("/\n")
+	} else {
+		canonicalRequest.WriteString(s3UriEncode(u.Path, false) + "\n")
+	}
+	canonicalRequest.WriteString(canonicalQuery.String() + "\n")
+	for _, name := range headerNames {
+		canonicalRequest.WriteString(name + ":" + canonicalHeaders[name] + "\n")
+	}
+	canonicalRequest.WriteString("\n" + signedHeaders + "\nUNSIGNED-PAYLOAD")
+
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest.String()))
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", amzDate, scope, hex.EncodeToString(canonicalRequestHash[:]))
+
+	signingKey := hmacSha256([]byte("AWS4"+credentials.secretAccessKey), amzDate[:8])
+	signingKey = hmacSha256(signingKey, region)
+	signingKey = hmacSha256(signingKey, "s3")
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	presignedUrl := *u
+	presignedUrl.RawQuery = canonicalQuery.String() + "&X-Amz-Signature=" + signature
+	return &presignedUrl
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3UriEncode percent-encodes every byte except the unreserved characters, as required for signing.
+func s3UriEncode(s string, encodeSlash bool) string {
+	builder := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,157 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	pool "github.com/libp2p/go-buffer-pool"
+	"github.com/microsoft/tyger/cli/internal/httpclient"
+	"github.com/rs/zerolog/log"
+)
+
+// blobStorage sends the requests that store and retrieve the individual blobs of a buffer.
+// Each call is a single attempt. Retrying after an MD5 mismatch, waiting for blobs that have not
+// been written yet, and checking whether a blob that already exists has the expected contents are
+// left to the callers so that they behave the same way for every kind of storage.
+type blobStorage interface {
+	// putBlob creates a blob with the given contents, which are empty for the final blob. It returns
+	// errBlobOverwrite if the blob already exists, errMd5Mismatch if the contents received by the
+	// storage service do not match encodedMD5Hash, and errBufferDoesNotExist if the container does not exist.
+	putBlob(ctx context.Context, blobUri string, contents []byte, encodedMD5Hash string, encodedHashChain string, uncompressedLength int64) error
+
+	// getBlob downloads a blob and checks its contents against its MD5 hash. It returns ErrNotFound if
+	// the blob does not exist, errBufferDoesNotExist if the container does not exist, and errMd5Mismatch
+	// if the contents do not match the hash.
+	getBlob(ctx context.Context, blobUri string) (*readData, error)
+
+	// getBlobInfo returns the size and metadata of a blob without downloading its contents.
+	// It returns ErrNotFound if the blob does not exist.
+	getBlobInfo(ctx context.Context, blobUri string) (blobInfo, error)
+}
+
+// The contents of a downloaded blob along with its metadata.
+type readData struct {
+	Data               []byte
+	EncodedMD5Hash     string
+	EncodedHashChain   string
+	UncompressedLength int64
+}
+
+func newBlobStorage(uri *url.URL, httpClient *retryablehttp.Client) (blobStorage, error) {
+	switch uri.Scheme {
+	case FileUriScheme:
+		return newLocalBlobStorage(uri)
+	case S3UriScheme, S3HttpUriScheme:
+		return newS3BlobStorage(uri, httpClient)
+	default:
+		return &azureBlobStorage{httpClient: httpClient}, nil
+	}
+}
+
+// getBlobWithRetry sends GET requests for a blob until the response body can be read. retryablehttp does not
+// retry when reading the body fails because that happens after the call to HttpClient.Do().
+func getBlobWithRetry(ctx context.Context, httpClient *retryablehttp.Client, newRequest func() (*retryablehttp.Request, error), handleResponse func(*http.Response) (*readData, error)) (*readData, error) {
+	var lastBodyReadError *responseBodyReadError
+
+	for retryCount := 0; ; retryCount++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, httpclient.RedactHttpError(err)
+		}
+
+		respData, err := handleResponse(resp)
+		bodyReadError, ok := err.(*responseBodyReadError)
+		if !ok || errors.Is(err, ctx.Err()) {
+			return respData, err
+		}
+
+		if lastBodyReadError == nil {
+			lastBodyReadError = bodyReadError
+			retryCount = 0
+		}
+
+		if retryCount >= MaxRetries {
+			return nil, err
+		}
+
+		log.Ctx(ctx).Warn().Err(err).Msg("Error reading response body, retrying")
+
+		// wait in the same way as retryablehttp
+		wait := httpClient.Backoff(httpClient.RetryWaitMin, httpClient.RetryWaitMax, retryCount, resp)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			httpClient.HTTPClient.CloseIdleConnections()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// readBlobResponseBody reads the body of a successful GET response into a buffer obtained from
+// the pool and checks it against the blob's MD5 hash.
+func readBlobResponseBody(resp *http.Response, encodedMD5Hash string) ([]byte, error) {
+	if resp.ContentLength < 0 {
+		io.Copy(io.Discard, resp.Body)
+		return nil, &responseBodyReadError{reason: errors.New("expected Content-Length header missing")}
+	}
+
+	buf := pool.Get(int(resp.ContentLength))
+	_, err := io.ReadFull(resp.Body, buf)
+	if err != nil {
+		// return the buffer to the pool
+		pool.Put(buf)
+		return nil, &responseBodyReadError{reason: err}
+	}
+
+	if err := checkMD5(buf, encodedMD5Hash); err != nil {
+		pool.Put(buf)
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func checkMD5(contents []byte, encodedMD5Hash string) error {
+	if encodedMD5Hash == "" {
+		return errors.New("expected Content-MD5 header missing")
+	}
+
+	calculatedMd5 := md5.Sum(contents)
+	md5Bytes, _ := base64.StdEncoding.DecodeString(encodedMD5Hash)
+	if !bytes.Equal(calculatedMd5[:], md5Bytes) {
+		return errMd5Mismatch
+	}
+
+	return nil
+}
+
+type responseBodyReadError struct {
+	reason error
+}
+
+func (e *responseBodyReadError) Error() string {
+	return fmt.Sprintf("error reading response body: %v", e.reason)
+}
+
+func (e *responseBodyReadError) Unwrap() error {
+	return e.reason
+}
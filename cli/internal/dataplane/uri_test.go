@@ -53,3 +53,9 @@ func TestAccessStringIsFileWithLargeUri(t *testing.T) {
 	_, err = GetUriFromAccessString(path)
 	assert.ErrorContains(t, err, "the buffer access string is invalid")
 }
+
+func TestAccessStringIsFileUri(t *testing.T) {
+	uri, err := GetUriFromAccessString("file:///tmp/buffer")
+	assert.Nil(t, err)
+	assert.Equal(t, "file:///tmp/buffer", uri)
+}
@@ -0,0 +1,205 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	pool "github.com/libp2p/go-buffer-pool"
+	"github.com/microsoft/tyger/cli/internal/httpclient"
+	"github.com/rs/zerolog/log"
+)
+
+// VerifyResult is the outcome of verifying the integrity of a buffer.
+type VerifyResult struct {
+	Valid bool `json:"valid"`
+
+	// The status recorded in the buffer's end metadata, or empty if the buffer has not been ended.
+	Status string `json:"status"`
+
+	// The number of blobs that were verified, not counting the final empty blob.
+	BlobCount int64 `json:"blobCount"`
+
+	// The number of stored bytes that were verified.
+	TotalBytes int64 `json:"totalBytes"`
+
+	// The number of the first blob that is corrupt or missing, if any.
+	FirstInvalidBlob *int64 `json:"firstInvalidBlob,omitempty"`
+
+	// A description of why the buffer is not valid.
+	Problem string `json:"problem,omitempty"`
+}
+
+type verifiedBlob struct {
+	blobNumber       int64
+	size             int64
+	encodedMD5Hash   string
+	encodedHashChain string
+	err              error
+}
+
+// Verify downloads every blob of a buffer, checking its MD5 hash and the hash chain, and confirms that
+// the buffer has been marked as complete. Unlike Read, it does not wait for blobs to be written.
+// Since only the stored bytes are verified, no encryption key is needed. Problems with the buffer's
+// contents are reported in the result, while the returned error is for failures to perform the verification.
+func Verify(ctx context.Context, uri string, options ...ReadOption) (*VerifyResult, error) {
+	readOptions := &readOptions{
+		dop: DefaultReadDop,
+	}
+	for _, o := range options {
+		o(readOptions)
+	}
+
+	if readOptions.httpClient == nil {
+		readOptions.httpClient = httpclient.NewRetryableClient()
+		readOptions.httpClient.HTTPClient.Timeout = ResponseTimeout
+	}
+
+	httpClient := readOptions.httpClient
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx = log.With().Str("operation", "buffer verify").Logger().WithContext(ctx)
+	container, err := NewContainer(uri, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	noWait := atomic.Bool{}
+	result := &VerifyResult{}
+
+	startData, err := DownloadBlob(ctx, container, container.GetStartMetadataUri(), &noWait, nil, nil)
+	if err != nil {
+		if err == ErrNotFound {
+			result.Problem = "the buffer has not been written to"
+			return result, nil
+		}
+		return nil, err
+	}
+
+	if _, err := parseBufferStartMetadata(startData.Data); err != nil {
+		return nil, err
+	}
+
+	// The blob and byte counts recorded in the end metadata, if any.
+	var recordedBlobCount, recordedByteCount *int64
+
+	endData, err := DownloadBlob(ctx, container, container.GetEndMetadataUri(), &noWait, nil, nil)
+	if err == nil {
+		bufferEndMetadata := BufferEndMetadata{}
+		if err := json.Unmarshal(endData.Data, &bufferEndMetadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal buffer end metadata: %w", err)
+		}
+		result.Status = bufferEndMetadata.Status
+		recordedBlobCount = bufferEndMetadata.BlobCount
+		recordedByteCount = bufferEndMetadata.ByteCount
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+
+	metrics := TransferMetrics{
+		Context:   ctx,
+		Container: container,
+	}
+	metrics.Start()
+
+	// Blobs are downloaded concurrently but verified in order.
+	responseChannel := make(chan chan verifiedBlob, readOptions.dop)
+	go func() {
+		defer close(responseChannel)
+		for blobNumber := int64(0); ; blobNumber++ {
+			c := make(chan verifiedBlob, 1)
+			select {
+			case responseChannel <- c:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(blobNumber int64) {
+				ctx := log.Ctx(ctx).With().Int64("blobNumber", blobNumber).Logger().WithContext(ctx)
+				respData, err := DownloadBlob(ctx, container, container.GetBlobUri(blobNumber), &noWait, nil, nil)
+				if err != nil {
+					c <- verifiedBlob{blobNumber: blobNumber, err: err}
+					return
+				}
+
+				pool.Put(respData.Data)
+				metrics.Update(uint64(len(respData.Data)))
+
+				c <- verifiedBlob{
+					blobNumber:       blobNumber,
+					size:             int64(len(respData.Data)),
+					encodedMD5Hash:   respData.EncodedMD5Hash,
+					encodedHashChain: respData.EncodedHashChain,
+				}
+			}(blobNumber)
+		}
+	}()
+
+	invalidBlob := func(blobNumber int64, problem string, args ...any) (*VerifyResult, error) {
+		result.FirstInvalidBlob = &blobNumber
+		result.Problem = fmt.Sprintf(problem, args...)
+		return result, nil
+	}
+
+	encodedHashChain := EncodedHashChainInitialValue
+	for c := range responseChannel {
+		blob := <-c
+		if blob.err != nil {
+			switch {
+			case blob.err == ErrNotFound:
+				if result.Status == "" {
+					return invalidBlob(blob.blobNumber, "the buffer has not been marked as complete and blob %d has not been written", blob.blobNumber)
+				}
+				return invalidBlob(blob.blobNumber, "blob %d is missing", blob.blobNumber)
+			case errors.Is(blob.err, errMd5Mismatch):
+				return invalidBlob(blob.blobNumber, "blob %d is corrupt: its contents do not match its MD5 hash", blob.blobNumber)
+			default:
+				return nil, fmt.Errorf("error downloading blob %d: %w", blob.blobNumber, blob.err)
+			}
+		}
+
+		if blob.encodedHashChain == "" {
+			return invalidBlob(blob.blobNumber, "blob %d is missing the %s header", blob.blobNumber, HashChainHeader)
+		}
+
+		encodedHashChain = calculateNextHashChain(encodedHashChain, blob.encodedMD5Hash)
+		if blob.encodedHashChain != encodedHashChain {
+			return invalidBlob(blob.blobNumber, "blob %d does not match the hash chain", blob.blobNumber)
+		}
+
+		if blob.size == 0 {
+			break
+		}
+
+		result.BlobCount++
+		result.TotalBytes += blob.size
+	}
+
+	metrics.Stop()
+
+	switch result.Status {
+	case BufferStatusComplete:
+		if recordedBlobCount != nil && *recordedBlobCount != result.BlobCount {
+			result.Problem = fmt.Sprintf("the buffer's end metadata records %d blobs, but %d were found", *recordedBlobCount, result.BlobCount)
+		} else if recordedByteCount != nil && *recordedByteCount != result.TotalBytes {
+			result.Problem = fmt.Sprintf("the buffer's end metadata records %d bytes, but %d were found", *recordedByteCount, result.TotalBytes)
+		} else {
+			result.Valid = true
+		}
+	case BufferStatusFailed:
+		result.Problem = "the buffer is marked as failed"
+	case "":
+		result.Problem = "the buffer has not been marked as complete"
+	default:
+		result.Problem = fmt.Sprintf("the buffer has an unexpected status '%s'", result.Status)
+	}
+
+	return result, nil
+}
@@ -6,14 +6,14 @@ package dataplane
 import (
 	"context"
 	"crypto/md5"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
@@ -27,6 +27,9 @@ const (
 	DefaultWriteDop              = 16
 	DefaultBlockSize             = 4 * 1024 * 1024
 	EncodedHashChainInitialValue = "MDAwMDAwMDAwMDAwMDAwMA=="
+
+	// The largest block size that can be written. Readers reject blobs that claim to be larger when decoded.
+	MaxBlobSizeBytes = 256 * 1024 * 1024
 )
 
 var (
@@ -34,9 +37,13 @@ var (
 )
 
 type writeOptions struct {
-	dop        int
-	blockSize  int
-	httpClient *retryablehttp.Client
+	dop         int
+	blockSize   int
+	httpClient  *retryablehttp.Client
+	resume      bool
+	resumable   bool
+	keyProvider KeyProvider
+	compression string
 }
 
 type WriteOption func(o *writeOptions)
@@ -59,6 +66,40 @@ func WithWriteHttpClient(httpClient *retryablehttp.Client) WriteOption {
 	}
 }
 
+// When resume is true, the blobs that already exist in the buffer are verified against
+// the input and skipped, and writing continues from the first blob that is missing.
+// The input and block size must be the same as those of the original write.
+func WithWriteResume(resume bool) WriteOption {
+	return func(o *writeOptions) {
+		o.resume = resume
+	}
+}
+
+// When resumable is true, a write that is cancelled or that fails with an error that might be transient
+// does not mark the buffer as failed, so that it can be resumed later with WithWriteResume. Readers keep
+// waiting for the rest of the buffer in the meantime. Errors that mean the buffer cannot be completed,
+// such as conflicting blobs, still mark it as failed.
+func WithWriteResumableOnFailure(resumable bool) WriteOption {
+	return func(o *writeOptions) {
+		o.resumable = resumable
+	}
+}
+
+// When a key provider is given, the blobs are encrypted with a data key generated for
+// the buffer, which is wrapped by the key provider and recorded in the start metadata.
+func WithWriteKeyProvider(keyProvider KeyProvider) WriteOption {
+	return func(o *writeOptions) {
+		o.keyProvider = keyProvider
+	}
+}
+
+// The compression to apply to each blob. See SupportedCompressions.
+func WithWriteCompression(compression string) WriteOption {
+	return func(o *writeOptions) {
+		o.compression = compression
+	}
+}
+
 // If invalidHashChain is set to true, the value of the hash chain attached to the blob will
 // always be the Inital Value. This should only be set for testing.
 func Write(ctx context.Context, uri string, inputReader io.Reader, options ...WriteOption) error {
@@ -70,6 +111,10 @@ func Write(ctx context.Context, uri string, inputReader io.Reader, options ...Wr
 		o(writeOptions)
 	}
 
+	if writeOptions.blockSize < 1 || writeOptions.blockSize > MaxBlobSizeBytes {
+		return fmt.Errorf("the block size must be between 1 and %d bytes", MaxBlobSizeBytes)
+	}
+
 	ctx = log.With().Str("operation", "buffer write").Logger().WithContext(ctx)
 	if writeOptions.httpClient == nil {
 		writeOptions.httpClient = httpclient.NewRetryableClient()
@@ -86,8 +131,26 @@ func Write(ctx context.Context, uri string, inputReader io.Reader, options ...Wr
 		return fmt.Errorf("invalid URL: %w", err)
 	}
 
-	if err := writeStartMetadata(ctx, httpClient, container); err != nil {
-		return err
+	var encoding *blobEncoding
+	resumePoint := &writeResumePoint{encodedHashChain: EncodedHashChainInitialValue}
+	if writeOptions.resume {
+		resumePoint, err = findResumePoint(ctx, container, inputReader, writeOptions)
+		if err != nil {
+			return err
+		}
+
+		encoding = resumePoint.encoding
+
+		if resumePoint.allBlobsWritten {
+			// Only the end metadata is missing. The final empty blob is not counted.
+			writeEndMetadata(ctx, container, newCompleteBufferEndMetadata(resumePoint.blobNumber-1, resumePoint.byteCount))
+			return nil
+		}
+	} else {
+		encoding, err = startNewBuffer(ctx, container, writeOptions)
+		if err != nil {
+			return err
+		}
 	}
 
 	outputChannel := make(chan BufferBlob, writeOptions.dop)
@@ -101,6 +164,10 @@ func Write(ctx context.Context, uri string, inputReader io.Reader, options ...Wr
 		Container: container,
 	}
 
+	byteCount := atomic.Int64{}
+	byteCount.Store(resumePoint.byteCount)
+	blobCount := resumePoint.blobNumber
+
 	for i := 0; i < writeOptions.dop; i++ {
 		go func() {
 			defer wg.Done()
@@ -108,12 +175,14 @@ func Write(ctx context.Context, uri string, inputReader io.Reader, options ...Wr
 
 				blobUrl := container.GetBlobUri(bb.BlobNumber)
 				ctx := log.Ctx(ctx).With().Int64("blobNumber", bb.BlobNumber).Logger().WithContext(ctx)
-				var body any = bb.Contents
-				if len(bb.Contents) == 0 {
-					// This is a bit subtle, but if we send an empty or nil []byte body,
-					// we will empty with the Transfer-Encoding: chunked header, which
-					// the blob service does not support.  So we send a nil body instead.
-					body = nil
+				var uncompressedLength int64
+				if !encoding.isIdentity() && len(bb.Contents) > 0 {
+					if encoding.codec != nil {
+						uncompressedLength = int64(len(bb.Contents))
+					}
+					encoded := encoding.encode(bb.BlobNumber, bb.Contents)
+					pool.Put(bb.Contents)
+					bb.Contents = encoded
 				}
 
 				md5Hash := md5.Sum(bb.Contents)
@@ -121,18 +190,18 @@ func Write(ctx context.Context, uri string, inputReader io.Reader, options ...Wr
 
 				previousHashChain := <-bb.PreviousCumulativeHash
 
-				hashChain := sha256.Sum256([]byte(previousHashChain + encodedMD5Hash))
-				encodedHashChain := base64.StdEncoding.EncodeToString(hashChain[:])
+				encodedHashChain := calculateNextHashChain(previousHashChain, encodedMD5Hash)
 
 				bb.CurrentCumulativeHash <- encodedHashChain
 
-				if err := uploadBlobWithRetry(ctx, httpClient, blobUrl, body, encodedMD5Hash, encodedHashChain); err != nil {
+				if err := uploadBlobWithRetry(ctx, container, blobUrl, bb.Contents, encodedMD5Hash, encodedHashChain, uncompressedLength); err != nil {
 					log.Debug().Err(err).Msg("Encountered error uploading blob")
 					errorChannel <- err
 					return
 				}
 
 				metrics.Update(uint64(len(bb.Contents)))
+				byteCount.Add(int64(len(bb.Contents)))
 
 				pool.Put(bb.Contents)
 			}
@@ -140,17 +209,28 @@ func Write(ctx context.Context, uri string, inputReader io.Reader, options ...Wr
 	}
 
 	go func() {
-		var blobNumber int64 = 0
+		blobNumber := resumePoint.blobNumber
 		previousHashChannel := make(chan string, 1)
 
-		previousHashChannel <- EncodedHashChainInitialValue
-
-		for {
+		previousHashChannel <- resumePoint.encodedHashChain
+
+		metricsStarted := false
+		for !resumePoint.inputExhausted {
+			var buffer []byte
+			var bytesRead int
+			var err error
+			if resumePoint.pendingBlock != nil {
+				// The first block that was not found in the buffer when resuming.
+				buffer, bytesRead, err = resumePoint.pendingBlock, len(resumePoint.pendingBlock), resumePoint.pendingErr
+				resumePoint.pendingBlock = nil
+			} else {
+				buffer = pool.Get(writeOptions.blockSize)
+				bytesRead, err = io.ReadFull(inputReader, buffer)
+			}
 
-			buffer := pool.Get(writeOptions.blockSize)
-			bytesRead, err := io.ReadFull(inputReader, buffer)
-			if blobNumber == 0 {
+			if !metricsStarted {
 				metrics.Start()
+				metricsStarted = true
 			}
 
 			if bytesRead > 0 {
@@ -176,6 +256,12 @@ func Write(ctx context.Context, uri string, inputReader io.Reader, options ...Wr
 			}
 		}
 
+		if !metricsStarted {
+			metrics.Start()
+		}
+
+		blobCount = blobNumber
+
 		currentHashChannel := make(chan string, 1)
 
 		outputChannel <- BufferBlob{
@@ -191,42 +277,54 @@ func Write(ctx context.Context, uri string, inputReader io.Reader, options ...Wr
 	}()
 
 	for err := range errorChannel {
+		if writeOptions.resumable && isResumableWriteError(err) {
+			log.Ctx(ctx).Warn().Msg("The buffer was not completed. The write can be resumed with the same input")
+
+			//lint:ignore SA4004 deliberately exiting after the first error
+			return err
+		}
+
+		endMetadataCtx := ctx
 		if ctx.Err() != nil {
 			// this means the context was cancelled or timed out
 			// use a new context to write the end metadata
 			newCtx, cancel := context.WithTimeout(&MergedContext{Context: context.Background(), valueSource: ctx}, 3*time.Second)
 			defer cancel()
-			ctx = newCtx
+			endMetadataCtx = newCtx
 		}
-		writeEndMetadata(ctx, httpClient, container, BufferStatusFailed)
+		writeEndMetadata(endMetadataCtx, container, BufferEndMetadata{Status: BufferStatusFailed})
 
 		//lint:ignore SA4004 deliberately exiting after the first error
 		return err
 	}
 
-	writeEndMetadata(ctx, httpClient, container, BufferStatusComplete)
+	writeEndMetadata(ctx, container, newCompleteBufferEndMetadata(blobCount, byteCount.Load()))
 	metrics.Stop()
 	return nil
 }
 
-func writeStartMetadata(ctx context.Context, httpClient *retryablehttp.Client, container *Container) error {
-	bufferStartMetadata := BufferStartMetadata{Version: CurrentBufferFormatVersion}
-	startMetadataUri := container.GetStartMetadataUri()
+// isResumableWriteError returns false for errors that mean that the buffer cannot be completed by
+// a later write, because its contents conflict with the input or the buffer no longer exists.
+func isResumableWriteError(err error) bool {
+	return !errors.Is(err, errBlobOverwrite) && !errors.Is(err, errMd5Mismatch) && !errors.Is(err, errBufferDoesNotExist)
+}
 
-	// See if the start metadata blob already exists and error out if it does.
-	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodHead, startMetadataUri, nil)
+// startNewBuffer writes the start metadata of a new buffer and returns the encoding of its blobs.
+func startNewBuffer(ctx context.Context, container *Container, writeOptions *writeOptions) (*blobEncoding, error) {
+	bufferStartMetadata, encoding, err := newBufferEncoding(writeOptions.compression, writeOptions.keyProvider)
 	if err != nil {
-		return fmt.Errorf("failed to create HEAD request: %w", err)
+		return nil, err
 	}
 
-	AddCommonBlobRequestHeaders(req.Header)
+	return encoding, writeStartMetadata(ctx, container, bufferStartMetadata)
+}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send HEAD request: %w", err)
-	}
+func writeStartMetadata(ctx context.Context, container *Container, bufferStartMetadata BufferStartMetadata) error {
+	startMetadataUri := container.GetStartMetadataUri()
 
-	if resp.StatusCode == http.StatusOK {
+	// See if the start metadata blob already exists and error out if it does. Other errors are
+	// surfaced by the upload below.
+	if _, err := container.storage.getBlobInfo(ctx, startMetadataUri); err == nil {
 		return fmt.Errorf("buffer cannot be overwritten")
 	}
 
@@ -238,11 +336,10 @@ func writeStartMetadata(ctx context.Context, httpClient *retryablehttp.Client, c
 	md5Hash := md5.Sum(startBytes)
 	encodedMD5Hash := base64.StdEncoding.EncodeToString(md5Hash[:])
 
-	return uploadBlobWithRetry(ctx, httpClient, startMetadataUri, startBytes, encodedMD5Hash, "")
+	return uploadBlobWithRetry(ctx, container, startMetadataUri, startBytes, encodedMD5Hash, "", 0)
 }
 
-func writeEndMetadata(ctx context.Context, httpClient *retryablehttp.Client, container *Container, status string) {
-	bufferEndMetadata := BufferEndMetadata{Status: status}
+func writeEndMetadata(ctx context.Context, container *Container, bufferEndMetadata BufferEndMetadata) {
 	endBytes, err := json.Marshal(bufferEndMetadata)
 	if err != nil {
 		panic(fmt.Errorf("failed to marshal end metadata: %w", err))
@@ -251,33 +348,16 @@ func writeEndMetadata(ctx context.Context, httpClient *retryablehttp.Client, con
 	md5Hash := md5.Sum(endBytes)
 	encodedMD5Hash := base64.StdEncoding.EncodeToString(md5Hash[:])
 
-	err = uploadBlobWithRetry(ctx, httpClient, container.GetEndMetadataUri(), endBytes, encodedMD5Hash, "")
+	err = uploadBlobWithRetry(ctx, container, container.GetEndMetadataUri(), endBytes, encodedMD5Hash, "", 0)
 	if err != nil {
 		log.Warn().Err(err).Msg("Failed to upload optional metadata at the end of the transfer")
 	}
 }
 
-func uploadBlobWithRetry(ctx context.Context, httpClient *retryablehttp.Client, blobUrl string, body any, encodedMD5Hash string, encodedHashChain string) error {
+func uploadBlobWithRetry(ctx context.Context, container *Container, blobUrl string, contents []byte, encodedMD5Hash string, encodedHashChain string, uncompressedLength int64) error {
 	start := time.Now()
 	for i := 0; ; i++ {
-		req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPut, blobUrl, body)
-		if err != nil {
-			return fmt.Errorf("unable to create request: %w", err)
-		}
-
-		AddCommonBlobRequestHeaders(req.Header)
-		req.Header.Add("x-ms-blob-type", "BlockBlob")
-
-		req.Header.Add(ContentMD5Header, encodedMD5Hash)
-		if encodedHashChain != "" {
-			req.Header.Add(HashChainHeader, encodedHashChain)
-		}
-
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("unable to send request: %w", err)
-		}
-		err = handleWriteResponse(resp)
+		err := container.storage.putBlob(ctx, blobUrl, contents, encodedMD5Hash, encodedHashChain, uncompressedLength)
 		if err == nil {
 			break
 		}
@@ -288,82 +368,162 @@ func uploadBlobWithRetry(ctx context.Context, httpClient *retryablehttp.Client,
 				log.Ctx(ctx).Debug().Msg("MD5 mismatch, retrying")
 				continue
 			} else {
-				return fmt.Errorf("failed to upload blob: %w", httpclient.RedactHttpError(err))
+				return fmt.Errorf("failed to upload blob: %w", err)
 			}
 		case errBlobOverwrite:
 			// When retrying failed writes, we might encounter the UnauthorizedBlobOverwrite if the original
 			// write went through. In such cases, we should follow up with a HEAD request to verify the
 			// Content-MD5 and x-ms-meta-cumulative_hash_chain match our expectations.
-			req, err := retryablehttp.NewRequest(http.MethodHead, blobUrl, nil)
-			if err != nil {
-				return fmt.Errorf("unable to create HEAD request: %w", err)
+			info, headErr := container.storage.getBlobInfo(ctx, blobUrl)
+			if headErr == nil && info.EncodedMD5Hash == encodedMD5Hash && info.EncodedHashChain == encodedHashChain {
+				return nil
 			}
 
-			AddCommonBlobRequestHeaders(req.Header)
-
-			resp, err := httpClient.Do(req)
-			if err != nil {
-				return fmt.Errorf("HEAD request failed: %w", err)
-			}
-
-			if resp.StatusCode == http.StatusOK {
-				md5Header := resp.Header.Get(ContentMD5Header)
-				hashChainHeader := resp.Header.Get(HashChainHeader)
-
-				if md5Header == encodedMD5Hash && hashChainHeader == encodedHashChain {
-					return nil
-				}
-			}
-
-			return fmt.Errorf("buffer cannot be overwritten: %w", httpclient.RedactHttpError(err))
+			return fmt.Errorf("buffer cannot be overwritten: %w", err)
 		case errBufferDoesNotExist:
 			return err
 		default:
-			return fmt.Errorf("failed to upload blob: %w", httpclient.RedactHttpError(err))
+			return fmt.Errorf("failed to upload blob: %w", err)
 		}
 	}
 
 	if log.Ctx(ctx).GetLevel() >= zerolog.TraceLevel {
 		parsedUrl, _ := url.Parse(blobUrl)
-		e := log.Ctx(ctx).Trace().Str("blobPath", parsedUrl.Path).Dur("duration", time.Since(start))
-		if bytesBody, ok := body.([]byte); ok {
-			e = e.Int("contentLength", len(bytesBody))
-		} else if body == nil {
-			e = e.Int("contentLength", 0)
-		}
-		e.Msg("Uploaded blob")
+		log.Ctx(ctx).Trace().
+			Str("blobPath", parsedUrl.Path).
+			Dur("duration", time.Since(start)).
+			Int("contentLength", len(contents)).
+			Msg("Uploaded blob")
 	}
 
 	return nil
 }
 
-func handleWriteResponse(resp *http.Response) error {
-	defer resp.Body.Close()
+type writeResumePoint struct {
+	blobNumber       int64
+	encodedHashChain string
+
+	// The first block of input that is not already in the buffer.
+	pendingBlock []byte
+	pendingErr   error
+
+	// Set when the entire input has been consumed while verifying.
+	inputExhausted bool
+
+	// Set when every blob, including the final empty one, is already in the buffer.
+	allBlobsWritten bool
+
+	// The number of stored bytes of the blobs that are already in the buffer.
+	byteCount int64
+
+	// How the blobs of the buffer are compressed and encrypted.
+	encoding *blobEncoding
+}
+
+// findResumePoint verifies the blobs written to the buffer by a previous attempt against the input,
+// consuming the input up to the first blob that is missing from the buffer.
+func findResumePoint(ctx context.Context, container *Container, inputReader io.Reader, writeOptions *writeOptions) (*writeResumePoint, error) {
+	resumePoint := &writeResumePoint{encodedHashChain: EncodedHashChainInitialValue}
+
+	wait := atomic.Bool{}
+	wait.Store(false)
+
+	startData, err := DownloadBlob(ctx, container, container.GetStartMetadataUri(), &wait, nil, nil)
+	if err != nil {
+		if err == ErrNotFound {
+			// Nothing was written before, so we start from the beginning.
+			resumePoint.encoding, err = startNewBuffer(ctx, container, writeOptions)
+			return resumePoint, err
+		}
+		return nil, err
+	}
+
+	bufferStartMetadata, err := parseBufferStartMetadata(startData.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resume: %w", err)
+	}
+
+	if bufferStartMetadata.Encryption == nil && writeOptions.keyProvider != nil {
+		return nil, errors.New("unable to resume: the buffer was not encrypted in the original write")
+	}
+
+	if writeOptions.compression != "" && normalizeCompression(writeOptions.compression) != normalizeCompression(bufferStartMetadata.Compression) {
+		return nil, fmt.Errorf("unable to resume: the original write used compression '%s'", normalizeCompression(bufferStartMetadata.Compression))
+	}
+
+	resumePoint.encoding, err = getBlobEncoding(bufferStartMetadata, writeOptions.keyProvider)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resume: %w", err)
+	}
+
+	endData, err := DownloadBlob(ctx, container, container.GetEndMetadataUri(), &wait, nil, nil)
+	if err == nil {
+		bufferEndMetadata := BufferEndMetadata{}
+		if err := json.Unmarshal(endData.Data, &bufferEndMetadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal buffer end metadata: %w", err)
+		}
+		if bufferEndMetadata.Status == BufferStatusFailed {
+			return nil, fmt.Errorf("unable to resume: %w", errBufferFailedState)
+		}
+		return nil, errors.New("unable to resume: the buffer has already been completed")
+	}
+	if err != ErrNotFound {
+		return nil, err
+	}
+
+	for {
+		buffer := pool.Get(writeOptions.blockSize)
+		bytesRead, readErr := io.ReadFull(inputReader, buffer)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			pool.Put(buffer)
+			return nil, fmt.Errorf("error reading from input: %w", readErr)
+		}
 
-	switch resp.StatusCode {
-	case http.StatusCreated:
-		io.Copy(io.Discard, resp.Body)
-		return nil
-	case http.StatusNotFound:
-		if resp.Header.Get("x-ms-error-code") == "ContainerNotFound" {
-			return errBufferDoesNotExist
+		contents := buffer[:bytesRead]
+		var md5Hash [md5.Size]byte
+		if !resumePoint.encoding.isIdentity() && bytesRead > 0 {
+			encoded := resumePoint.encoding.encode(resumePoint.blobNumber, contents)
+			md5Hash = md5.Sum(encoded)
+			pool.Put(encoded)
+		} else {
+			md5Hash = md5.Sum(contents)
 		}
-		fallthrough
-	case http.StatusBadRequest:
-		if resp.Header.Get("x-ms-error-code") == "Md5Mismatch" {
-			io.Copy(io.Discard, resp.Body)
-			return errMd5Mismatch
+		encodedMD5Hash := base64.StdEncoding.EncodeToString(md5Hash[:])
+		encodedHashChain := calculateNextHashChain(resumePoint.encodedHashChain, encodedMD5Hash)
+
+		info, err := container.storage.getBlobInfo(ctx, container.GetBlobUri(resumePoint.blobNumber))
+		if err != nil {
+			if err != ErrNotFound {
+				pool.Put(buffer)
+				return nil, err
+			}
+
+			log.Ctx(ctx).Info().Int64("blobNumber", resumePoint.blobNumber).Msg("Resuming write")
+			if bytesRead == 0 {
+				pool.Put(buffer)
+				resumePoint.inputExhausted = true
+			} else {
+				resumePoint.pendingBlock = contents
+				resumePoint.pendingErr = readErr
+			}
+
+			return resumePoint, nil
+		}
+
+		pool.Put(buffer)
+
+		if info.EncodedMD5Hash != encodedMD5Hash || info.EncodedHashChain != encodedHashChain {
+			return nil, fmt.Errorf("blob %d in the buffer does not match the input. The input and block size must be the same as in the original write", resumePoint.blobNumber)
 		}
-		fallthrough
-	case http.StatusForbidden:
-		if resp.Header.Get("x-ms-error-code") == "UnauthorizedBlobOverwrite" {
-			io.Copy(io.Discard, resp.Body)
-			return errBlobOverwrite
+
+		resumePoint.encodedHashChain = encodedHashChain
+		resumePoint.blobNumber++
+		resumePoint.byteCount += info.Size
+
+		if bytesRead == 0 {
+			resumePoint.allBlobsWritten = true
+			return resumePoint, nil
 		}
-		fallthrough
-	default:
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 }
 
@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interruptingReader reads from an input until limit bytes have been read, then cancels
+// the write like Ctrl-C would and fails all further reads.
+type interruptingReader struct {
+	input  io.Reader
+	limit  int
+	cancel context.CancelFunc
+}
+
+func (r *interruptingReader) Read(p []byte) (int, error) {
+	if r.limit <= 0 {
+		r.cancel()
+		return 0, context.Canceled
+	}
+
+	if len(p) > r.limit {
+		p = p[:r.limit]
+	}
+	n, err := r.input.Read(p)
+	r.limit -= n
+	return n, err
+}
+
+func TestResumeInterruptedWrite(t *testing.T) {
+	t.Parallel()
+
+	uri, dir := newLocalBufferUri(t)
+
+	input := make([]byte, 10*1024+17)
+	_, err := rand.Read(input)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupted := &interruptingReader{input: bytes.NewReader(input), limit: 4 * 1024, cancel: cancel}
+
+	err = Write(ctx, uri, interrupted, WithWriteBlockSize(1024), WithWriteResumableOnFailure(true))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, filepath.Join(dir, EndMetadataBlobName))
+
+	err = Write(context.Background(), uri, bytes.NewReader(input), WithWriteBlockSize(1024), WithWriteResume(true))
+	require.NoError(t, err)
+
+	output := &bytes.Buffer{}
+	err = Read(context.Background(), uri, output)
+	require.NoError(t, err)
+	assert.Equal(t, input, output.Bytes())
+}
+
+func TestInterruptedWriteIsFailedByDefault(t *testing.T) {
+	t.Parallel()
+
+	uri, dir := newLocalBufferUri(t)
+
+	input := make([]byte, 10*1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupted := &interruptingReader{input: bytes.NewReader(input), limit: 4 * 1024, cancel: cancel}
+
+	err := Write(ctx, uri, interrupted, WithWriteBlockSize(1024))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.FileExists(t, filepath.Join(dir, EndMetadataBlobName))
+
+	err = Write(context.Background(), uri, bytes.NewReader(input), WithWriteBlockSize(1024), WithWriteResume(true))
+	require.ErrorIs(t, err, errBufferFailedState)
+}
+
+func TestWriteRejectsBlockSizeOutOfRange(t *testing.T) {
+	t.Parallel()
+
+	uri, _ := newLocalBufferUri(t)
+	for _, blockSize := range []int{0, MaxBlobSizeBytes + 1} {
+		err := Write(context.Background(), uri, bytes.NewReader(nil), WithWriteBlockSize(blockSize))
+		assert.ErrorContains(t, err, "the block size must be between")
+	}
+}
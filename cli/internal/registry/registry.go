@@ -0,0 +1,390 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Package registry reads image manifests and configurations from container registries that implement
+// the OCI distribution API, with the credentials of the local Docker configuration.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/cli/cli/config"
+	"github.com/microsoft/tyger/cli/internal/httpclient"
+)
+
+const (
+	mediaTypeOciIndex            = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOciManifest         = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifestList  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest      = "application/vnd.docker.distribution.manifest.v2+json"
+	maxManifestOrConfigByteCount = 4 * 1024 * 1024
+)
+
+var manifestMediaTypes = []string{
+	mediaTypeOciIndex,
+	mediaTypeOciManifest,
+	mediaTypeDockerManifestList,
+	mediaTypeDockerManifest,
+}
+
+var authChallengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ErrImageNotFound is returned when the registry does not have the image.
+var ErrImageNotFound = errors.New("image not found")
+
+// Reference is a parsed image reference.
+type Reference struct {
+	// Domain is the registry domain, such as docker.io or myregistry.azurecr.io.
+	Domain string
+	// Repository is the path of the repository in the registry.
+	Repository string
+	// TagOrDigest is the tag or the digest of the image. It is "latest" if the reference has neither.
+	TagOrDigest string
+
+	baseUrl   string
+	configKey string
+}
+
+// ParseReference parses an image reference, such as ubuntu:22.04 or myregistry.azurecr.io/app@sha256:...
+func ParseReference(image string) (*Reference, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, err
+	}
+	named = reference.TagNameOnly(named)
+
+	ref := &Reference{
+		Domain:     reference.Domain(named),
+		Repository: reference.Path(named),
+	}
+
+	if digested, ok := named.(reference.Digested); ok {
+		ref.TagOrDigest = digested.Digest().String()
+	} else {
+		ref.TagOrDigest = named.(reference.Tagged).Tag()
+	}
+
+	host, configKey := ref.Domain, ref.Domain
+	if ref.Domain == "docker.io" {
+		host, configKey = "registry-1.docker.io", "https://index.docker.io/v1/"
+	}
+
+	// Like Docker, registries on the loopback interface are accessed without TLS.
+	scheme := "https"
+	if isLoopbackHost(host) {
+		scheme = "http"
+	}
+
+	ref.baseUrl = fmt.Sprintf("%s://%s/v2/%s", scheme, host, ref.Repository)
+	ref.configKey = configKey
+	return ref, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ImageConfig holds the parts of an image configuration that are used by tyger.
+type ImageConfig struct {
+	Labels map[string]string
+}
+
+// ImageExists looks up the manifest of an image in its registry. It returns an error
+// if the registry could not be queried, for example because it denied access.
+func ImageExists(ctx context.Context, ref *Reference) (bool, error) {
+	client := newRepositoryClient(ref)
+	resp, err := client.do(ctx, http.MethodHead, fmt.Sprintf("%s/manifests/%s", ref.baseUrl, ref.TagOrDigest), manifestMediaTypes)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status code %d from the registry %s", resp.StatusCode, ref.Domain)
+	}
+}
+
+// GetImageConfig gets the configuration of an image from its registry. For images built
+// for multiple platforms, the configuration of the linux/amd64 image is returned.
+// ErrImageNotFound is returned if the registry does not have the image.
+func GetImageConfig(ctx context.Context, ref *Reference) (*ImageConfig, error) {
+	client := newRepositoryClient(ref)
+
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+		Config    struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+
+	mediaType, err := client.getJson(ctx, fmt.Sprintf("%s/manifests/%s", ref.baseUrl, ref.TagOrDigest), manifestMediaTypes, ref.TagOrDigest, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.MediaType != "" {
+		mediaType = manifest.MediaType
+	}
+
+	if mediaType == mediaTypeOciIndex || mediaType == mediaTypeDockerManifestList {
+		platformDigest := ""
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == "amd64" {
+				platformDigest = m.Digest
+				break
+			}
+		}
+		if platformDigest == "" {
+			return nil, errors.New("the image does not have a linux/amd64 variant")
+		}
+
+		manifest.MediaType = ""
+		if _, err := client.getJson(ctx, fmt.Sprintf("%s/manifests/%s", ref.baseUrl, platformDigest), manifestMediaTypes, platformDigest, &manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	if manifest.Config.Digest == "" {
+		return nil, errors.New("the image manifest does not have a configuration")
+	}
+
+	var imageConfig struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+
+	if _, err := client.getJson(ctx, fmt.Sprintf("%s/blobs/%s", ref.baseUrl, manifest.Config.Digest), nil, manifest.Config.Digest, &imageConfig); err != nil {
+		return nil, err
+	}
+
+	return &ImageConfig{Labels: imageConfig.Config.Labels}, nil
+}
+
+// repositoryClient makes requests to the repository of an image. When the registry
+// asks for credentials, it authenticates once and reuses the authorization for later requests.
+type repositoryClient struct {
+	ref           *Reference
+	httpClient    *http.Client
+	authorization string
+}
+
+func newRepositoryClient(ref *Reference) *repositoryClient {
+	return &repositoryClient{
+		ref:        ref,
+		httpClient: httpclient.DefaultRetryableClient.StandardClient(),
+	}
+}
+
+// do sends a request, authenticating if the registry responds with 401. Responses with a
+// status code of 401 or 403 after authentication are returned as errors.
+func (c *repositoryClient) do(ctx context.Context, method string, url string, accept []string) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		return c.httpClient.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && c.authorization == "" {
+		resp.Body.Close()
+		if err := c.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, err
+		}
+
+		resp, err = send()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, fmt.Errorf("the registry %s denied access to the image", c.ref.Domain)
+	}
+
+	return resp, nil
+}
+
+// getJson gets a manifest or a blob and decodes it into v after checking its content against
+// the expected digest, if the request is by digest. Returns the media type of the response.
+func (c *repositoryClient) getJson(ctx context.Context, url string, accept []string, tagOrDigest string, v any) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, url, accept)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrImageNotFound
+	default:
+		return "", fmt.Errorf("unexpected status code %d from the registry %s", resp.StatusCode, c.ref.Domain)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestOrConfigByteCount+1))
+	if err != nil {
+		return "", err
+	}
+	if len(content) > maxManifestOrConfigByteCount {
+		return "", fmt.Errorf("the response from the registry %s is too large", c.ref.Domain)
+	}
+
+	if algorithm, expected, ok := strings.Cut(tagOrDigest, ":"); ok && algorithm == "sha256" {
+		actual := sha256.Sum256(content)
+		if hex.EncodeToString(actual[:]) != expected {
+			return "", fmt.Errorf("the content of %s from the registry %s does not match its digest", tagOrDigest, c.ref.Domain)
+		}
+	}
+
+	if err := json.Unmarshal(content, v); err != nil {
+		return "", fmt.Errorf("unable to parse the response from the registry %s: %w", c.ref.Domain, err)
+	}
+
+	mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	return strings.TrimSpace(mediaType), nil
+}
+
+// authenticate sets the authorization for later requests from the challenge in a WWW-Authenticate header,
+// with the credentials for the registry in the local Docker configuration, if there are any.
+func (c *repositoryClient) authenticate(ctx context.Context, authenticateHeader string) error {
+	authConfig, err := config.LoadDefaultConfigFile(io.Discard).GetAuthConfig(c.ref.configKey)
+	if err != nil {
+		return fmt.Errorf("unable to read the Docker credentials for %s: %w", c.ref.Domain, err)
+	}
+
+	scheme, params, _ := strings.Cut(authenticateHeader, " ")
+	challenge := make(map[string]string)
+	for _, match := range authChallengeParamRegex.FindAllStringSubmatch(params, -1) {
+		challenge[strings.ToLower(match[1])] = match[2]
+	}
+
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		token, err := c.getToken(ctx, challenge, authConfig.Username, authConfig.Password, authConfig.IdentityToken)
+		if err != nil {
+			return err
+		}
+		c.authorization = "Bearer " + token
+	case "basic":
+		if authConfig.Username == "" {
+			return fmt.Errorf("the registry %s requires credentials", c.ref.Domain)
+		}
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(authConfig.Username, authConfig.Password)
+		c.authorization = req.Header.Get("Authorization")
+	default:
+		return fmt.Errorf("the registry %s uses an unsupported authentication scheme '%s'", c.ref.Domain, scheme)
+	}
+
+	return nil
+}
+
+// getToken gets a token to pull from the repository, following the registry's bearer challenge.
+// The request is anonymous if there are no credentials.
+func (c *repositoryClient) getToken(ctx context.Context, challenge map[string]string, username, password, identityToken string) (string, error) {
+	realm := challenge["realm"]
+	if realm == "" {
+		return "", errors.New("the registry did not say where to get a token")
+	}
+
+	scope := challenge["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", c.ref.Repository)
+	}
+
+	var req *http.Request
+	var err error
+	if identityToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", identityToken)
+		form.Set("service", challenge["service"])
+		form.Set("scope", scope)
+		form.Set("client_id", "tyger")
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := url.Values{}
+		if service := challenge["service"]; service != "" {
+			query.Set("service", service)
+		}
+		query.Set("scope", scope)
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?%s", realm, query.Encode()), nil)
+		if err != nil {
+			return "", err
+		}
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("the registry denied access to the image (status code %d)", resp.StatusCode)
+	}
+
+	var tokenResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("unable to read the registry token: %w", err)
+	}
+
+	if tokenResponse.Token != "" {
+		return tokenResponse.Token, nil
+	}
+	return tokenResponse.AccessToken, nil
+}
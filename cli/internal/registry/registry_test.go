@@ -0,0 +1,242 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/tyger/cli/internal/settings"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRegistry is a stand-in for a container registry that serves the manifests and blobs it is given.
+type fakeRegistry struct {
+	server      *httptest.Server
+	token       string
+	issuedToken string
+	manifests   map[string]fakeManifest
+	blobs       map[string][]byte
+}
+
+type fakeManifest struct {
+	mediaType string
+	content   []byte
+}
+
+func newFakeRegistry(t *testing.T, token string) *fakeRegistry {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	r := &fakeRegistry{
+		token:       token,
+		issuedToken: token,
+		manifests:   make(map[string]fakeManifest),
+		blobs:       make(map[string][]byte),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("scope") != "repository:app:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": r.issuedToken})
+	})
+	mux.HandleFunc("/v2/app/", func(w http.ResponseWriter, req *http.Request) {
+		if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, r.server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		kind, tagOrDigest, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/v2/app/"), "/")
+		switch kind {
+		case "manifests":
+			if m, ok := r.manifests[tagOrDigest]; ok {
+				w.Header().Set("Content-Type", m.mediaType)
+				w.Write(m.content)
+				return
+			}
+		case "blobs":
+			if b, ok := r.blobs[tagOrDigest]; ok {
+				w.Write(b)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	r.server = httptest.NewServer(mux)
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *fakeRegistry) image(tagOrDigest string) string {
+	separator := ":"
+	if strings.Contains(tagOrDigest, ":") {
+		separator = "@"
+	}
+	return fmt.Sprintf("%s/app%s%s", strings.TrimPrefix(r.server.URL, "http://"), separator, tagOrDigest)
+}
+
+// testContext returns a context like the one of a CLI that is not logged in.
+func testContext() context.Context {
+	return settings.SetServiceInfoFuncOnContext(context.Background(), func() (settings.ServiceInfo, error) {
+		return nil, errors.New("not logged in")
+	})
+}
+
+func digestOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// addImage adds an image with the given labels and returns the digest of its manifest.
+func (r *fakeRegistry) addImage(t *testing.T, tag string, labels map[string]string) string {
+	config, err := json.Marshal(map[string]any{"config": map[string]any{"Labels": labels}})
+	require.NoError(t, err)
+	r.blobs[digestOf(config)] = config
+
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeOciManifest,
+		"config":        map[string]any{"digest": digestOf(config)},
+	})
+	require.NoError(t, err)
+
+	manifestDigest := digestOf(manifest)
+	r.manifests[manifestDigest] = fakeManifest{mediaTypeOciManifest, manifest}
+	if tag != "" {
+		r.manifests[tag] = r.manifests[manifestDigest]
+	}
+
+	return manifestDigest
+}
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		image       string
+		domain      string
+		repository  string
+		tagOrDigest string
+		baseUrl     string
+	}{
+		{"ubuntu", "docker.io", "library/ubuntu", "latest", "https://registry-1.docker.io/v2/library/ubuntu"},
+		{"myregistry.azurecr.io/team/app:1.2", "myregistry.azurecr.io", "team/app", "1.2", "https://myregistry.azurecr.io/v2/team/app"},
+		{"localhost:5000/app:1", "localhost:5000", "app", "1", "http://localhost:5000/v2/app"},
+		{"127.0.0.1:5000/app:1", "127.0.0.1:5000", "app", "1", "http://127.0.0.1:5000/v2/app"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.image, func(t *testing.T) {
+			ref, err := ParseReference(tC.image)
+			require.NoError(t, err)
+			require.Equal(t, tC.domain, ref.Domain)
+			require.Equal(t, tC.repository, ref.Repository)
+			require.Equal(t, tC.tagOrDigest, ref.TagOrDigest)
+			require.Equal(t, tC.baseUrl, ref.baseUrl)
+		})
+	}
+
+	_, err := ParseReference("Invalid Image")
+	require.Error(t, err)
+}
+
+func TestImageExists(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	registry.addImage(t, "1", nil)
+
+	ref, err := ParseReference(registry.image("1"))
+	require.NoError(t, err)
+	exists, err := ImageExists(testContext(), ref)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	ref, err = ParseReference(registry.image("2"))
+	require.NoError(t, err)
+	exists, err = ImageExists(testContext(), ref)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestGetImageConfig(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	digest := registry.addImage(t, "1", map[string]string{"tyger.kind": "job"})
+
+	for _, tagOrDigest := range []string{"1", digest} {
+		ref, err := ParseReference(registry.image(tagOrDigest))
+		require.NoError(t, err)
+		config, err := GetImageConfig(testContext(), ref)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"tyger.kind": "job"}, config.Labels)
+	}
+
+	ref, err := ParseReference(registry.image("2"))
+	require.NoError(t, err)
+	_, err = GetImageConfig(testContext(), ref)
+	require.ErrorIs(t, err, ErrImageNotFound)
+}
+
+func TestGetImageConfigFromIndex(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	armDigest := registry.addImage(t, "", map[string]string{"tyger.kind": "arm"})
+	amdDigest := registry.addImage(t, "", map[string]string{"tyger.kind": "amd"})
+
+	index, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeOciIndex,
+		"manifests": []map[string]any{
+			{"digest": armDigest, "platform": map[string]string{"os": "linux", "architecture": "arm64"}},
+			{"digest": amdDigest, "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+		},
+	})
+	require.NoError(t, err)
+	registry.manifests["multi"] = fakeManifest{mediaTypeOciIndex, index}
+
+	ref, err := ParseReference(registry.image("multi"))
+	require.NoError(t, err)
+	config, err := GetImageConfig(testContext(), ref)
+	require.NoError(t, err)
+	require.Equal(t, "amd", config.Labels["tyger.kind"])
+}
+
+func TestGetImageConfigDigestMismatch(t *testing.T) {
+	registry := newFakeRegistry(t, "")
+	digest := registry.addImage(t, "1", nil)
+	registry.manifests[digest] = fakeManifest{mediaTypeOciManifest, []byte(`{"config":{"digest":"sha256:abc"}}`)}
+
+	ref, err := ParseReference(registry.image(digest))
+	require.NoError(t, err)
+	_, err = GetImageConfig(testContext(), ref)
+	require.ErrorContains(t, err, "does not match its digest")
+}
+
+func TestGetImageConfigWithBearerToken(t *testing.T) {
+	registry := newFakeRegistry(t, "secret-token")
+	registry.addImage(t, "1", map[string]string{"tyger.kind": "worker"})
+
+	ref, err := ParseReference(registry.image("1"))
+	require.NoError(t, err)
+	config, err := GetImageConfig(testContext(), ref)
+	require.NoError(t, err)
+	require.Equal(t, "worker", config.Labels["tyger.kind"])
+}
+
+func TestImageExistsAccessDenied(t *testing.T) {
+	registry := newFakeRegistry(t, "secret-token")
+	registry.addImage(t, "1", nil)
+	registry.issuedToken = "other-token"
+
+	ref, err := ParseReference(registry.image("1"))
+	require.NoError(t, err)
+	_, err = ImageExists(testContext(), ref)
+	require.ErrorContains(t, err, "denied access")
+}
@@ -29,6 +29,7 @@ export default defineConfig({
           { text: "Working with codespecs", link: "/guides/codespecs" },
           { text: "Working with runs", link: "/guides/runs" },
           { text: "Distributed runs", link: "/guides/distributed-runs" },
+          { text: "Pipelines", link: "/guides/pipelines" },
         ],
       },
       {
@@ -38,6 +39,7 @@ export default defineConfig({
           { text: "Gadgetron examples", link: "/reference/gadgetron/gadgetron" },
           { text: "Database management", link: "/reference/database-management" },
           { text: "<code>tyger-proxy</code>", link: "/reference/tyger-proxy" },
+          { text: "Output formats", link: "/reference/output-formats" },
         ],
       },
     ],
@@ -0,0 +1,43 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+using System.Text.Json;
+using Shouldly;
+using Tyger.Server.Model;
+using Xunit;
+
+namespace Tyger.Server.UnitTests.Model;
+
+public class EnvValueConverterTests
+{
+    private static readonly JsonSerializerOptions s_options = new(JsonSerializerDefaults.Web);
+
+    [Fact]
+    public void LiteralValue_RoundTrips()
+    {
+        var env = JsonSerializer.Deserialize<Dictionary<string, EnvValue>>("""{"A": "a"}""", s_options)!;
+        env["A"].ShouldBe(new EnvValue { Value = "a" });
+        JsonSerializer.Serialize(env, s_options).ShouldBe("""{"A":"a"}""");
+    }
+
+    [Fact]
+    public void SecretRef_RoundTrips()
+    {
+        var env = JsonSerializer.Deserialize<Dictionary<string, EnvValue>>("""{"A": {"secretRef": "my-secret/KEY_1"}}""", s_options)!;
+        env["A"].ShouldBe(new EnvValue { SecretRef = "my-secret/KEY_1" });
+        env["A"].ParseSecretRef().ShouldBe(("my-secret", "KEY_1"));
+        JsonSerializer.Serialize(env, s_options).ShouldBe("""{"A":{"secretRef":"my-secret/KEY_1"}}""");
+    }
+
+    [Theory]
+    [InlineData("""{"secretRef": "no-key"}""")]
+    [InlineData("""{"secretRef": "Upper/key"}""")]
+    [InlineData("""{"secretRef": "a/b/c"}""")]
+    [InlineData("""{"secretRef": "a/b", "value": "c"}""")]
+    [InlineData("""{"value": "c"}""")]
+    [InlineData("1")]
+    public void InvalidValue_Throws(string json)
+    {
+        Should.Throw<JsonException>(() => JsonSerializer.Deserialize<EnvValue>(json, s_options));
+    }
+}
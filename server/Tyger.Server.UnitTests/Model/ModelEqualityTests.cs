@@ -35,6 +35,11 @@ public class ModelEqualityTests
         (a with { Resources = new() { Requests = new() { Cpu = new("10001m") } } }).ShouldNotBe(a);
         (a with { Resources = new() { Limits = new() { Cpu = new("1") } } }).ShouldNotBe(a);
 
+        a = new() { Image = "image", Mounts = [new() { Dataset = "a", Path = "/a" }] };
+        (a with { Mounts = [new() { Dataset = "a", Path = "/a" }] }).ShouldBe(a);
+        (a with { Mounts = [new() { Dataset = "a", Version = 1, Path = "/a" }] }).ShouldNotBe(a);
+        (a with { Mounts = [new() { Dataset = "a", Path = "/b" }] }).ShouldNotBe(a);
+
         a = new() { Image = "i1" };
         (a with { Image = "i1" }).ShouldBe(a);
         (a with { Image = "i2" }).ShouldNotBe(a);
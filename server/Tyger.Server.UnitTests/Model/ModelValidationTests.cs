@@ -35,5 +35,14 @@ public class ModelValidationTests
         Should.Throw<ValidationException>(() => Validate(s_validCodespec with { Buffers = new(new string[] { null! }, null) }));
     }
 
+    [Fact]
+    public void Codespec_Mounts()
+    {
+        Validate(s_validCodespec with { Mounts = [new() { Dataset = "a", Path = "/a" }, new() { Dataset = "b", Version = 2, Path = "/b" }] });
+        Should.Throw<ValidationException>(() => Validate(s_validCodespec with { Mounts = [new() { Dataset = "a", Path = "a" }] }));
+        Should.Throw<ValidationException>(() => Validate(s_validCodespec with { Mounts = [new() { Dataset = "a", Path = "/a" }, new() { Dataset = "b", Path = "/a/" }] }));
+        Should.Throw<ValidationException>(() => Validate(s_validCodespec with { Mounts = [new() { Dataset = "a", Path = "/a" }, new() { Dataset = "a", Path = "/b" }] }));
+    }
+
     private static void Validate(object o) => Validator.ValidateObject(o, new ValidationContext(o), true);
 }
@@ -2,6 +2,8 @@
 // Licensed under the MIT License.
 
 using System.ComponentModel.DataAnnotations;
+using System.Globalization;
+using System.Text.Json;
 using System.Text.RegularExpressions;
 using Azure;
 using Azure.Core;
@@ -19,9 +21,20 @@ namespace Tyger.Server.Buffers;
 public sealed class BufferManager : IHealthCheck, IHostedService, IDisposable
 {
     private static readonly TimeSpan s_userDelegationKeyDuration = TimeSpan.FromDays(1);
+    private static readonly TimeSpan s_sweepInterval = TimeSpan.FromMinutes(1);
+
+    // The number of pending buffers whose status is checked at a time, and the maximum number of
+    // such pages per sweep, which spreads out checking a large number of buffers over several sweeps.
+    private const int PendingStatusPageSize = 100;
+    private const int MaxPendingStatusPagesPerSweep = 10;
+
+    private const string StartMetadataBlobName = ".bufferstart";
+    private const string EndMetadataBlobName = ".bufferend";
+    private static readonly JsonSerializerOptions s_endMetadataSerializerOptions = new(JsonSerializerDefaults.Web);
     private readonly IRepository _repository;
     private readonly ILogger<BufferManager> _logger;
     private readonly BlobServiceClient _serviceClient;
+    private readonly TimeSpan _deletedBufferRetention;
     private readonly CancellationTokenSource _backgroundCancellationTokenSource = new();
     private UserDelegationKey? _userDelegationKey;
 
@@ -31,6 +44,7 @@ public sealed class BufferManager : IHealthCheck, IHostedService, IDisposable
         _logger = logger;
         var bufferStorageAccountOptions = config.Value.StorageAccounts[0];
         _serviceClient = new BlobServiceClient(new Uri(bufferStorageAccountOptions.Endpoint), credential);
+        _deletedBufferRetention = config.Value.DeletedBufferRetention;
     }
 
     public async Task<Buffer> CreateBuffer(Buffer newBuffer, CancellationToken cancellationToken)
@@ -59,6 +73,11 @@ public sealed class BufferManager : IHealthCheck, IHostedService, IDisposable
             }
         }
 
+        if (newBuffer.TtlSeconds <= 0)
+        {
+            throw new ValidationException("The TTL of a buffer must be a positive number of seconds");
+        }
+
         string id = UniqueId.Create();
         _logger.CreatingBuffer(id);
         _ = await _serviceClient.CreateBlobContainerAsync(id, cancellationToken: cancellationToken);
@@ -84,6 +103,11 @@ public sealed class BufferManager : IHealthCheck, IHostedService, IDisposable
         {
             if (await containerClient.ExistsAsync(cancellationToken))
             {
+                if (buffer.Status == BufferStatus.Pending && buffer.DeletedAt == null)
+                {
+                    buffer = await RefreshBufferStatus(buffer, cancellationToken);
+                }
+
                 return buffer;
             }
         }
@@ -100,16 +124,117 @@ public sealed class BufferManager : IHealthCheck, IHostedService, IDisposable
         return await _repository.UpdateBufferById(id, eTag, tags, cancellationToken);
     }
 
-    public async Task<(IList<Buffer>, string? nextContinuationToken)> GetBuffers(IDictionary<string, string>? tags, int limit, string? continuationToken, CancellationToken cancellationToken)
+    public async Task<Buffer?> DeleteBufferById(string id, CancellationToken cancellationToken)
+    {
+        if (await GetBufferById(id, cancellationToken) is null)
+        {
+            return null;
+        }
+
+        if (await _repository.GetDatasetUsingBuffer(id, cancellationToken) is Dataset dataset)
+        {
+            throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The buffer '{0}' holds version {1} of the dataset '{2}' and cannot be deleted.", id, dataset.Version, dataset.Name));
+        }
+
+        _logger.DeletingBuffer(id);
+        return await _repository.SoftDeleteBuffer(id, cancellationToken);
+    }
+
+    public async Task<(IList<Buffer>, string? nextContinuationToken)> GetBuffers(IDictionary<string, string>? tags, BufferStatus? status, long? minByteCount, long? maxByteCount, DateTimeOffset? createdBefore, int limit, string? continuationToken, CancellationToken cancellationToken)
+    {
+        return await _repository.GetBuffers(tags, status, minByteCount, maxByteCount, createdBefore, limit, continuationToken, cancellationToken);
+    }
+
+    /// <summary>
+    /// Reads the end metadata that the writer of a pending buffer leaves once it is complete or has failed,
+    /// and records the buffer's status and size in the database.
+    /// </summary>
+    private async Task<Buffer> RefreshBufferStatus(Buffer buffer, CancellationToken cancellationToken)
+    {
+        var containerClient = _serviceClient.GetBlobContainerClient(buffer.Id);
+        BufferEndMetadata? endMetadata;
+        try
+        {
+            var content = (await containerClient.GetBlobClient(EndMetadataBlobName).DownloadContentAsync(cancellationToken)).Value.Content;
+            endMetadata = content.ToObjectFromJson<BufferEndMetadata>(s_endMetadataSerializerOptions);
+        }
+        catch (RequestFailedException e) when (e.Status == StatusCodes.Status404NotFound)
+        {
+            return buffer;
+        }
+        catch (JsonException e)
+        {
+            _logger.InvalidBufferEndMetadata(buffer.Id, e);
+            return buffer;
+        }
+
+        BufferStatus status;
+        switch (endMetadata?.Status)
+        {
+            case "complete":
+                status = BufferStatus.Complete;
+                break;
+            case "failed":
+                status = BufferStatus.Failed;
+                break;
+            default:
+                _logger.InvalidBufferEndMetadata(buffer.Id, null);
+                return buffer;
+        }
+
+        long? byteCount = null;
+        long? blobCount = null;
+        if (status == BufferStatus.Complete)
+        {
+            byteCount = endMetadata!.ByteCount;
+            blobCount = endMetadata.BlobCount;
+            if (byteCount == null || blobCount == null)
+            {
+                // Writers before the counts were added to the end metadata did not record them.
+                byteCount = 0;
+                blobCount = 0;
+                await foreach (var blob in containerClient.GetBlobsAsync(cancellationToken: cancellationToken))
+                {
+                    if (blob.Name is StartMetadataBlobName or EndMetadataBlobName || blob.Properties.ContentLength is null or 0)
+                    {
+                        continue;
+                    }
+
+                    byteCount += blob.Properties.ContentLength.Value;
+                    blobCount++;
+                }
+            }
+        }
+
+        await _repository.UpdateBufferStatus(buffer.Id, status, byteCount, blobCount, cancellationToken);
+        return buffer with { Status = status, ByteCount = byteCount, BlobCount = blobCount };
+    }
+
+    private async Task RefreshPendingBufferStatuses(CancellationToken cancellationToken)
     {
-        return await _repository.GetBuffers(tags, limit, continuationToken, cancellationToken);
+        for (int page = 0; page < MaxPendingStatusPagesPerSweep; page++)
+        {
+            var ids = await _repository.GetPageOfPendingBuffersToCheck(PendingStatusPageSize, cancellationToken);
+            foreach (var id in ids)
+            {
+                await RefreshBufferStatus(new Buffer { Id = id, Status = BufferStatus.Pending }, cancellationToken);
+            }
+
+            if (ids.Count < PendingStatusPageSize)
+            {
+                break;
+            }
+        }
     }
 
     internal async Task<BufferAccess?> CreateBufferAccessString(string id, bool writeable, CancellationToken cancellationToken)
     {
-        if (await GetBufferById(id, cancellationToken) is null)
+        switch (await GetBufferById(id, cancellationToken))
         {
-            return null;
+            case null:
+                return null;
+            case { DeletedAt: not null }:
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The buffer '{0}' has been deleted", id));
         }
 
         var permissions = BlobContainerSasPermissions.Read;
@@ -186,8 +311,59 @@ public sealed class BufferManager : IHealthCheck, IHostedService, IDisposable
             }
         }
 
+        async Task SweepLoop(CancellationToken cancellationToken)
+        {
+            while (!cancellationToken.IsCancellationRequested)
+            {
+                try
+                {
+                    await Task.Delay(s_sweepInterval, cancellationToken);
+                    await RefreshPendingBufferStatuses(cancellationToken);
+                    await SweepDeletedBuffers(cancellationToken);
+                }
+                catch (TaskCanceledException) when (cancellationToken.IsCancellationRequested)
+                {
+                    return;
+                }
+                catch (Exception e)
+                {
+                    _logger.ErrorDuringBufferSweep(e);
+                }
+            }
+        }
+
         await RefreshUserDelegationKey(cancellationToken);
         _ = BackgroundLoop(_backgroundCancellationTokenSource.Token);
+        _ = SweepLoop(_backgroundCancellationTokenSource.Token);
+    }
+
+    /// <summary>
+    /// Soft-deletes buffers that have expired and permanently removes buffers that were deleted
+    /// longer ago than the retention period.
+    /// </summary>
+    private async Task SweepDeletedBuffers(CancellationToken cancellationToken)
+    {
+        var expiredCount = await _repository.SoftDeleteExpiredBuffers(cancellationToken);
+        if (expiredCount > 0)
+        {
+            _logger.DeletedExpiredBuffers(expiredCount);
+        }
+
+        while (true)
+        {
+            var ids = await _repository.GetPageOfBuffersToPurge(DateTimeOffset.UtcNow - _deletedBufferRetention, cancellationToken);
+            if (ids.Count == 0)
+            {
+                break;
+            }
+
+            foreach (var id in ids)
+            {
+                _logger.PurgingBuffer(id);
+                await _serviceClient.GetBlobContainerClient(id).DeleteIfExistsAsync(cancellationToken: cancellationToken);
+                await _repository.PurgeBuffer(id, cancellationToken);
+            }
+        }
     }
 
     Task IHostedService.StopAsync(CancellationToken cancellationToken)
@@ -206,4 +382,6 @@ public sealed class BufferManager : IHealthCheck, IHostedService, IDisposable
     {
         _backgroundCancellationTokenSource.Dispose();
     }
+
+    private sealed record BufferEndMetadata(string? Status, long? BlobCount, long? ByteCount);
 }
@@ -35,9 +35,21 @@ public static class Buffers
             .WithName("createBuffer")
             .Produces<Buffer>(StatusCodes.Status201Created);
 
-        app.MapGet("/v1/buffers", async (BufferManager manager, HttpContext context, int? limit, [FromQuery(Name = "_ct")] string? continuationToken, CancellationToken cancellationToken) =>
+        app.MapGet("/v1/buffers", async (BufferManager manager, HttpContext context, int? limit, string? status, long? minByteCount, long? maxByteCount, DateTimeOffset? createdBefore, [FromQuery(Name = "_ct")] string? continuationToken, CancellationToken cancellationToken) =>
             {
                 limit = limit is null ? 20 : Math.Min(limit.Value, 200);
+
+                BufferStatus? parsedStatus = null;
+                if (status != null)
+                {
+                    if (!Enum.TryParse<BufferStatus>(status, ignoreCase: true, out var parsed) || !Enum.IsDefined(parsed))
+                    {
+                        throw new ValidationException($"Invalid status '{status}'. Must be one of {string.Join(", ", Enum.GetNames<BufferStatus>())}.");
+                    }
+
+                    parsedStatus = parsed;
+                }
+
                 var tagQuery = new Dictionary<string, string>();
 
                 foreach (var tag in context.Request.Query)
@@ -53,7 +65,7 @@ public static class Buffers
                     tagQuery = null;
                 }
 
-                (var buffers, var nextContinuationToken) = await manager.GetBuffers(tagQuery, limit.Value, continuationToken, cancellationToken);
+                (var buffers, var nextContinuationToken) = await manager.GetBuffers(tagQuery, parsedStatus, minByteCount, maxByteCount, createdBefore, limit.Value, continuationToken, cancellationToken);
 
                 string? nextLink;
                 if (nextContinuationToken is null)
@@ -91,6 +103,22 @@ public static class Buffers
             .Produces<Buffer>(StatusCodes.Status200OK)
             .Produces<ErrorBody>(StatusCodes.Status404NotFound);
 
+        app.MapDelete("/v1/buffers/{id}", async (BufferManager manager, HttpContext context, string id, CancellationToken cancellationToken) =>
+            {
+                var buffer = await manager.DeleteBufferById(id, cancellationToken);
+                if (buffer != null)
+                {
+                    context.Response.Headers.ETag = buffer.ETag;
+                    return Results.Ok(buffer);
+                }
+
+                return Responses.NotFound();
+            })
+            .WithName("deleteBuffer")
+            .Produces<Buffer>(StatusCodes.Status200OK)
+            .Produces<ErrorBody>(StatusCodes.Status400BadRequest)
+            .Produces<ErrorBody>(StatusCodes.Status404NotFound);
+
         app.MapPut("/v1/buffers/{id}/tags", async (BufferManager manager, HttpContext context, string id, CancellationToken cancellationToken) =>
             {
                 string eTag = context.Request.Headers.IfMatch.FirstOrDefault() ?? "";
@@ -149,6 +177,11 @@ public class BufferOptions
 
     [Required]
     public required string BufferSidecarImage { get; init; }
+
+    /// <summary>
+    /// How long a deleted or expired buffer is kept before it is permanently removed.
+    /// </summary>
+    public TimeSpan DeletedBufferRetention { get; init; } = TimeSpan.FromDays(7);
 }
 
 public class BufferStorageAccountOptions
@@ -16,4 +16,19 @@ public static partial class LogingExtensions
 
     [LoggerMessage(2, LogLevel.Error, "Failed to refresh user delegation key (expired)")]
     public static partial void FailedToRefreshExpiredUserDelegationKey(this ILogger logger, Exception ex);
+
+    [LoggerMessage(3, LogLevel.Information, "Deleting buffer {bufferId}")]
+    public static partial void DeletingBuffer(this ILogger logger, string bufferId);
+
+    [LoggerMessage(4, LogLevel.Information, "Deleted {count} expired buffers")]
+    public static partial void DeletedExpiredBuffers(this ILogger logger, int count);
+
+    [LoggerMessage(5, LogLevel.Information, "Permanently removing deleted buffer {bufferId}")]
+    public static partial void PurgingBuffer(this ILogger logger, string bufferId);
+
+    [LoggerMessage(6, LogLevel.Error, "Error during background buffer sweep")]
+    public static partial void ErrorDuringBufferSweep(this ILogger logger, Exception ex);
+
+    [LoggerMessage(7, LogLevel.Warning, "The end metadata of buffer {bufferId} is not valid")]
+    public static partial void InvalidBufferEndMetadata(this ILogger logger, string bufferId, Exception? ex);
 }
@@ -0,0 +1,22 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+using Microsoft.Extensions.Options;
+using Tyger.Server.Kubernetes;
+using Tyger.Server.Model;
+
+namespace Tyger.Server.Clusters;
+
+public static class Clusters
+{
+    public static void MapClusters(this WebApplication app)
+    {
+        app.MapGet("/v1/clusters", (IOptions<KubernetesApiOptions> k8sOptions) =>
+        {
+            return k8sOptions.Value.Clusters
+                .Select(c => new Cluster(c.Name, c.Location, c.UserNodePools.Select(np => new NodePool(np.Name, np.VmSize)).ToList()))
+                .ToList();
+        })
+        .Produces<IReadOnlyList<Cluster>>();
+    }
+}
@@ -9,6 +9,7 @@ using Microsoft.AspNetCore.WebUtilities;
 using Microsoft.Extensions.Primitives;
 using Tyger.Server.Database;
 using Tyger.Server.Json;
+using Tyger.Server.Kubernetes;
 using Tyger.Server.Model;
 
 namespace Tyger.Server.Codespecs;
@@ -19,10 +20,9 @@ public static class Codespecs
     {
         app.MapPut("/v1/codespecs/{name}", async (string name, IRepository repository, HttpContext context) =>
         {
-            string pattern = @"^[a-z0-9\-._]*$";
-            if (!Regex.IsMatch(name, pattern))
+            if (!IsValidName(name))
             {
-                throw new ValidationException("Codespec names must contain only lower case letters (a-z), numbers (0-9), dashes (-), underscores (_), and dots (.)");
+                throw new ValidationException(InvalidNameMessage);
             }
 
             var newCodespec = await context.Request.ReadAndValidateJson<Codespec>(context.RequestAborted);
@@ -41,6 +41,23 @@ public static class Codespecs
         .Produces<Codespec>(StatusCodes.Status201Created)
         .Produces<ErrorBody>(StatusCodes.Status400BadRequest);
 
+        app.MapPost("/v1/codespecs/validate", async (RunCreator runCreator, HttpContext context, string? cluster, string? nodePool) =>
+        {
+            var codespec = await context.Request.ReadAndValidateJson<Codespec>(context.RequestAborted, validate: false);
+
+            var problems = new List<CodespecProblem>();
+            if (!string.IsNullOrEmpty(codespec.Name) && !IsValidName(codespec.Name))
+            {
+                problems.Add(new("name", InvalidNameMessage));
+            }
+
+            problems.AddRange(await runCreator.ValidateCodespec(codespec, cluster, nodePool, context.RequestAborted));
+            return Results.Ok(new CodespecValidationResult(problems));
+        })
+        .Accepts<Codespec>("application/json")
+        .Produces<CodespecValidationResult>()
+        .Produces<ErrorBody>(StatusCodes.Status400BadRequest);
+
         app.MapGet("/v1/codespecs/{name}", async (string name, IRepository repository, HttpContext context) =>
         {
             Codespec? codespec = await repository.GetLatestCodespec(name, context.RequestAborted);
@@ -79,6 +96,36 @@ public static class Codespecs
         })
         .Produces<CodespecPage>();
 
+        app.MapGet("/v1/codespecs/{name}/versions", async (string name, IRepository repository, int? limit, [FromQuery(Name = "_ct")] string? continuationToken, HttpContext context) =>
+        {
+            limit = limit is null ? 20 : Math.Min(limit.Value, 200);
+            (var codespecs, var nextContinuationToken) = await repository.GetCodespecVersions(name, limit.Value, continuationToken, context.RequestAborted);
+            if (codespecs.Count == 0 && continuationToken is null)
+            {
+                return Responses.NotFound();
+            }
+
+            string? nextLink;
+            if (nextContinuationToken is null)
+            {
+                nextLink = null;
+            }
+            else if (context.Request.QueryString.HasValue)
+            {
+                var qd = QueryHelpers.ParseQuery(context.Request.QueryString.Value);
+                qd["_ct"] = new StringValues(nextContinuationToken);
+                nextLink = QueryHelpers.AddQueryString(context.Request.Path, qd);
+            }
+            else
+            {
+                nextLink = QueryHelpers.AddQueryString(context.Request.Path, "_ct", nextContinuationToken);
+            }
+
+            return Results.Ok(new CodespecPage(codespecs, nextLink == null ? null : new Uri(nextLink)));
+        })
+        .Produces<CodespecPage>()
+        .Produces<ErrorBody>(StatusCodes.Status404NotFound);
+
         app.MapGet("/v1/codespecs/{name}/versions/{version}", async (string name, string version, IRepository repository, CancellationToken cancellationToken) =>
         {
             if (!int.TryParse(version, out var versionInt))
@@ -96,4 +143,8 @@ public static class Codespecs
         })
         .Produces<Codespec>();
     }
+
+    private const string InvalidNameMessage = "Codespec names must contain only lower case letters (a-z), numbers (0-9), dashes (-), underscores (_), and dots (.)";
+
+    private static bool IsValidName(string name) => Regex.IsMatch(name, @"^[a-z0-9\-._]*$");
 }
@@ -12,14 +12,26 @@ public interface IRepository
     Task<Codespec?> GetCodespecAtVersion(string name, int version, CancellationToken cancellationToken);
 
     Task<(IList<Codespec>, string? nextContinuationToken)> GetCodespecs(int limit, string? prefix, string? continuationToken, CancellationToken cancellationToken);
+    Task<(IList<Codespec>, string? nextContinuationToken)> GetCodespecVersions(string name, int limit, string? continuationToken, CancellationToken cancellationToken);
+    Task<Dataset> CreateDatasetVersion(string name, string bufferId, CancellationToken cancellationToken);
+    Task<Dataset?> GetDataset(string name, int? version, CancellationToken cancellationToken);
+    Task<(IList<Dataset>, string? nextContinuationToken)> GetDatasets(int limit, string? continuationToken, CancellationToken cancellationToken);
+    Task<Dataset?> GetDatasetUsingBuffer(string bufferId, CancellationToken cancellationToken);
     Task<Run> CreateRun(Run newRun, CancellationToken cancellationToken);
     Task UpdateRun(Run run, bool? resourcesCreated = null, bool? final = null, DateTimeOffset? logsArchivedAt = null, CancellationToken cancellationToken = default);
+    Task UpdateRunUsage(long id, RunUsage usage, CancellationToken cancellationToken);
     Task DeleteRun(long id, CancellationToken cancellationToken);
     Task<(Run run, bool final, DateTimeOffset? logsArchivedAt)?> GetRun(long id, CancellationToken cancellationToken);
-    Task<(IList<(Run run, bool final)>, string? nextContinuationToken)> GetRuns(int limit, DateTimeOffset? since, string? continuationToken, CancellationToken cancellationToken);
+    Task<(IList<(Run run, bool final)>, string? nextContinuationToken)> GetRuns(int limit, RunFilter filter, string? continuationToken, CancellationToken cancellationToken);
     Task<IList<Run>> GetPageOfRunsThatNeverGotResources(CancellationToken cancellationToken);
     Task<Model.Buffer?> GetBuffer(string id, string eTag, CancellationToken cancellationToken);
-    Task<(IList<Model.Buffer>, string? nextContinuationToken)> GetBuffers(IDictionary<string, string>? tags, int limit, string? continuationToken, CancellationToken cancellationToken);
+    Task<(IList<Model.Buffer>, string? nextContinuationToken)> GetBuffers(IDictionary<string, string>? tags, BufferStatus? status, long? minByteCount, long? maxByteCount, DateTimeOffset? createdBefore, int limit, string? continuationToken, CancellationToken cancellationToken);
     Task<Model.Buffer?> UpdateBufferById(string id, string eTag, IDictionary<string, string>? tags, CancellationToken cancellationToken);
     Task<Model.Buffer> CreateBuffer(Model.Buffer newBuffer, CancellationToken cancellationToken);
+    Task<IList<string>> GetPageOfPendingBuffersToCheck(int limit, CancellationToken cancellationToken);
+    Task UpdateBufferStatus(string id, BufferStatus status, long? byteCount, long? blobCount, CancellationToken cancellationToken);
+    Task<Model.Buffer?> SoftDeleteBuffer(string id, CancellationToken cancellationToken);
+    Task<int> SoftDeleteExpiredBuffers(CancellationToken cancellationToken);
+    Task<IList<string>> GetPageOfBuffersToPurge(DateTimeOffset deletedBefore, CancellationToken cancellationToken);
+    Task PurgeBuffer(string id, CancellationToken cancellationToken);
 }
@@ -19,4 +19,10 @@ public static partial class LoggerExtensions
 
     [LoggerMessage(4, LogLevel.Information, "Failed to refresh database credentials")]
     public static partial void FailedToRefreshDatabaseCredentials(this ILogger logger, Exception exception);
+
+    [LoggerMessage(5, LogLevel.Information, "Creating a version of dataset {name}")]
+    public static partial void CreatingDatasetVersion(this ILogger logger, string name);
+
+    [LoggerMessage(6, LogLevel.Information, "Conflict when creating a version of dataset {name}")]
+    public static partial void CreatingDatasetVersionConflict(this ILogger logger, string name);
 }
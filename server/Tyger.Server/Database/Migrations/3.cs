@@ -0,0 +1,30 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+namespace Tyger.Server.Database.Migrations;
+
+public class Migrator3 : Migrator
+{
+    public override async Task Apply(Npgsql.NpgsqlDataSource dataSource, ILogger logger, CancellationToken cancellationToken)
+    {
+        await using var batch = dataSource.CreateBatch();
+
+        batch.BatchCommands.Add(new("""
+            ALTER TABLE buffers
+                ADD COLUMN IF NOT EXISTS expires_at timestamp with time zone NULL,
+                ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone NULL
+            """));
+
+        batch.BatchCommands.Add(new(
+            WrapCreateIndexWithExistenceCheck(
+                "idx_buffers_expires_at",
+                "CREATE INDEX idx_buffers_expires_at ON buffers (expires_at) WHERE expires_at IS NOT NULL AND deleted_at IS NULL")));
+
+        batch.BatchCommands.Add(new(
+            WrapCreateIndexWithExistenceCheck(
+                "idx_buffers_deleted_at",
+                "CREATE INDEX idx_buffers_deleted_at ON buffers (deleted_at) WHERE deleted_at IS NOT NULL")));
+
+        await batch.ExecuteNonQueryAsync(cancellationToken);
+    }
+}
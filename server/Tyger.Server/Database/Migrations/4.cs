@@ -0,0 +1,26 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+namespace Tyger.Server.Database.Migrations;
+
+public class Migrator4 : Migrator
+{
+    public override async Task Apply(Npgsql.NpgsqlDataSource dataSource, ILogger logger, CancellationToken cancellationToken)
+    {
+        await using var batch = dataSource.CreateBatch();
+
+        batch.BatchCommands.Add(new("""
+            ALTER TABLE buffers
+                ADD COLUMN IF NOT EXISTS status text NULL,
+                ADD COLUMN IF NOT EXISTS byte_count bigint NULL,
+                ADD COLUMN IF NOT EXISTS blob_count bigint NULL
+            """));
+
+        batch.BatchCommands.Add(new(
+            WrapCreateIndexWithExistenceCheck(
+                "idx_buffers_created_at_pending",
+                "CREATE INDEX idx_buffers_created_at_pending ON buffers (created_at) WHERE status IS NULL AND deleted_at IS NULL")));
+
+        await batch.ExecuteNonQueryAsync(cancellationToken);
+    }
+}
@@ -0,0 +1,29 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+namespace Tyger.Server.Database.Migrations;
+
+public class Migrator5 : Migrator
+{
+    public override async Task Apply(Npgsql.NpgsqlDataSource dataSource, ILogger logger, CancellationToken cancellationToken)
+    {
+        await using var batch = dataSource.CreateBatch();
+
+        batch.BatchCommands.Add(new("""
+            CREATE TABLE IF NOT EXISTS datasets (
+                name text NOT NULL COLLATE "C",
+                version integer NOT NULL,
+                created_at timestamp with time zone NOT NULL DEFAULT (now() AT TIME ZONE 'UTC'),
+                buffer_id text NOT NULL,
+                PRIMARY KEY (name, version)
+            )
+            """));
+
+        batch.BatchCommands.Add(new(
+            WrapCreateIndexWithExistenceCheck(
+                "idx_datasets_buffer_id",
+                "CREATE INDEX idx_datasets_buffer_id ON datasets (buffer_id)")));
+
+        await batch.ExecuteNonQueryAsync(cancellationToken);
+    }
+}
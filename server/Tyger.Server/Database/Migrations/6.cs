@@ -0,0 +1,26 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+namespace Tyger.Server.Database.Migrations;
+
+public class Migrator6 : Migrator
+{
+    public override async Task Apply(Npgsql.NpgsqlDataSource dataSource, ILogger logger, CancellationToken cancellationToken)
+    {
+        await using var batch = dataSource.CreateBatch();
+
+        batch.BatchCommands.Add(new("""
+            ALTER TABLE buffers
+                ADD COLUMN IF NOT EXISTS status_checked_at timestamp with time zone NULL
+            """));
+
+        batch.BatchCommands.Add(new("DROP INDEX IF EXISTS idx_buffers_created_at_pending"));
+
+        batch.BatchCommands.Add(new(
+            WrapCreateIndexWithExistenceCheck(
+                "idx_buffers_status_checked_at_pending",
+                "CREATE INDEX idx_buffers_status_checked_at_pending ON buffers (status_checked_at NULLS FIRST) WHERE status IS NULL AND deleted_at IS NULL")));
+
+        await batch.ExecuteNonQueryAsync(cancellationToken);
+    }
+}
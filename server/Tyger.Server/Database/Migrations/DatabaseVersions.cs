@@ -22,6 +22,22 @@ public enum DatabaseVersion
     [Migrator(typeof(Migrator2))]
     [Description("Adding an index to the codespecs table")]
     AddCodespecsIndex = 2,
+
+    [Migrator(typeof(Migrator3))]
+    [Description("Adding buffer expiration and soft deletion")]
+    AddBufferExpiration = 3,
+
+    [Migrator(typeof(Migrator4))]
+    [Description("Adding buffer status and size")]
+    AddBufferStatus = 4,
+
+    [Migrator(typeof(Migrator5))]
+    [Description("Adding datasets")]
+    AddDatasets = 5,
+
+    [Migrator(typeof(Migrator6))]
+    [Description("Adding the time when the status of pending buffers was last checked")]
+    AddBufferStatusCheckedAt = 6,
 }
 
 public sealed class DatabaseVersions : IHostedService, IHealthCheck, IDisposable
@@ -161,6 +161,71 @@ public class Repository : IRepository
         return (results, null);
     }
 
+    public async Task<(IList<Codespec>, string? nextContinuationToken)> GetCodespecVersions(string name, int limit, string? continuationToken, CancellationToken cancellationToken)
+    {
+        var pagingVersion = int.MaxValue;
+        if (continuationToken != null)
+        {
+            bool valid = false;
+            try
+            {
+                var fields = JsonSerializer.Deserialize<int[]>(Encoding.ASCII.GetString(Base32.ZBase32.Decode(continuationToken)), _serializerOptions);
+                if (fields is { Length: 1 })
+                {
+                    pagingVersion = fields[0];
+                    valid = true;
+                }
+            }
+            catch (Exception e) when (e is JsonException or FormatException)
+            {
+            }
+
+            if (!valid)
+            {
+                throw new ValidationException("Invalid continuation token.");
+            }
+        }
+
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand($"""
+            SELECT version, created_at, spec
+            FROM codespecs
+            WHERE name = $2 AND version < $3
+            ORDER BY version DESC
+            LIMIT $1
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { NpgsqlDbType = NpgsqlDbType.Integer, Value = limit + 1 },
+                new() { NpgsqlDbType = NpgsqlDbType.Text, Value = name },
+                new() { NpgsqlDbType = NpgsqlDbType.Integer, Value = pagingVersion },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+
+        var results = new List<Codespec>();
+        await using var reader = (await cmd.ExecuteReaderAsync(CommandBehavior.SequentialAccess, cancellationToken))!;
+        while (await reader.ReadAsync(cancellationToken))
+        {
+            var version = reader.GetInt32(0);
+            var createdAt = reader.GetDateTime(1);
+            Codespec spec = JsonSerializer.Deserialize<Codespec>(reader.GetString(2), _serializerOptions)!;
+            results.Add(spec.WithSystemProperties(name, version, createdAt));
+        }
+
+        if (results.Count == limit + 1)
+        {
+            results.RemoveAt(limit);
+            var last = results[^1];
+            string newToken = Base32.ZBase32.Encode(Encoding.ASCII.GetBytes(JsonSerializer.Serialize(new[] { last.Version!.Value }, _serializerOptions)));
+            return (results, newToken);
+        }
+
+        return (results, null);
+    }
+
     public async Task<Codespec> UpsertCodespec(string name, Codespec newcodespec, CancellationToken cancellationToken)
     {
         newcodespec = newcodespec.WithoutSystemProperties();
@@ -215,6 +280,188 @@ public class Repository : IRepository
         }
     }
 
+    public async Task<Dataset> CreateDatasetVersion(string name, string bufferId, CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            INSERT INTO datasets (name, version, created_at, buffer_id)
+            SELECT
+                $1,
+                CASE WHEN MAX(version) IS NULL THEN 1 ELSE MAX(version) + 1 END,
+                now() AT TIME ZONE 'utc',
+                $2
+            FROM datasets
+            WHERE name = $1
+            RETURNING version, created_at
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { NpgsqlDbType = NpgsqlDbType.Text, Value = name },
+                new() { NpgsqlDbType = NpgsqlDbType.Text, Value = bufferId },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+
+        for (int i = 0; ; i++)
+        {
+            try
+            {
+                _logger.CreatingDatasetVersion(name);
+                await using var reader = await cmd.ExecuteReaderAsync(cancellationToken);
+                await reader.ReadAsync(cancellationToken);
+                return new Dataset
+                {
+                    Name = name,
+                    Version = reader.GetInt32(0),
+                    CreatedAt = reader.GetDateTime(1),
+                    BufferId = bufferId,
+                };
+            }
+            catch (PostgresException e) when (e.SqlState == PostgresErrorCodes.UniqueViolation)
+            {
+                _logger.CreatingDatasetVersionConflict(name);
+                if (i == 5)
+                {
+                    throw;
+                }
+            }
+        }
+    }
+
+    public async Task<Dataset?> GetDataset(string name, int? version, CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            SELECT datasets.name, datasets.version, datasets.created_at, datasets.buffer_id, buffers.byte_count
+            FROM datasets
+            LEFT JOIN buffers ON buffers.id = datasets.buffer_id
+            WHERE datasets.name = $1 AND ($2::integer IS NULL OR datasets.version = $2)
+            ORDER BY datasets.version DESC
+            LIMIT 1
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { NpgsqlDbType = NpgsqlDbType.Text, Value = name },
+                new() { NpgsqlDbType = NpgsqlDbType.Integer, Value = (object?)version ?? DBNull.Value },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+
+        await using var reader = await cmd.ExecuteReaderAsync(CommandBehavior.SequentialAccess, cancellationToken);
+        if (!await reader.ReadAsync(cancellationToken))
+        {
+            return null;
+        }
+
+        return ReadDataset(reader);
+    }
+
+    public async Task<(IList<Dataset>, string? nextContinuationToken)> GetDatasets(int limit, string? continuationToken, CancellationToken cancellationToken)
+    {
+        var pagingName = "";
+        if (continuationToken != null)
+        {
+            bool valid = false;
+            try
+            {
+                var fields = JsonSerializer.Deserialize<string[]>(Encoding.ASCII.GetString(Base32.ZBase32.Decode(continuationToken)), _serializerOptions);
+                if (fields is { Length: 1 })
+                {
+                    pagingName = fields[0];
+                    valid = true;
+                }
+            }
+            catch (Exception e) when (e is JsonException or FormatException)
+            {
+            }
+
+            if (!valid)
+            {
+                throw new ValidationException("Invalid continuation token.");
+            }
+        }
+
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            SELECT DISTINCT ON (datasets.name) datasets.name, datasets.version, datasets.created_at, datasets.buffer_id, buffers.byte_count
+            FROM datasets
+            LEFT JOIN buffers ON buffers.id = datasets.buffer_id
+            WHERE datasets.name > $2
+            ORDER BY datasets.name, datasets.version DESC
+            LIMIT $1
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { NpgsqlDbType = NpgsqlDbType.Integer, Value = limit + 1 },
+                new() { NpgsqlDbType = NpgsqlDbType.Text, Value = pagingName },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+
+        var results = new List<Dataset>();
+        await using var reader = (await cmd.ExecuteReaderAsync(CommandBehavior.SequentialAccess, cancellationToken))!;
+        while (await reader.ReadAsync(cancellationToken))
+        {
+            results.Add(ReadDataset(reader));
+        }
+
+        if (results.Count == limit + 1)
+        {
+            results.RemoveAt(limit);
+            var last = results[^1];
+            string newToken = Base32.ZBase32.Encode(Encoding.ASCII.GetBytes(JsonSerializer.Serialize(new[] { last.Name }, _serializerOptions)));
+            return (results, newToken);
+        }
+
+        return (results, null);
+    }
+
+    public async Task<Dataset?> GetDatasetUsingBuffer(string bufferId, CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            SELECT datasets.name, datasets.version, datasets.created_at, datasets.buffer_id, buffers.byte_count
+            FROM datasets
+            LEFT JOIN buffers ON buffers.id = datasets.buffer_id
+            WHERE datasets.buffer_id = $1
+            LIMIT 1
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { NpgsqlDbType = NpgsqlDbType.Text, Value = bufferId },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+
+        await using var reader = await cmd.ExecuteReaderAsync(CommandBehavior.SequentialAccess, cancellationToken);
+        if (!await reader.ReadAsync(cancellationToken))
+        {
+            return null;
+        }
+
+        return ReadDataset(reader);
+    }
+
+    private static Dataset ReadDataset(NpgsqlDataReader reader)
+    {
+        return new Dataset
+        {
+            Name = reader.GetString(0),
+            Version = reader.GetInt32(1),
+            CreatedAt = reader.GetDateTime(2),
+            BufferId = reader.GetString(3),
+            ByteCount = reader.IsDBNull(4) ? null : reader.GetInt64(4),
+        };
+    }
+
     public async Task<Run> CreateRun(Run newRun, CancellationToken cancellationToken)
     {
         newRun = newRun.WithoutSystemProperties();
@@ -304,6 +551,30 @@ public class Repository : IRepository
         await command.ExecuteNonQueryAsync(cancellationToken);
     }
 
+    /// <summary>
+    /// Replaces only the usage of a run, leaving the rest of the run as it is. Runs that have been
+    /// finalized are not updated, since their usage has already been completed.
+    /// </summary>
+    public async Task UpdateRunUsage(long id, RunUsage usage, CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var command = new NpgsqlCommand("""
+            UPDATE runs
+            SET run = jsonb_set(run, '{usage}', $2)
+            WHERE id = $1 AND NOT final
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { Value = id, NpgsqlDbType = NpgsqlDbType.Bigint },
+                new() { Value = JsonSerializer.Serialize(usage, _serializerOptions), NpgsqlDbType = NpgsqlDbType.Jsonb },
+            }
+        };
+
+        await command.PrepareAsync(cancellationToken);
+        await command.ExecuteNonQueryAsync(cancellationToken);
+    }
+
     public async Task DeleteRun(long id, CancellationToken cancellationToken)
     {
         await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
@@ -352,7 +623,7 @@ public class Repository : IRepository
         return (JsonSerializer.Deserialize<Run>(runJson, _serializerOptions)!, final, logsArchivedAt);
     }
 
-    public async Task<(IList<(Run run, bool final)>, string? nextContinuationToken)> GetRuns(int limit, DateTimeOffset? since, string? continuationToken, CancellationToken cancellationToken)
+    public async Task<(IList<(Run run, bool final)>, string? nextContinuationToken)> GetRuns(int limit, RunFilter filter, string? continuationToken, CancellationToken cancellationToken)
     {
         var sb = new StringBuilder();
         sb.Append("""
@@ -391,10 +662,49 @@ public class Repository : IRepository
             }
         }
 
-        if (since.HasValue)
+        if (filter.Since.HasValue)
         {
             sb.AppendLine($"AND created_at > ${++paramNumber}");
-            parameters.Add(new() { Value = since.Value, NpgsqlDbType = NpgsqlDbType.TimestampTz });
+            parameters.Add(new() { Value = filter.Since.Value, NpgsqlDbType = NpgsqlDbType.TimestampTz });
+        }
+
+        if (filter.CodespecName != null)
+        {
+            sb.AppendLine($"AND run->'job'->'codespec'->>'name' = ${++paramNumber}");
+            parameters.Add(new() { Value = filter.CodespecName, NpgsqlDbType = NpgsqlDbType.Text });
+        }
+
+        if (filter.CodespecVersion.HasValue)
+        {
+            sb.AppendLine($"AND (run->'job'->'codespec'->>'version')::integer = ${++paramNumber}");
+            parameters.Add(new() { Value = filter.CodespecVersion.Value, NpgsqlDbType = NpgsqlDbType.Integer });
+        }
+
+        if (filter.Tags?.Count > 0)
+        {
+            sb.AppendLine($"AND run->'job'->'tags' @> ${++paramNumber}");
+            parameters.Add(new() { Value = JsonSerializer.Serialize(filter.Tags, _serializerOptions), NpgsqlDbType = NpgsqlDbType.Jsonb });
+        }
+
+        // The status of runs that are not final is only known once they have been
+        // reconciled with Kubernetes, so these conditions only apply to final runs.
+        // The caller filters the remaining runs.
+        if (filter.Status.HasValue)
+        {
+            sb.AppendLine($"AND (NOT final OR run->>'status' = ${++paramNumber})");
+            parameters.Add(new() { Value = filter.Status.Value.ToString(), NpgsqlDbType = NpgsqlDbType.Text });
+        }
+
+        if (filter.FinishedBefore.HasValue)
+        {
+            sb.AppendLine($"AND (NOT final OR (run->>'finishedAt')::timestamptz < ${++paramNumber})");
+            parameters.Add(new() { Value = filter.FinishedBefore.Value, NpgsqlDbType = NpgsqlDbType.TimestampTz });
+        }
+
+        if (filter.FinishedAfter.HasValue)
+        {
+            sb.AppendLine($"AND (NOT final OR (run->>'finishedAt')::timestamptz > ${++paramNumber})");
+            parameters.Add(new() { Value = filter.FinishedAfter.Value, NpgsqlDbType = NpgsqlDbType.TimestampTz });
         }
 
         sb.AppendLine("ORDER BY created_at DESC, id DESC");
@@ -464,7 +774,7 @@ public class Repository : IRepository
     {
         await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
         await using var command = new NpgsqlCommand("""
-            SELECT buffers.created_at, buffers.etag, tag_keys.name, tags.value
+            SELECT buffers.created_at, buffers.etag, tag_keys.name, tags.value, buffers.expires_at, buffers.deleted_at, buffers.status, buffers.byte_count, buffers.blob_count
             FROM buffers
             LEFT JOIN tags
                 on buffers.id = tags.id
@@ -487,21 +797,17 @@ public class Repository : IRepository
         }
 
         var tags = new Dictionary<string, string>();
-        string currentETag = "";
-        DateTimeOffset createdAt = DateTimeOffset.MinValue;
+        Buffer? buffer = null;
 
         await command.PrepareAsync(cancellationToken);
         await using var reader = (await command.ExecuteReaderAsync(cancellationToken))!;
         while (await reader.ReadAsync(cancellationToken))
         {
-            if (createdAt == DateTimeOffset.MinValue)
+            if (buffer == null)
             {
-                createdAt = reader.GetDateTime(0);
-            }
-
-            if (string.IsNullOrEmpty(currentETag))
-            {
-                currentETag = reader.GetString(1);
+                var expiresAt = reader.IsDBNull(4) ? (DateTimeOffset?)null : reader.GetDateTime(4);
+                var deletedAt = reader.IsDBNull(5) ? (DateTimeOffset?)null : reader.GetDateTime(5);
+                buffer = WithContentStatus(new Buffer { Id = id, ETag = reader.GetString(1), CreatedAt = reader.GetDateTime(0) }.WithExpiration(expiresAt, deletedAt), reader, 6);
             }
 
             if (!reader.IsDBNull(2) && !reader.IsDBNull(3))
@@ -512,12 +818,19 @@ public class Repository : IRepository
             }
         }
 
-        if (currentETag == "" && createdAt == DateTimeOffset.MinValue)
-        {
-            return null;
-        }
+        return buffer == null ? null : buffer with { Tags = tags };
+    }
 
-        return new Buffer { Id = id, ETag = currentETag, CreatedAt = createdAt, Tags = tags };
+    private static string BufferStatusToString(BufferStatus status) => status.ToString().ToLowerInvariant();
+
+    private static Buffer WithContentStatus(Buffer buffer, NpgsqlDataReader reader, int ordinal)
+    {
+        return buffer with
+        {
+            Status = reader.IsDBNull(ordinal) ? BufferStatus.Pending : Enum.Parse<BufferStatus>(reader.GetString(ordinal), ignoreCase: true),
+            ByteCount = reader.IsDBNull(ordinal + 1) ? null : reader.GetInt64(ordinal + 1),
+            BlobCount = reader.IsDBNull(ordinal + 2) ? null : reader.GetInt64(ordinal + 2),
+        };
     }
 
     private static async Task<long?> GetTagId(NpgsqlConnection conn, string name, CancellationToken cancellationToken)
@@ -544,7 +857,7 @@ public class Repository : IRepository
         return reader.GetInt64(0);
     }
 
-    public async Task<(IList<Buffer>, string? nextContinuationToken)> GetBuffers(IDictionary<string, string>? tags, int limit, string? continuationToken, CancellationToken cancellationToken)
+    public async Task<(IList<Buffer>, string? nextContinuationToken)> GetBuffers(IDictionary<string, string>? tags, BufferStatus? status, long? minByteCount, long? maxByteCount, DateTimeOffset? createdBefore, int limit, string? continuationToken, CancellationToken cancellationToken)
     {
         await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
         await using var command = new NpgsqlCommand
@@ -566,6 +879,9 @@ public class Repository : IRepository
 
         int param = 2;
 
+        // The alias of the buffers table in the query
+        string buffersAlias = tags?.Count > 0 ? "b" : "t1";
+
         if (tags?.Count > 0)
         {
             for (int x = 0; x < tags.Count - 1; x++)
@@ -573,15 +889,14 @@ public class Repository : IRepository
                 commandText.AppendLine($"INNER JOIN tags AS t{x + 2} ON t1.created_at = t{x + 2}.created_at and t1.id = t{x + 2}.id");
             }
 
-            commandText.AppendLine("WHERE");
+            // Deleted buffers are excluded from the results
+            commandText.AppendLine("INNER JOIN buffers AS b ON t1.created_at = b.created_at and t1.id = b.id");
+            commandText.AppendLine("WHERE b.deleted_at IS NULL");
 
             int index = 1;
             foreach (var tag in tags)
             {
-                if (index != 1)
-                {
-                    commandText.Append(" AND ");
-                }
+                commandText.Append(" AND ");
 
                 var id = await GetTagId(conn, tag.Key, cancellationToken);
                 if (id == null)
@@ -596,6 +911,42 @@ public class Repository : IRepository
                 param += 2;
             }
         }
+        else
+        {
+            commandText.AppendLine("WHERE t1.deleted_at IS NULL");
+        }
+
+        if (status == BufferStatus.Pending)
+        {
+            commandText.AppendLine($" AND {buffersAlias}.status IS NULL");
+        }
+        else if (status != null)
+        {
+            commandText.AppendLine($" AND {buffersAlias}.status = ${param}");
+            command.Parameters.Add(new() { Value = BufferStatusToString(status.Value), NpgsqlDbType = NpgsqlDbType.Text });
+            param++;
+        }
+
+        if (minByteCount != null)
+        {
+            commandText.AppendLine($" AND {buffersAlias}.byte_count >= ${param}");
+            command.Parameters.Add(new() { Value = minByteCount.Value, NpgsqlDbType = NpgsqlDbType.Bigint });
+            param++;
+        }
+
+        if (maxByteCount != null)
+        {
+            commandText.AppendLine($" AND {buffersAlias}.byte_count <= ${param}");
+            command.Parameters.Add(new() { Value = maxByteCount.Value, NpgsqlDbType = NpgsqlDbType.Bigint });
+            param++;
+        }
+
+        if (createdBefore != null)
+        {
+            commandText.AppendLine($" AND t1.created_at < ${param}");
+            command.Parameters.Add(new() { Value = createdBefore.Value, NpgsqlDbType = NpgsqlDbType.TimestampTz });
+            param++;
+        }
 
         if (continuationToken != null)
         {
@@ -605,15 +956,7 @@ public class Repository : IRepository
                 var fields = JsonSerializer.Deserialize<string[]>(Encoding.ASCII.GetString(Base32.ZBase32.Decode(continuationToken)), _serializerOptions);
                 if (fields is { Length: 2 })
                 {
-                    if (tags == null)
-                    {
-                        commandText.Append(" WHERE ");
-                    }
-                    else
-                    {
-                        commandText.Append(" AND ");
-                    }
-
+                    commandText.Append(" AND ");
                     commandText.Append($"(t1.created_at, t1.id) < (${param}, ${param + 1})\n");
                     command.Parameters.Add(new() { Value = DateTimeOffset.Parse(fields[0]), NpgsqlDbType = NpgsqlDbType.TimestampTz });
                     command.Parameters.Add(new() { Value = fields[1], NpgsqlDbType = NpgsqlDbType.Text });
@@ -635,7 +978,7 @@ public class Repository : IRepository
             ORDER BY t1.created_at DESC, t1.id DESC
                 LIMIT $1
             )
-            SELECT matches.id, matches.created_at, tag_keys.name, tags.value, buffers.etag
+            SELECT matches.id, matches.created_at, tag_keys.name, tags.value, buffers.etag, buffers.expires_at, buffers.deleted_at, buffers.status, buffers.byte_count, buffers.blob_count
             FROM matches
             LEFT JOIN tags
                 ON matches.id = tags.id AND matches.created_at = tags.created_at
@@ -665,7 +1008,9 @@ public class Repository : IRepository
                     results.Add(currentBuffer with { Tags = currentTags });
                 }
 
-                currentBuffer = currentBuffer with { Id = id, CreatedAt = createdAt, ETag = etag };
+                var expiresAt = reader.IsDBNull(5) ? (DateTimeOffset?)null : reader.GetDateTime(5);
+                var deletedAt = reader.IsDBNull(6) ? (DateTimeOffset?)null : reader.GetDateTime(6);
+                currentBuffer = WithContentStatus(new Buffer { Id = id, CreatedAt = createdAt, ETag = etag }.WithExpiration(expiresAt, deletedAt), reader, 7);
                 currentTags = [];
             }
 
@@ -724,11 +1069,11 @@ public class Repository : IRepository
             bufferCommand.Parameters.Add(new() { Value = eTag, NpgsqlDbType = NpgsqlDbType.Text });
         }
 
-        bufferCommand.CommandText += " RETURNING created_at";
+        bufferCommand.CommandText += " RETURNING created_at, expires_at, deleted_at, status, byte_count, blob_count";
 
         await bufferCommand.PrepareAsync(cancellationToken);
 
-        DateTimeOffset createdAt = DateTimeOffset.MinValue;
+        Buffer buffer;
         await using (var reader = await bufferCommand.ExecuteReaderAsync(cancellationToken))
         {
             // If the query didn't do anything, return null
@@ -739,7 +1084,9 @@ public class Repository : IRepository
 
             await reader.ReadAsync(cancellationToken);
 
-            createdAt = reader.GetDateTime(0);
+            var expiresAt = reader.IsDBNull(1) ? (DateTimeOffset?)null : reader.GetDateTime(1);
+            var deletedAt = reader.IsDBNull(2) ? (DateTimeOffset?)null : reader.GetDateTime(2);
+            buffer = WithContentStatus(new Buffer { Id = id, ETag = newETag, CreatedAt = reader.GetDateTime(0), Tags = tags }.WithExpiration(expiresAt, deletedAt), reader, 3);
 
             await reader.ReadAsync(cancellationToken);
         }
@@ -756,7 +1103,7 @@ public class Repository : IRepository
             Parameters =
                 {
                     new() { Value = id, NpgsqlDbType = NpgsqlDbType.Text },
-                    new() { Value = createdAt, NpgsqlDbType = NpgsqlDbType.TimestampTz },
+                    new() { Value = buffer.CreatedAt, NpgsqlDbType = NpgsqlDbType.TimestampTz },
                 }
         };
 
@@ -768,12 +1115,12 @@ public class Repository : IRepository
             // Add the new tags
             foreach (var tag in tags)
             {
-                await InsertTag(tx, id, createdAt, tag, cancellationToken);
+                await InsertTag(tx, id, buffer.CreatedAt, tag, cancellationToken);
             }
         }
 
         await tx.CommitAsync(cancellationToken);
-        return new Buffer() { Id = id, ETag = newETag, CreatedAt = createdAt, Tags = tags };
+        return buffer;
     }
 
     private static async Task InsertTag(NpgsqlTransaction tx, string id, DateTimeOffset createdAt, KeyValuePair<string, string> tag, CancellationToken cancellationToken)
@@ -817,14 +1164,15 @@ public class Repository : IRepository
             Connection = connection,
             Transaction = tx,
             CommandText = """
-                    INSERT INTO buffers (id, created_at, etag)
-                    VALUES ($1, now() AT TIME ZONE 'utc', $2)
-                    RETURNING created_at
+                    INSERT INTO buffers (id, created_at, etag, expires_at)
+                    VALUES ($1, now() AT TIME ZONE 'utc', $2, (now() AT TIME ZONE 'utc') + $3)
+                    RETURNING created_at, expires_at
                     """,
             Parameters =
                 {
                     new() { Value = newBuffer.Id, NpgsqlDbType = NpgsqlDbType.Text },
                     new() { Value = eTag, NpgsqlDbType = NpgsqlDbType.Text },
+                    new() { Value = newBuffer.TtlSeconds.HasValue ? TimeSpan.FromSeconds(newBuffer.TtlSeconds.Value) : (object)DBNull.Value, NpgsqlDbType = NpgsqlDbType.Interval },
                 }
         };
 
@@ -836,6 +1184,7 @@ public class Repository : IRepository
             await reader.ReadAsync(cancellationToken);
 
             buffer = buffer with { CreatedAt = reader.GetDateTime(0), ETag = eTag };
+            buffer = buffer.WithExpiration(reader.IsDBNull(1) ? (DateTimeOffset?)null : reader.GetDateTime(1), null) with { Status = BufferStatus.Pending, ByteCount = null, BlobCount = null };
 
             await reader.ReadAsync(cancellationToken);
         }
@@ -851,4 +1200,182 @@ public class Repository : IRepository
         await tx.CommitAsync(cancellationToken);
         return buffer;
     }
+
+    /// <summary>
+    /// Returns up to <paramref name="limit"/> pending buffers whose status is due to be checked and records
+    /// that they have been checked, so that the next call continues with other buffers. Buffers that have
+    /// never been checked come first. After that, a buffer is checked again after a tenth of its age,
+    /// but at least a minute and at most a day after the previous check, so that buffers that are never
+    /// completed are checked less and less often.
+    /// </summary>
+    public async Task<IList<string>> GetPageOfPendingBuffersToCheck(int limit, CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            UPDATE buffers
+            SET status_checked_at = now() AT TIME ZONE 'utc'
+            FROM (
+                SELECT id, created_at
+                FROM buffers
+                WHERE status IS NULL AND deleted_at IS NULL
+                    AND (status_checked_at IS NULL
+                        OR status_checked_at < now() - LEAST(GREATEST((status_checked_at - created_at) / 10, interval '1 minute'), interval '1 day'))
+                ORDER BY status_checked_at NULLS FIRST
+                LIMIT $1
+                FOR UPDATE SKIP LOCKED
+            ) AS due
+            WHERE buffers.id = due.id AND buffers.created_at = due.created_at
+            RETURNING buffers.id
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { Value = limit, NpgsqlDbType = NpgsqlDbType.Integer },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+        await using var reader = await cmd.ExecuteReaderAsync(CommandBehavior.SequentialAccess, cancellationToken);
+        var results = new List<string>();
+        while (await reader.ReadAsync(cancellationToken))
+        {
+            results.Add(reader.GetString(0));
+        }
+
+        return results;
+    }
+
+    public async Task UpdateBufferStatus(string id, BufferStatus status, long? byteCount, long? blobCount, CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            UPDATE buffers
+            SET status = $2, byte_count = $3, blob_count = $4
+            WHERE id = $1 AND status IS NULL
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { Value = id, NpgsqlDbType = NpgsqlDbType.Text },
+                new() { Value = BufferStatusToString(status), NpgsqlDbType = NpgsqlDbType.Text },
+                new() { Value = byteCount.HasValue ? byteCount.Value : (object)DBNull.Value, NpgsqlDbType = NpgsqlDbType.Bigint },
+                new() { Value = blobCount.HasValue ? blobCount.Value : (object)DBNull.Value, NpgsqlDbType = NpgsqlDbType.Bigint },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+        await cmd.ExecuteNonQueryAsync(cancellationToken);
+    }
+
+    public async Task<Buffer?> SoftDeleteBuffer(string id, CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            UPDATE buffers
+            SET deleted_at = now() AT TIME ZONE 'utc', etag = $2
+            WHERE id = $1 AND deleted_at IS NULL
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { Value = id, NpgsqlDbType = NpgsqlDbType.Text },
+                new() { Value = DateTime.UtcNow.Ticks.ToString(), NpgsqlDbType = NpgsqlDbType.Text },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+        await cmd.ExecuteNonQueryAsync(cancellationToken);
+
+        // If the buffer had already been deleted, it is returned unchanged.
+        return await GetBuffer(id, "", cancellationToken);
+    }
+
+    public async Task<int> SoftDeleteExpiredBuffers(CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            UPDATE buffers
+            SET deleted_at = now() AT TIME ZONE 'utc', etag = $1
+            WHERE expires_at <= now() AND deleted_at IS NULL
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { Value = DateTime.UtcNow.Ticks.ToString(), NpgsqlDbType = NpgsqlDbType.Text },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+        return await cmd.ExecuteNonQueryAsync(cancellationToken);
+    }
+
+    public async Task<IList<string>> GetPageOfBuffersToPurge(DateTimeOffset deletedBefore, CancellationToken cancellationToken)
+    {
+        await using var conn = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var cmd = new NpgsqlCommand("""
+            SELECT id
+            FROM buffers
+            WHERE deleted_at < $1
+            LIMIT 100
+            """, conn)
+        {
+            Parameters =
+            {
+                new() { Value = deletedBefore, NpgsqlDbType = NpgsqlDbType.TimestampTz },
+            }
+        };
+
+        await cmd.PrepareAsync(cancellationToken);
+        await using var reader = await cmd.ExecuteReaderAsync(CommandBehavior.SequentialAccess, cancellationToken);
+        var results = new List<string>();
+        while (await reader.ReadAsync(cancellationToken))
+        {
+            results.Add(reader.GetString(0));
+        }
+
+        return results;
+    }
+
+    public async Task PurgeBuffer(string id, CancellationToken cancellationToken)
+    {
+        await using var connection = await _dataSource.OpenConnectionAsync(cancellationToken);
+        await using var tx = await connection.BeginTransactionAsync(IsolationLevel.Serializable, cancellationToken);
+
+        using var deleteTagsCommand = new NpgsqlCommand
+        {
+            Connection = connection,
+            Transaction = tx,
+            CommandText = """
+                DELETE FROM tags
+                USING buffers
+                WHERE tags.id = buffers.id AND tags.created_at = buffers.created_at AND buffers.id = $1
+                """,
+            Parameters =
+                {
+                    new() { Value = id, NpgsqlDbType = NpgsqlDbType.Text },
+                }
+        };
+
+        await deleteTagsCommand.PrepareAsync(cancellationToken);
+        await deleteTagsCommand.ExecuteNonQueryAsync(cancellationToken);
+
+        using var deleteBufferCommand = new NpgsqlCommand
+        {
+            Connection = connection,
+            Transaction = tx,
+            CommandText = """
+                DELETE FROM buffers
+                WHERE id = $1
+                """,
+            Parameters =
+                {
+                    new() { Value = id, NpgsqlDbType = NpgsqlDbType.Text },
+                }
+        };
+
+        await deleteBufferCommand.PrepareAsync(cancellationToken);
+        await deleteBufferCommand.ExecuteNonQueryAsync(cancellationToken);
+
+        await tx.CommitAsync(cancellationToken);
+    }
 }
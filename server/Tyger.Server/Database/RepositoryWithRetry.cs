@@ -29,6 +29,11 @@ public class RepositoryWithRetry : IRepository
         return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.CreateBuffer(newBuffer, cancellationToken), cancellationToken);
     }
 
+    public async Task<Dataset> CreateDatasetVersion(string name, string bufferId, CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.CreateDatasetVersion(name, bufferId, cancellationToken), cancellationToken);
+    }
+
     public async Task<Run> CreateRun(Run newRun, CancellationToken cancellationToken)
     {
         return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.CreateRun(newRun, cancellationToken), cancellationToken);
@@ -44,9 +49,34 @@ public class RepositoryWithRetry : IRepository
         return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetBuffer(id, eTag, cancellationToken), cancellationToken);
     }
 
-    public async Task<(IList<Buffer>, string? nextContinuationToken)> GetBuffers(IDictionary<string, string>? tags, int limit, string? continuationToken, CancellationToken cancellationToken)
+    public async Task<(IList<Buffer>, string? nextContinuationToken)> GetBuffers(IDictionary<string, string>? tags, BufferStatus? status, long? minByteCount, long? maxByteCount, DateTimeOffset? createdBefore, int limit, string? continuationToken, CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetBuffers(tags, status, minByteCount, maxByteCount, createdBefore, limit, continuationToken, cancellationToken), cancellationToken);
+    }
+
+    public async Task<Dataset?> GetDataset(string name, int? version, CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetDataset(name, version, cancellationToken), cancellationToken);
+    }
+
+    public async Task<(IList<Dataset>, string? nextContinuationToken)> GetDatasets(int limit, string? continuationToken, CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetDatasets(limit, continuationToken, cancellationToken), cancellationToken);
+    }
+
+    public async Task<Dataset?> GetDatasetUsingBuffer(string bufferId, CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetDatasetUsingBuffer(bufferId, cancellationToken), cancellationToken);
+    }
+
+    public async Task<IList<string>> GetPageOfBuffersToPurge(DateTimeOffset deletedBefore, CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetPageOfBuffersToPurge(deletedBefore, cancellationToken), cancellationToken);
+    }
+
+    public async Task<IList<string>> GetPageOfPendingBuffersToCheck(int limit, CancellationToken cancellationToken)
     {
-        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetBuffers(tags, limit, continuationToken, cancellationToken), cancellationToken);
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetPageOfPendingBuffersToCheck(limit, cancellationToken), cancellationToken);
     }
 
     public async Task<Codespec?> GetCodespecAtVersion(string name, int version, CancellationToken cancellationToken)
@@ -59,6 +89,11 @@ public class RepositoryWithRetry : IRepository
         return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetCodespecs(limit, prefix, continuationToken, cancellationToken), cancellationToken);
     }
 
+    public async Task<(IList<Codespec>, string? nextContinuationToken)> GetCodespecVersions(string name, int limit, string? continuationToken, CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetCodespecVersions(name, limit, continuationToken, cancellationToken), cancellationToken);
+    }
+
     public async Task<Codespec?> GetLatestCodespec(string name, CancellationToken cancellationToken)
     {
         return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetLatestCodespec(name, cancellationToken), cancellationToken);
@@ -74,9 +109,24 @@ public class RepositoryWithRetry : IRepository
         return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetRun(id, cancellationToken), cancellationToken);
     }
 
-    public async Task<(IList<(Run run, bool final)>, string? nextContinuationToken)> GetRuns(int limit, DateTimeOffset? since, string? continuationToken, CancellationToken cancellationToken)
+    public async Task<(IList<(Run run, bool final)>, string? nextContinuationToken)> GetRuns(int limit, RunFilter filter, string? continuationToken, CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetRuns(limit, filter, continuationToken, cancellationToken), cancellationToken);
+    }
+
+    public async Task PurgeBuffer(string id, CancellationToken cancellationToken)
+    {
+        await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.PurgeBuffer(id, cancellationToken), cancellationToken);
+    }
+
+    public async Task<Buffer?> SoftDeleteBuffer(string id, CancellationToken cancellationToken)
     {
-        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.GetRuns(limit, since, continuationToken, cancellationToken), cancellationToken);
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.SoftDeleteBuffer(id, cancellationToken), cancellationToken);
+    }
+
+    public async Task<int> SoftDeleteExpiredBuffers(CancellationToken cancellationToken)
+    {
+        return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.SoftDeleteExpiredBuffers(cancellationToken), cancellationToken);
     }
 
     public async Task<Buffer?> UpdateBufferById(string id, string eTag, IDictionary<string, string>? tags, CancellationToken cancellationToken)
@@ -84,11 +134,21 @@ public class RepositoryWithRetry : IRepository
         return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.UpdateBufferById(id, eTag, tags, cancellationToken), cancellationToken);
     }
 
+    public async Task UpdateBufferStatus(string id, BufferStatus status, long? byteCount, long? blobCount, CancellationToken cancellationToken)
+    {
+        await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.UpdateBufferStatus(id, status, byteCount, blobCount, cancellationToken), cancellationToken);
+    }
+
     public async Task UpdateRun(Run run, bool? resourcesCreated = null, bool? final = null, DateTimeOffset? logsArchivedAt = null, CancellationToken cancellationToken = default)
     {
         await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.UpdateRun(run, resourcesCreated, final, logsArchivedAt, cancellationToken), cancellationToken);
     }
 
+    public async Task UpdateRunUsage(long id, RunUsage usage, CancellationToken cancellationToken)
+    {
+        await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.UpdateRunUsage(id, usage, cancellationToken), cancellationToken);
+    }
+
     public async Task<Codespec> UpsertCodespec(string name, Codespec newcodespec, CancellationToken cancellationToken)
     {
         return await _resiliencePipeline.ExecuteAsync(async cancellationToken => await _repository.UpsertCodespec(name, newcodespec, cancellationToken), cancellationToken);
@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+using System.ComponentModel.DataAnnotations;
+using System.Globalization;
+using System.Text.RegularExpressions;
+using Microsoft.AspNetCore.Mvc;
+using Microsoft.AspNetCore.WebUtilities;
+using Microsoft.Extensions.Primitives;
+using Tyger.Server.Buffers;
+using Tyger.Server.Database;
+using Tyger.Server.Json;
+using Tyger.Server.Model;
+
+namespace Tyger.Server.Datasets;
+
+public static class Datasets
+{
+    public const string NamePattern = "^[a-z0-9][-a-z0-9_.]*$";
+    public const int MaxNameLength = 63;
+
+    public static void MapDatasets(this WebApplication app)
+    {
+        app.MapPost("/v1/datasets/{name}/versions", async (string name, IRepository repository, BufferManager bufferManager, HttpContext context) =>
+        {
+            if (name.Length > MaxNameLength || !Regex.IsMatch(name, NamePattern))
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "Dataset names must contain only lower case letters (a-z), numbers (0-9), dashes (-), underscores (_), and dots (.), must start with a letter or number, and must be at most {0} characters long.", MaxNameLength));
+            }
+
+            var newVersion = await context.Request.ReadAndValidateJson<NewDatasetVersion>(context.RequestAborted);
+
+            // The buffer must outlive the dataset version, and runs can only mount it once it has been completely written.
+            var buffer = await bufferManager.GetBufferById(newVersion.BufferId, context.RequestAborted);
+            if (buffer is null || buffer.DeletedAt is not null)
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The buffer '{0}' was not found.", newVersion.BufferId));
+            }
+
+            if (buffer.ExpiresAt is not null)
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The buffer '{0}' has a TTL. The buffer of a dataset version must not expire.", newVersion.BufferId));
+            }
+
+            if (buffer.Status != BufferStatus.Complete)
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The buffer '{0}' is not complete.", newVersion.BufferId));
+            }
+
+            var dataset = await repository.CreateDatasetVersion(name, newVersion.BufferId, context.RequestAborted);
+            context.Response.Headers.Location = $"/v1/datasets/{name}/versions/{dataset.Version}";
+            return Results.Json(dataset with { ByteCount = buffer.ByteCount }, statusCode: StatusCodes.Status201Created);
+        })
+        .Accepts<NewDatasetVersion>("application/json")
+        .Produces<Dataset>(StatusCodes.Status201Created)
+        .Produces<ErrorBody>(StatusCodes.Status400BadRequest);
+
+        app.MapGet("/v1/datasets", async (IRepository repository, int? limit, [FromQuery(Name = "_ct")] string? continuationToken, HttpContext context) =>
+        {
+            limit = limit is null ? 20 : Math.Min(limit.Value, 200);
+            (var datasets, var nextContinuationToken) = await repository.GetDatasets(limit.Value, continuationToken, context.RequestAborted);
+
+            string? nextLink;
+            if (nextContinuationToken is null)
+            {
+                nextLink = null;
+            }
+            else if (context.Request.QueryString.HasValue)
+            {
+                var qd = QueryHelpers.ParseQuery(context.Request.QueryString.Value);
+                qd["_ct"] = new StringValues(nextContinuationToken);
+                nextLink = QueryHelpers.AddQueryString(context.Request.Path, qd);
+            }
+            else
+            {
+                nextLink = QueryHelpers.AddQueryString(context.Request.Path, "_ct", nextContinuationToken);
+            }
+
+            return Results.Ok(new DatasetPage(datasets, nextLink == null ? null : new Uri(nextLink)));
+        })
+        .Produces<DatasetPage>();
+
+        app.MapGet("/v1/datasets/{name}", async (string name, IRepository repository, HttpContext context) =>
+        {
+            if (await repository.GetDataset(name, null, context.RequestAborted) is not Dataset dataset)
+            {
+                return Responses.NotFound();
+            }
+
+            context.Response.Headers.Location = $"/v1/datasets/{name}/versions/{dataset.Version}";
+            return Results.Ok(dataset);
+        })
+        .Produces<Dataset>()
+        .Produces<ErrorBody>(StatusCodes.Status404NotFound);
+
+        app.MapGet("/v1/datasets/{name}/versions/{version}", async (string name, string version, IRepository repository, CancellationToken cancellationToken) =>
+        {
+            if (!int.TryParse(version, out var versionInt))
+            {
+                return Responses.NotFound();
+            }
+
+            if (await repository.GetDataset(name, versionInt, cancellationToken) is not Dataset dataset)
+            {
+                return Responses.NotFound();
+            }
+
+            return Results.Ok(dataset);
+        })
+        .Produces<Dataset>()
+        .Produces<ErrorBody>(StatusCodes.Status404NotFound);
+    }
+}
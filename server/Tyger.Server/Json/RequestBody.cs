@@ -8,7 +8,7 @@ namespace Tyger.Server.Json;
 
 public static class RequestBody
 {
-    public static async ValueTask<TValue> ReadAndValidateJson<TValue>(this HttpRequest request, CancellationToken cancellationToken, bool allowEmpty = false) where TValue : class
+    public static async ValueTask<TValue> ReadAndValidateJson<TValue>(this HttpRequest request, CancellationToken cancellationToken, bool allowEmpty = false, bool validate = true) where TValue : class
     {
         TValue? value;
         try
@@ -28,7 +28,11 @@ public static class RequestBody
                 }
             }
 
-            Validator.ValidateObject(value, new ValidationContext(value), validateAllProperties: true);
+            if (validate)
+            {
+                Validator.ValidateObject(value, new ValidationContext(value), validateAllProperties: true);
+            }
+
             return value;
         }
         catch (JsonException e)
@@ -36,8 +36,13 @@ public static class Kubernetes
             services.AddSingleton<RunReader>();
             services.AddSingleton<RunUpdater>();
             services.AddSingleton<ILogSource, RunLogReader>();
+            services.AddSingleton<RunExecutor>();
+            services.AddSingleton<RunEventReader>();
+            services.AddSingleton<SecretManager>();
             services.AddSingleton<RunSweeper>();
             services.AddSingleton<IHostedService, RunSweeper>(sp => sp.GetRequiredService<RunSweeper>());
+            services.AddSingleton<RunUsageMonitor>();
+            services.AddSingleton<IHostedService, RunUsageMonitor>(sp => sp.GetRequiredService<RunUsageMonitor>());
         }
     }
 }
@@ -66,6 +71,12 @@ public class KubernetesApiOptions : KubernetesCoreOptions
 
     [Required]
     public required string CurrentPodUid { get; init; }
+
+    /// <summary>
+    /// The directory on each node where the datasets mounted by runs are cached between runs.
+    /// The cache is kept within its size limit by the dataset cache DaemonSet.
+    /// </summary>
+    public string DatasetCacheHostPath { get; init; } = "/var/lib/tyger/datasets";
 }
 
 public class ClusterOptions
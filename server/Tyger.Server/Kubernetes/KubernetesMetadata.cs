@@ -1,6 +1,10 @@
 // Copyright (c) Microsoft Corporation.
 // Licensed under the MIT License.
 
+using System.Globalization;
+using k8s.Models;
+using Tyger.Server.Model;
+
 namespace Tyger.Server.Kubernetes;
 
 public static class KubernetesMetadata
@@ -10,8 +14,31 @@ public static class KubernetesMetadata
     public const string RunLabel = "tyger-run";
     public const string JobLabel = "tyger-job";
     public const string WorkerLabel = "tyger-worker";
+    public const string SecretLabel = "tyger-secret";
 
     public static string JobNameFromRunId(long id) => $"run-{id}-job";
     public static string SecretNameFromRunId(long id) => JobNameFromRunId(id);
+    public static string DatasetSecretNameFromRunId(long id) => $"run-{id}-datasets";
     public static string StatefulSetNameFromRunId(long id) => $"run-{id}-worker";
+    public static string KubernetesSecretNameFromSecretName(string name) => $"secret-{name}";
+
+    /// <summary>
+    /// Returns whether a pod of a run belongs to the job or to the worker, and the index of its replica.
+    /// </summary>
+    public static (string target, int replica) GetReplica(V1Pod pod)
+    {
+        if (pod.GetLabel(JobLabel) is not null)
+        {
+            if (!int.TryParse(pod.GetAnnotation("batch.kubernetes.io/job-completion-index"), CultureInfo.InvariantCulture, out var index))
+            {
+                throw new InvalidOperationException($"Pod {pod.Name()} is missing the job-completion-index annotation");
+            }
+
+            return (ReplicaUsage.JobTarget, index);
+        }
+
+        // Worker pods belong to a StatefulSet and their names end with their ordinal
+        var name = pod.Name();
+        return (ReplicaUsage.WorkerTarget, int.Parse(name[(name.LastIndexOf('-') + 1)..], CultureInfo.InvariantCulture));
+    }
 }
@@ -46,4 +46,22 @@ public static partial class LoggerExtensions
     [LoggerMessage(16, LogLevel.Information, "Restarting watch after exception")]
     public static partial void RestartingWatchAfterException(this ILogger logger, Exception exception);
 
+    [LoggerMessage(17, LogLevel.Error, "Error measuring the resource usage of runs.")]
+    public static partial void ErrorMeasuringRunUsage(this ILogger logger, Exception exception);
+
+    [LoggerMessage(18, LogLevel.Warning, "The metrics API is not available (status code {statusCode}). The CPU and memory usage of runs will not be recorded.")]
+    public static partial void MetricsApiUnavailable(this ILogger logger, System.Net.HttpStatusCode statusCode);
+
+    [LoggerMessage(19, LogLevel.Information, "Executing command {command} in pod {pod}")]
+    public static partial void ExecutingCommandInPod(this ILogger logger, string pod, string command);
+
+    [LoggerMessage(20, LogLevel.Information, "Saved secret {secret}")]
+    public static partial void SavedSecret(this ILogger logger, string secret);
+
+    [LoggerMessage(21, LogLevel.Information, "Deleted secret {secret}")]
+    public static partial void DeletedSecret(this ILogger logger, string secret);
+
+    [LoggerMessage(22, LogLevel.Warning, "Unable to list nodes (status code {statusCode}). Codespec requests will not be checked against node capacity.")]
+    public static partial void UnableToListNodes(this ILogger logger, System.Net.HttpStatusCode statusCode);
+
 }
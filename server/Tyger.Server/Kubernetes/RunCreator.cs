@@ -4,9 +4,11 @@
 using System.Collections.Immutable;
 using System.ComponentModel.DataAnnotations;
 using System.Globalization;
+using System.Net;
 using System.Text;
 using System.Text.Json;
 using k8s;
+using k8s.Autorest;
 using k8s.Models;
 using Microsoft.Extensions.Options;
 using Tyger.Server.Buffers;
@@ -21,6 +23,7 @@ public class RunCreator
     private readonly IKubernetes _client;
     private readonly IRepository _repository;
     private readonly BufferManager _bufferManager;
+    private readonly SecretManager _secretManager;
     private readonly BufferOptions _bufferOptions;
     private readonly KubernetesApiOptions _k8sOptions;
     private readonly ILogger<RunCreator> _logger;
@@ -30,6 +33,7 @@ public class RunCreator
         IKubernetes client,
         IRepository repository,
         BufferManager bufferManager,
+        SecretManager secretManager,
         IOptions<KubernetesApiOptions> k8sOptions,
         IOptions<BufferOptions> bufferOptions,
         ILogger<RunCreator> logger)
@@ -37,6 +41,7 @@ public class RunCreator
         _client = client;
         _repository = repository;
         _bufferManager = bufferManager;
+        _secretManager = secretManager;
         _bufferOptions = bufferOptions.Value;
         _k8sOptions = k8sOptions.Value;
         _logger = logger;
@@ -46,7 +51,7 @@ public class RunCreator
     {
         // Phase 1: Validate newRun and create the leaf building blocks.
 
-        ClusterOptions targetCluster = GetTargetCluster(newRun);
+        ClusterOptions targetCluster = GetTargetCluster(newRun.Cluster);
 
         if (await GetCodespec(newRun.Job.Codespec, cancellationToken) is not JobCodespec jobCodespec)
         {
@@ -62,10 +67,17 @@ public class RunCreator
             }
         };
 
+        ValidateRetryPolicy(newRun.RetryPolicy, jobCodespec);
+        await _secretManager.ValidateSecretRefs(jobCodespec, cancellationToken);
+
+        var jobDatasets = await ResolveDatasets(jobCodespec, newRun.Job, cancellationToken);
+        newRun = newRun with { Job = newRun.Job with { Datasets = ToDatasetVersions(jobDatasets) } };
+
         var jobPodTemplateSpec = CreatePodTemplateSpec(jobCodespec, newRun.Job, targetCluster, "Never");
 
         V1PodTemplateSpec? workerPodTemplateSpec = null;
         WorkerCodespec? workerCodespec = null;
+        Dictionary<string, Dataset> workerDatasets = [];
         if (newRun.Worker != null)
         {
             workerCodespec = await GetCodespec(newRun.Worker.Codespec, cancellationToken) as WorkerCodespec;
@@ -74,13 +86,16 @@ public class RunCreator
                 throw new ArgumentException($"The codespec for the worker is required to be a worker codespec");
             }
 
+            workerDatasets = await ResolveDatasets(workerCodespec, newRun.Worker, cancellationToken);
             newRun = newRun with
             {
                 Worker = newRun.Worker with
                 {
-                    Codespec = workerCodespec.ToCodespecRef()
+                    Codespec = workerCodespec.ToCodespecRef(),
+                    Datasets = ToDatasetVersions(workerDatasets),
                 }
             };
+            await _secretManager.ValidateSecretRefs(workerCodespec, cancellationToken);
             workerPodTemplateSpec = CreatePodTemplateSpec(workerCodespec, newRun.Worker, targetCluster, "Always");
         }
 
@@ -123,15 +138,46 @@ public class RunCreator
                 Selector = new() { MatchLabels = jobLabels },
                 Template = jobPodTemplateSpec,
                 ActiveDeadlineSeconds = run.TimeoutSeconds,
-                BackoffLimit = 0,
+                PodFailurePolicy = CreatePodFailurePolicy(run.RetryPolicy),
             },
         };
 
+        if (run.RetryPolicy is { MaxAttempts: > 1 })
+        {
+            // The backoff limit of an indexed job is shared by all of its replicas,
+            // so each replica gets its own limit, and the job fails as soon as one replica has exhausted it.
+            job.Spec.BackoffLimitPerIndex = run.RetryPolicy.MaxAttempts - 1;
+            job.Spec.MaxFailedIndexes = 0;
+
+            // Clusters older than 1.29 ignore the per-index limit. Without an explicit backoff limit, they would allow
+            // Kubernetes' default of 6 retries, so the shared limit is set to the sum of the replicas' limits.
+            job.Spec.BackoffLimit = (run.RetryPolicy.MaxAttempts - 1) * run.Job.Replicas;
+
+            if (run.RetryPolicy.BackoffSeconds > 0)
+            {
+                AddRetryBackoffInitContainer(job, run.RetryPolicy.BackoffSeconds.Value);
+            }
+        }
+        else
+        {
+            job.Spec.BackoffLimit = 0;
+        }
+
         if (bufferMap != null)
         {
             await AddBufferProxySidecars(job, run, bufferMap, cancellationToken);
         }
 
+        if (jobDatasets.Count > 0 || workerDatasets.Count > 0)
+        {
+            var datasetsSecret = await CreateDatasetsSecret(run, jobDatasets.Values.Concat(workerDatasets.Values), commonLabels, cancellationToken);
+            AddDatasetMounts(job.Spec.Template.Spec, jobCodespec, jobDatasets, datasetsSecret);
+            if (workerPodTemplateSpec != null)
+            {
+                AddDatasetMounts(workerPodTemplateSpec.Spec, workerCodespec!, workerDatasets, datasetsSecret);
+            }
+        }
+
         if (newRun.Worker != null)
         {
             var workerLabels = commonLabels.Add(WorkerLabel, $"{run.Id}");
@@ -185,6 +231,304 @@ public class RunCreator
         return run;
     }
 
+    /// <summary>
+    /// Checks a codespec against the rules that apply when it is created and when a run uses it on the given cluster and node pool,
+    /// without creating anything. Unlike when creating a run, all the problems found are returned, not just the first.
+    /// </summary>
+    public async Task<IReadOnlyList<CodespecProblem>> ValidateCodespec(Codespec codespec, string? clusterName, string? nodePool, CancellationToken cancellationToken)
+    {
+        var problems = new List<CodespecProblem>();
+
+        var results = new List<ValidationResult>();
+        Validator.TryValidateObject(codespec, new ValidationContext(codespec), results, validateAllProperties: true);
+        foreach (var result in results)
+        {
+            var member = result.MemberNames.FirstOrDefault();
+            problems.Add(new(member is null ? "codespec" : JsonNamingPolicy.CamelCase.ConvertName(member), result.ErrorMessage ?? "The value is invalid."));
+        }
+
+        problems.AddRange(ValidateForKubernetes(codespec));
+
+        ClusterOptions targetCluster;
+        try
+        {
+            targetCluster = GetTargetCluster(clusterName);
+        }
+        catch (ValidationException e)
+        {
+            problems.Add(new("cluster", e.Message));
+            return problems;
+        }
+
+        // The secret and dataset checks assume well-formed references, so they only run once the codespec itself is valid.
+        if (results.Count == 0)
+        {
+            try
+            {
+                await _secretManager.ValidateSecretRefs(codespec, cancellationToken);
+            }
+            catch (ValidationException e)
+            {
+                problems.Add(new("env", e.Message));
+            }
+
+            try
+            {
+                await ResolveDatasets(codespec, new RunCodeTarget { Codespec = codespec }, cancellationToken);
+            }
+            catch (ValidationException e)
+            {
+                problems.Add(new("mounts", e.Message));
+            }
+        }
+
+        try
+        {
+            AddComputeResources(new V1PodTemplateSpec { Spec = new() { Containers = [new() { Name = "main" }] } }, codespec, new RunCodeTarget { Codespec = codespec, NodePool = nodePool }, targetCluster);
+        }
+        catch (ValidationException e)
+        {
+            problems.Add(new("nodePool", e.Message));
+            return problems;
+        }
+
+        if (string.IsNullOrEmpty(nodePool) && codespec.Resources?.Gpu is ResourceQuantity gpu && gpu.ToDecimal() != 0 &&
+            !targetCluster.UserNodePools.Any(np => DoesVmHaveSupportedGpu(np.VmSize)))
+        {
+            problems.Add(new("resources.gpu", string.Format(CultureInfo.InvariantCulture, "No nodepool in cluster '{0}' has GPUs to satisfy GPU request '{1}'.", targetCluster.Name, gpu)));
+        }
+
+        if (targetCluster.ApiHost)
+        {
+            problems.AddRange(await ValidateRequestsAgainstNodeCapacity(codespec, targetCluster, nodePool, cancellationToken));
+        }
+
+        return problems;
+    }
+
+    /// <summary>
+    /// Checks the values that are accepted in a codespec but that Kubernetes would reject when a run uses it.
+    /// </summary>
+    private static IEnumerable<CodespecProblem> ValidateForKubernetes(Codespec codespec)
+    {
+        if (codespec.MaxReplicas < 1)
+        {
+            yield return new("maxReplicas", "The maxReplicas must be at least 1.");
+        }
+
+        if (codespec is WorkerCodespec worker)
+        {
+            foreach (var (name, port) in worker.Endpoints?.OrderBy(e => e.Key, StringComparer.Ordinal) ?? Enumerable.Empty<KeyValuePair<string, int>>())
+            {
+                if (port is < 1 or > 65535)
+                {
+                    yield return new("endpoints." + name, string.Format(CultureInfo.InvariantCulture, "The port {0} must be between 1 and 65535.", port));
+                }
+            }
+        }
+
+        var resources = codespec.Resources;
+        foreach (var (field, quantity) in new[]
+        {
+            ("resources.requests.cpu", resources?.Requests?.Cpu),
+            ("resources.requests.memory", resources?.Requests?.Memory),
+            ("resources.limits.cpu", resources?.Limits?.Cpu),
+            ("resources.limits.memory", resources?.Limits?.Memory),
+            ("resources.gpu", resources?.Gpu),
+        })
+        {
+            if (quantity?.ToDecimal() < 0)
+            {
+                yield return new(field, string.Format(CultureInfo.InvariantCulture, "The quantity '{0}' cannot be negative.", quantity));
+            }
+        }
+
+        if (resources?.Requests?.Cpu is ResourceQuantity cpuRequest && resources.Limits?.Cpu is ResourceQuantity cpuLimit && cpuRequest.ToDecimal() > cpuLimit.ToDecimal())
+        {
+            yield return new("resources.requests.cpu", string.Format(CultureInfo.InvariantCulture, "The CPU request '{0}' is greater than the CPU limit '{1}'.", cpuRequest, cpuLimit));
+        }
+
+        if (resources?.Requests?.Memory is ResourceQuantity memoryRequest && resources.Limits?.Memory is ResourceQuantity memoryLimit && memoryRequest.ToDecimal() > memoryLimit.ToDecimal())
+        {
+            yield return new("resources.requests.memory", string.Format(CultureInfo.InvariantCulture, "The memory request '{0}' is greater than the memory limit '{1}'.", memoryRequest, memoryLimit));
+        }
+
+        if (resources?.Gpu is ResourceQuantity gpu && decimal.Truncate(gpu.ToDecimal()) != gpu.ToDecimal())
+        {
+            yield return new("resources.gpu", string.Format(CultureInfo.InvariantCulture, "The GPU quantity '{0}' must be a whole number.", gpu));
+        }
+    }
+
+    /// <summary>
+    /// Checks that the CPU, memory, and GPUs that a codespec requests fit on at least one of the nodes that its runs can be
+    /// scheduled on. Only the nodes that currently exist are considered, so nothing is checked if one of the nodepools is scaled to zero.
+    /// </summary>
+    private async Task<IEnumerable<CodespecProblem>> ValidateRequestsAgainstNodeCapacity(Codespec codespec, ClusterOptions targetCluster, string? nodePool, CancellationToken cancellationToken)
+    {
+        // A container that only has a limit gets a request equal to that limit.
+        var requests = new List<(string field, string name, string resource, ResourceQuantity quantity)>();
+        var cpuRequest = codespec.Resources?.Requests?.Cpu;
+        if ((cpuRequest ?? codespec.Resources?.Limits?.Cpu) is ResourceQuantity cpu)
+        {
+            requests.Add((cpuRequest is null ? "resources.limits.cpu" : "resources.requests.cpu", "CPU", "cpu", cpu));
+        }
+
+        var memoryRequest = codespec.Resources?.Requests?.Memory;
+        if ((memoryRequest ?? codespec.Resources?.Limits?.Memory) is ResourceQuantity memory)
+        {
+            requests.Add((memoryRequest is null ? "resources.limits.memory" : "resources.requests.memory", "memory", "memory", memory));
+        }
+
+        if (codespec.Resources?.Gpu is ResourceQuantity gpu && gpu.ToDecimal() != 0)
+        {
+            requests.Add(("resources.gpu", "GPU", "nvidia.com/gpu", gpu));
+        }
+
+        if (requests.Count == 0)
+        {
+            return [];
+        }
+
+        // Runs can land on any of these nodepools. The nodes of a nodepool all have the same size, so one node is enough
+        // to know its capacity, but a nodepool without nodes cannot be checked.
+        bool requestsGpu = requests.Any(r => r.resource == "nvidia.com/gpu");
+        List<string> candidatePools = string.IsNullOrEmpty(nodePool)
+            ? targetCluster.UserNodePools.Where(np => !requestsGpu || DoesVmHaveSupportedGpu(np.VmSize)).Select(np => np.Name).ToList()
+            : [nodePool];
+
+        V1NodeList nodeList;
+        try
+        {
+            nodeList = await _client.CoreV1.ListNodeAsync(labelSelector: "tyger=run", cancellationToken: cancellationToken);
+        }
+        catch (HttpOperationException e) when (e.Response.StatusCode == HttpStatusCode.Forbidden)
+        {
+            _logger.UnableToListNodes(e.Response.StatusCode);
+            return [];
+        }
+
+        var nodes = nodeList.Items.Where(node => candidatePools.Contains(node.GetLabel("agentpool") ?? "", StringComparer.OrdinalIgnoreCase)).ToList();
+        if (candidatePools.Count == 0 || candidatePools.Any(pool => !nodes.Any(node => string.Equals(node.GetLabel("agentpool"), pool, StringComparison.OrdinalIgnoreCase))))
+        {
+            return [];
+        }
+
+        decimal Allocatable(V1Node node, string resource) =>
+            node.Status?.Allocatable?.TryGetValue(resource, out var quantity) == true ? quantity.ToDecimal() : 0;
+
+        if (nodes.Any(node => requests.All(r => r.quantity.ToDecimal() <= Allocatable(node, r.resource))))
+        {
+            return [];
+        }
+
+        var scope = string.IsNullOrEmpty(nodePool) ? "that runs can be scheduled on" : string.Format(CultureInfo.InvariantCulture, "in nodepool '{0}'", nodePool);
+        var problems = new List<CodespecProblem>();
+        foreach (var (field, name, resource, quantity) in requests)
+        {
+            var max = nodes.Max(node => Allocatable(node, resource));
+            if (quantity.ToDecimal() > max)
+            {
+                problems.Add(new(field, string.Format(CultureInfo.InvariantCulture, "The {0} request '{1}' is greater than the allocatable {0} of every node {2}. The most any node has is '{3}'.", name, quantity, scope, new ResourceQuantity(max.ToString(CultureInfo.InvariantCulture)))));
+            }
+        }
+
+        if (problems.Count == 0)
+        {
+            problems.Add(new("resources", string.Format(CultureInfo.InvariantCulture, "No single node {0} has enough allocatable CPU, memory, and GPUs for all the requests at once.", scope)));
+        }
+
+        return problems;
+    }
+
+    private static void ValidateRetryPolicy(RunRetryPolicy? retryPolicy, JobCodespec jobCodespec)
+    {
+        if (retryPolicy == null)
+        {
+            return;
+        }
+
+        if (retryPolicy.MaxAttempts is < 1 or > 10)
+        {
+            throw new ValidationException("The maxAttempts of the retry policy must be between 1 and 10.");
+        }
+
+        foreach (var reason in retryPolicy.GetRetryableReasons())
+        {
+            if (reason is not (RunRetryPolicy.PreemptedReason or RunRetryPolicy.ErrorReason))
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "Unknown retryable reason '{0}'. Valid options are: '{1}', '{2}'.", reason, RunRetryPolicy.PreemptedReason, RunRetryPolicy.ErrorReason));
+            }
+        }
+
+        if (retryPolicy.BackoffSeconds is < 0 or > RunRetryPolicy.MaxBackoffSeconds)
+        {
+            throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The backoffSeconds of the retry policy must be between 0 and {0}.", RunRetryPolicy.MaxBackoffSeconds));
+        }
+
+        if (retryPolicy.MaxAttempts > 1 && jobCodespec.Buffers?.Outputs is { Length: > 0 })
+        {
+            throw new ValidationException("A run cannot be retried if its job has output buffers, since a buffer cannot be overwritten once it has been written to.");
+        }
+    }
+
+    // Failed pods count towards the job's backoff limit only if the failure is retryable. Otherwise the job fails immediately.
+    private static V1PodFailurePolicy? CreatePodFailurePolicy(RunRetryPolicy? retryPolicy)
+    {
+        if (retryPolicy is not { MaxAttempts: > 1 })
+        {
+            return null;
+        }
+
+        var reasons = retryPolicy.GetRetryableReasons();
+        var rules = new List<V1PodFailurePolicyRule>
+        {
+            new()
+            {
+                Action = reasons.Contains(RunRetryPolicy.PreemptedReason) ? "Count" : "FailJob",
+                OnPodConditions = [new() { Type = "DisruptionTarget", Status = "True" }],
+            }
+        };
+
+        if (!reasons.Contains(RunRetryPolicy.ErrorReason))
+        {
+            rules.Add(new()
+            {
+                Action = "FailJob",
+                OnExitCodes = new() { OperatorProperty = "NotIn", Values = [0] },
+            });
+        }
+
+        return new V1PodFailurePolicy { Rules = rules };
+    }
+
+    /// <summary>
+    /// Adds an init container that runs first and waits before a replica is attempted again. The wait starts at the backoff
+    /// of the retry policy and doubles with each failed attempt of the replica, up to the maximum backoff. The number of failed
+    /// attempts comes from an annotation that Kubernetes only sets from 1.29, so older clusters do not wait.
+    /// </summary>
+    private void AddRetryBackoffInitContainer(V1Job job, int backoffSeconds)
+    {
+        var script = new StringBuilder("set -euo pipefail").AppendLine();
+        script.AppendLine("failures=\"${TYGER_FAILED_ATTEMPTS:-0}\"");
+        script.AppendLine("if [ \"$failures\" -gt 0 ]; then");
+        script.AppendLine($"  delay=$(( {backoffSeconds} << (failures - 1) ))");
+        script.AppendLine($"  if [ \"$delay\" -gt {RunRetryPolicy.MaxBackoffSeconds} ]; then delay={RunRetryPolicy.MaxBackoffSeconds}; fi");
+        script.AppendLine("  echo \"waiting $delay seconds before attempt $((failures + 1))\"");
+        script.AppendLine("  sleep \"$delay\"");
+        script.AppendLine("fi");
+
+        (job.Spec.Template.Spec.InitContainers ??= []).Insert(0, new()
+        {
+            Name = "retrybackoff",
+            Image = _k8sOptions.WorkerWaiterImage,
+            Command = ["bash", "-c", script.ToString()],
+            Env =
+            [
+                new V1EnvVar("TYGER_FAILED_ATTEMPTS", valueFrom: new V1EnvVarSource(fieldRef: new V1ObjectFieldSelector("metadata.annotations['batch.kubernetes.io/job-index-failure-count']"))),
+            ],
+        });
+    }
+
     private void AddWaitForWorkerInitContainersToJob(V1Job job, Run run)
     {
         var initContainers = job.Spec.Template.Spec.InitContainers ??= [];
@@ -337,18 +681,157 @@ public class RunCreator
         _logger.CreatedSecret(buffersSecret.Metadata.Name);
     }
 
+    /// <summary>
+    /// Determines the version of each dataset that the codespec mounts. A version given by the run takes precedence
+    /// over the version in the codespec, and the latest version is used if neither gives one.
+    /// </summary>
+    private async Task<Dictionary<string, Dataset>> ResolveDatasets(Codespec codespec, RunCodeTarget codeTarget, CancellationToken cancellationToken)
+    {
+        if (codespec.ValidateMounts().FirstOrDefault() is ValidationResult invalidMount)
+        {
+            throw new ValidationException(invalidMount.ErrorMessage);
+        }
+
+        var datasets = new Dictionary<string, Dataset>(StringComparer.Ordinal);
+        foreach (var mount in codespec.Mounts ?? [])
+        {
+            int? version = codeTarget.Datasets?.TryGetValue(mount.Dataset, out var runVersion) == true ? runVersion : mount.Version;
+            datasets[mount.Dataset] = await _repository.GetDataset(mount.Dataset, version, cancellationToken)
+                ?? throw new ValidationException(version is null
+                    ? string.Format(CultureInfo.InvariantCulture, "The dataset '{0}' was not found", mount.Dataset)
+                    : string.Format(CultureInfo.InvariantCulture, "The version '{0}' of dataset '{1}' was not found", version, mount.Dataset));
+        }
+
+        foreach (var name in codeTarget.Datasets?.Keys ?? Enumerable.Empty<string>())
+        {
+            if (!datasets.ContainsKey(name))
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The dataset '{0}' is not mounted by the codespec", name));
+            }
+        }
+
+        return datasets;
+    }
+
+    private static Dictionary<string, int>? ToDatasetVersions(Dictionary<string, Dataset> datasets) =>
+        datasets.Count == 0 ? null : datasets.ToDictionary(p => p.Key, p => p.Value.Version);
+
+    private async Task<V1Secret> CreateDatasetsSecret(Run run, IEnumerable<Dataset> datasets, IDictionary<string, string> labels, CancellationToken cancellationToken)
+    {
+        var sasUris = new Dictionary<string, string>();
+        foreach (var dataset in datasets)
+        {
+            if (!sasUris.ContainsKey(dataset.BufferId))
+            {
+                var bufferAccess = await _bufferManager.CreateBufferAccessString(dataset.BufferId, false, cancellationToken)
+                    ?? throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The buffer of version '{0}' of dataset '{1}' was not found", dataset.Version, dataset.Name));
+                sasUris[dataset.BufferId] = bufferAccess.Uri.ToString();
+            }
+        }
+
+        var datasetsSecret = new V1Secret
+        {
+            Metadata = new()
+            {
+                Name = DatasetSecretNameFromRunId(run.Id!.Value),
+                Labels = labels,
+            },
+            StringData = sasUris,
+        };
+
+        datasetsSecret = await _client.CoreV1.CreateNamespacedSecretAsync(datasetsSecret, _k8sOptions.Namespace, cancellationToken: cancellationToken);
+        _logger.CreatedSecret(datasetsSecret.Metadata.Name);
+        return datasetsSecret;
+    }
+
+    /// <summary>
+    /// Adds an init container for each mounted dataset that downloads and extracts the dataset version into the node's
+    /// dataset cache, unless it is already there. The cached directory is then mounted read-only into the main container.
+    /// </summary>
+    private void AddDatasetMounts(V1PodSpec podSpec, Codespec codespec, Dictionary<string, Dataset> datasets, V1Secret datasetsSecret)
+    {
+        const string SecretMountPath = "/etc/dataset-sas-tokens";
+        const string CacheMountPath = "/datasets";
+        const string SecretVolumeName = "dataset-sas-tokens";
+        const string CacheVolumeName = "dataset-cache";
+
+        podSpec.Volumes ??= [];
+        podSpec.Volumes.Add(new() { Name = SecretVolumeName, Secret = new() { SecretName = datasetsSecret.Metadata.Name } });
+        podSpec.Volumes.Add(new() { Name = CacheVolumeName, HostPath = new() { Path = _k8sOptions.DatasetCacheHostPath, Type = "DirectoryOrCreate" } });
+
+        var mainContainer = GetMainContainer(podSpec);
+        mainContainer.VolumeMounts ??= [];
+        podSpec.InitContainers ??= [];
+
+        foreach ((var mount, var i) in codespec.Mounts!.Select((m, i) => (m, i)))
+        {
+            var dataset = datasets[mount.Dataset];
+            var entry = $"{dataset.Name}/{dataset.Version}";
+
+            // The fetch leases the cache entry for the pod, so that the dataset cache DaemonSet
+            // does not evict it while the pod is on the node.
+            podSpec.InitContainers.Add(new()
+            {
+                Name = $"dataset-{i}",
+                Image = _bufferOptions.BufferSidecarImage,
+                Args = new[]
+                {
+                    "fetch-dataset",
+                    $"{SecretMountPath}/{dataset.BufferId}",
+                    "--cache-dir", CacheMountPath,
+                    "--entry", entry,
+                    "--pod-uid", "$(POD_UID)",
+                    "--log-format", "json",
+                },
+                Env = new[]
+                {
+                    new V1EnvVar("POD_UID", valueFrom: new V1EnvVarSource(fieldRef: new V1ObjectFieldSelector("metadata.uid"))),
+                },
+                VolumeMounts = new V1VolumeMount[]
+                {
+                    new(CacheMountPath, CacheVolumeName),
+                    new(SecretMountPath, SecretVolumeName, readOnlyProperty: true),
+                },
+
+                // The cache directory on the node is owned by root
+                SecurityContext = new() { RunAsUser = 0, RunAsNonRoot = false },
+            });
+
+            mainContainer.VolumeMounts.Add(new(mount.Path, CacheVolumeName, readOnlyProperty: true, subPath: entry));
+        }
+    }
+
+    private static V1EnvVar CreateEnvVar(string name, EnvValue value)
+    {
+        if (value.SecretRef is null)
+        {
+            return new V1EnvVar(name, value.Value);
+        }
+
+        // The value is read from the secret by the kubelet, so it is never part of the pod spec.
+        (var secretName, var key) = value.ParseSecretRef();
+        return new V1EnvVar
+        {
+            Name = name,
+            ValueFrom = new V1EnvVarSource
+            {
+                SecretKeyRef = new V1SecretKeySelector { Name = KubernetesSecretNameFromSecretName(secretName), Key = key },
+            },
+        };
+    }
+
     private static V1Container GetMainContainer(V1PodSpec podSpec) => podSpec.Containers.Single(c => c.Name == "main");
 
-    private ClusterOptions GetTargetCluster(Run newRun)
+    private ClusterOptions GetTargetCluster(string? clusterName)
     {
         ClusterOptions? targetCluster;
-        if (!string.IsNullOrEmpty(newRun.Cluster))
+        if (!string.IsNullOrEmpty(clusterName))
         {
-            targetCluster = _k8sOptions.Clusters.FirstOrDefault(c => string.Equals(c.Name, newRun.Cluster, StringComparison.OrdinalIgnoreCase));
+            targetCluster = _k8sOptions.Clusters.FirstOrDefault(c => string.Equals(c.Name, clusterName, StringComparison.OrdinalIgnoreCase));
             if (targetCluster == null)
             {
                 var options = string.Join(", ", _k8sOptions.Clusters.Select(c => $"'{c.Name}'"));
-                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "Unknown cluster '{0}'. Valid options are: {1}.", newRun.Cluster, options));
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "Unknown cluster '{0}'. Valid options are: {1}.", clusterName, options));
             }
         }
         else
@@ -378,7 +861,7 @@ public class RunCreator
                         Image = codespec.Image,
                         Command = codespec.Command,
                         Args = codespec.Args,
-                        Env = codespec.Env?.Select(p => new V1EnvVar(p.Key, p.Value)).ToList()
+                        Env = codespec.Env?.Select(p => CreateEnvVar(p.Key, p.Value)).ToList()
                     }
                 ],
                 RestartPolicy = restartPolicy,
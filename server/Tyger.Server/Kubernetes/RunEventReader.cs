@@ -0,0 +1,192 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+using System.Runtime.CompilerServices;
+using k8s;
+using k8s.Models;
+using Microsoft.Extensions.Options;
+using Tyger.Server.Model;
+using static Tyger.Server.Kubernetes.KubernetesMetadata;
+
+namespace Tyger.Server.Kubernetes;
+
+/// <summary>
+/// Builds a timeline of the events of a run from the state of its pods and the Kubernetes events about them.
+/// Events about pods are only available until the run is finalized and its pods are deleted.
+/// </summary>
+public class RunEventReader
+{
+    private const string BufferSidecarSuffix = "-buffer-sidecar";
+    private static readonly TimeSpan s_pollInterval = TimeSpan.FromSeconds(2);
+
+    private readonly IKubernetes _client;
+    private readonly RunReader _runReader;
+    private readonly KubernetesApiOptions _k8sOptions;
+
+    public RunEventReader(
+        IKubernetes client,
+        RunReader runReader,
+        IOptions<KubernetesApiOptions> k8sOptions)
+    {
+        _client = client;
+        _runReader = runReader;
+        _k8sOptions = k8sOptions.Value;
+    }
+
+    public async Task<IReadOnlyList<RunEvent>?> GetEvents(long runId, CancellationToken cancellationToken)
+    {
+        if (await _runReader.GetRun(runId, cancellationToken) is not Run run)
+        {
+            return null;
+        }
+
+        return await GetEvents(run, cancellationToken);
+    }
+
+    /// <summary>
+    /// Returns the events of a run as they happen until the run completes. Events that have already happened are returned first.
+    /// </summary>
+    public async IAsyncEnumerable<RunEvent> WatchEvents(long runId, [EnumeratorCancellation] CancellationToken cancellationToken)
+    {
+        var returnedEvents = new HashSet<RunEvent>();
+        while (true)
+        {
+            if (await _runReader.GetRun(runId, cancellationToken) is not Run run)
+            {
+                yield break;
+            }
+
+            foreach (var runEvent in await GetEvents(run, cancellationToken))
+            {
+                if (returnedEvents.Add(runEvent))
+                {
+                    yield return runEvent;
+                }
+            }
+
+            if (run.Status is RunStatus.Succeeded or RunStatus.Failed or RunStatus.Canceled)
+            {
+                yield break;
+            }
+
+            await Task.Delay(s_pollInterval, cancellationToken);
+        }
+    }
+
+    private async Task<IReadOnlyList<RunEvent>> GetEvents(Run run, CancellationToken cancellationToken)
+    {
+        var events = new List<RunEvent>();
+        if (run.CreatedAt.HasValue)
+        {
+            events.Add(new RunEvent { Type = RunEvent.CreatedType, Timestamp = run.CreatedAt.Value, Message = "The run was created" });
+        }
+
+        var pods = await _client.EnumeratePodsInNamespace(_k8sOptions.Namespace, labelSelector: $"{RunLabel}={run.Id}", cancellationToken: cancellationToken)
+            .ToListAsync(cancellationToken);
+
+        foreach (var pod in pods)
+        {
+            (var target, var replica) = GetReplica(pod);
+            var replicaEvent = new RunEvent { Target = target, Replica = replica };
+
+            foreach (var containerStatus in pod.Status?.ContainerStatuses ?? [])
+            {
+                events.AddRange(GetContainerEvents(replicaEvent, containerStatus));
+            }
+
+            var podEvents = await _client.CoreV1.ListNamespacedEventAsync(_k8sOptions.Namespace, fieldSelector: $"involvedObject.kind=Pod,involvedObject.name={pod.Name()}", cancellationToken: cancellationToken);
+            foreach (var podEvent in podEvents.Items)
+            {
+                if (GetEventType(podEvent) is string type)
+                {
+                    events.Add(replicaEvent with
+                    {
+                        Type = type,
+                        Timestamp = podEvent.LastTimestamp ?? podEvent.EventTime ?? podEvent.FirstTimestamp ?? podEvent.Metadata.CreationTimestamp ?? DateTime.UtcNow,
+                        Message = podEvent.Message,
+                    });
+                }
+            }
+        }
+
+        if (run.Status is RunStatus.Succeeded or RunStatus.Failed or RunStatus.Canceled)
+        {
+            var message = $"The run finished with status {run.Status}";
+            events.Add(new RunEvent
+            {
+                Type = RunEvent.FinishedType,
+                Timestamp = run.FinishedAt ?? DateTimeOffset.UtcNow,
+                Message = string.IsNullOrEmpty(run.StatusReason) ? message : $"{message}: {run.StatusReason}",
+            });
+        }
+
+        return events.OrderBy(e => e.Timestamp).ToList();
+    }
+
+    private static IEnumerable<RunEvent> GetContainerEvents(RunEvent replicaEvent, V1ContainerStatus containerStatus)
+    {
+        var startedAt = containerStatus.State?.Running?.StartedAt ?? containerStatus.State?.Terminated?.StartedAt;
+        var terminated = containerStatus.State?.Terminated;
+
+        if (containerStatus.Name == "main")
+        {
+            if (startedAt.HasValue)
+            {
+                yield return replicaEvent with { Type = RunEvent.ReplicaStartedType, Timestamp = startedAt.Value, Message = "The main container started" };
+            }
+
+            if (terminated?.FinishedAt != null)
+            {
+                yield return replicaEvent with
+                {
+                    Type = terminated.Reason == "OOMKilled" ? RunEvent.OomKilledType : RunEvent.ReplicaExitedType,
+                    Timestamp = terminated.FinishedAt.Value,
+                    ExitCode = terminated.ExitCode,
+                    Message = string.IsNullOrEmpty(terminated.Reason)
+                        ? $"The main container exited with code {terminated.ExitCode}"
+                        : $"The main container exited with code {terminated.ExitCode} ({terminated.Reason})",
+                };
+            }
+        }
+        else if (containerStatus.Name.EndsWith(BufferSidecarSuffix, StringComparison.Ordinal))
+        {
+            var buffer = containerStatus.Name[..^BufferSidecarSuffix.Length];
+            if (startedAt.HasValue)
+            {
+                yield return replicaEvent with { Type = RunEvent.BufferOpenedType, Timestamp = startedAt.Value, Buffer = buffer, Message = $"The buffer '{buffer}' was opened" };
+            }
+
+            if (terminated?.FinishedAt != null)
+            {
+                yield return replicaEvent with
+                {
+                    Type = RunEvent.BufferClosedType,
+                    Timestamp = terminated.FinishedAt.Value,
+                    Buffer = buffer,
+                    ExitCode = terminated.ExitCode,
+                    Message = terminated.ExitCode == 0 ? $"The buffer '{buffer}' was closed" : $"The buffer '{buffer}' was closed with an error (exit code {terminated.ExitCode})",
+                };
+            }
+        }
+    }
+
+    private static string? GetEventType(Corev1Event podEvent)
+    {
+        switch (podEvent.Reason)
+        {
+            case "Scheduled":
+                return RunEvent.ScheduledType;
+            case "FailedScheduling":
+                return RunEvent.SchedulingFailedType;
+            case "Pulling":
+                return RunEvent.ImagePullingType;
+            case "Pulled":
+                return RunEvent.ImagePulledType;
+            case "Failed" or "BackOff" or "ErrImagePull" when podEvent.Message?.Contains("image", StringComparison.OrdinalIgnoreCase) == true:
+                return RunEvent.ImagePullFailedType;
+        }
+
+        // Other events, like container restarts or failed volume mounts, can also explain why a run is not progressing
+        return podEvent.Type == "Warning" ? RunEvent.WarningType : null;
+    }
+}
@@ -0,0 +1,135 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+using System.ComponentModel.DataAnnotations;
+using System.Net.WebSockets;
+using k8s;
+using k8s.Models;
+using Microsoft.Extensions.Options;
+using Tyger.Server.Database;
+using Tyger.Server.Model;
+using static Tyger.Server.Kubernetes.KubernetesMetadata;
+
+namespace Tyger.Server.Kubernetes;
+
+/// <summary>
+/// Executes commands in the main container of a running job replica. The client's WebSocket
+/// is relayed to a Kubernetes exec WebSocket, so messages use the Kubernetes
+/// v4.channel.k8s.io framing: the first byte of each message is the channel
+/// (0 stdin, 1 stdout, 2 stderr, 3 status, 4 terminal resize) and the rest is the data.
+/// </summary>
+public class RunExecutor
+{
+    private readonly IKubernetes _client;
+    private readonly IRepository _repository;
+    private readonly KubernetesApiOptions _k8sOptions;
+    private readonly ILogger<RunExecutor> _logger;
+
+    public RunExecutor(
+        IKubernetes client,
+        IRepository repository,
+        IOptions<KubernetesApiOptions> k8sOptions,
+        ILogger<RunExecutor> logger)
+    {
+        _client = client;
+        _repository = repository;
+        _k8sOptions = k8sOptions.Value;
+        _logger = logger;
+    }
+
+    /// <summary>
+    /// Returns the pod of the given job replica of a run, or null if the run does not exist.
+    /// Throws a ValidationException if the run has completed or the replica is not running.
+    /// </summary>
+    public async Task<V1Pod?> GetReplicaPod(long runId, int replica, CancellationToken cancellationToken)
+    {
+        if (await _repository.GetRun(runId, cancellationToken) is not (Run run, bool final, _))
+        {
+            return null;
+        }
+
+        if (final)
+        {
+            throw new ValidationException($"Run {runId} has completed.");
+        }
+
+        if (replica < 0 || replica >= run.Job.Replicas)
+        {
+            throw new ValidationException($"The replica must be between 0 and {run.Job.Replicas - 1}.");
+        }
+
+        var pods = await _client.EnumeratePodsInNamespace(_k8sOptions.Namespace, labelSelector: $"{RunLabel}={runId},{JobLabel}", cancellationToken: cancellationToken)
+            .ToListAsync(cancellationToken);
+
+        // There can be more than one pod for a replica when it has been retried
+        return pods.FirstOrDefault(p =>
+            GetReplica(p).replica == replica &&
+            p.Status?.ContainerStatuses?.SingleOrDefault(c => c.Name == "main")?.State?.Running != null)
+            ?? throw new ValidationException($"Replica {replica} of run {runId} is not running.");
+    }
+
+    /// <summary>
+    /// Executes a command in the main container of the pod and relays messages between the client's
+    /// WebSocket and the command until the command exits or the client disconnects.
+    /// </summary>
+    public async Task Exec(V1Pod pod, IReadOnlyList<string> command, bool tty, WebSocket clientSocket, CancellationToken cancellationToken)
+    {
+        _logger.ExecutingCommandInPod(pod.Name(), command.Count > 0 ? command[0] : "");
+
+        using var podSocket = await _client.WebSocketNamespacedPodExecAsync(
+            pod.Name(),
+            _k8sOptions.Namespace,
+            command,
+            "main",
+            stderr: !tty,
+            stdin: true,
+            stdout: true,
+            tty: tty,
+            WebSocketProtocol.V4BinaryWebsocketProtocol,
+            cancellationToken: cancellationToken);
+
+        using var cts = CancellationTokenSource.CreateLinkedTokenSource(cancellationToken);
+        var clientToPod = Relay(clientSocket, podSocket, cts.Token);
+
+        try
+        {
+            // The pod closes the socket when the command exits
+            await Relay(podSocket, clientSocket, cts.Token);
+        }
+        finally
+        {
+            cts.Cancel();
+            try
+            {
+                await clientToPod;
+            }
+            catch (OperationCanceledException)
+            {
+            }
+            catch (WebSocketException)
+            {
+                // The client went away
+            }
+        }
+    }
+
+    private static async Task Relay(WebSocket source, WebSocket destination, CancellationToken cancellationToken)
+    {
+        var buffer = new byte[16 * 1024];
+        while (true)
+        {
+            var result = await source.ReceiveAsync(new ArraySegment<byte>(buffer), cancellationToken);
+            if (result.MessageType == WebSocketMessageType.Close)
+            {
+                if (destination.State is WebSocketState.Open or WebSocketState.CloseReceived)
+                {
+                    await destination.CloseOutputAsync(result.CloseStatus ?? WebSocketCloseStatus.NormalClosure, result.CloseStatusDescription, cancellationToken);
+                }
+
+                return;
+            }
+
+            await destination.SendAsync(buffer.AsMemory(0, result.Count), result.MessageType, result.EndOfMessage, cancellationToken);
+        }
+    }
+}
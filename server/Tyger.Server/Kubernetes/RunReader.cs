@@ -37,9 +37,9 @@ public class RunReader
         _logger = logger;
     }
 
-    public async Task<(IReadOnlyList<Run>, string? nextContinuationToken)> ListRuns(int limit, DateTimeOffset? since, string? continuationToken, CancellationToken cancellationToken)
+    public async Task<(IReadOnlyList<Run>, string? nextContinuationToken)> ListRuns(int limit, RunFilter filter, string? continuationToken, CancellationToken cancellationToken)
     {
-        (var partialRuns, var nextContinuationToken) = await _repository.GetRuns(limit, since, continuationToken, cancellationToken);
+        (var partialRuns, var nextContinuationToken) = await _repository.GetRuns(limit, filter, continuationToken, cancellationToken);
         if (partialRuns.All(r => r.final))
         {
             return (partialRuns.Select(r => r.run).ToList(), nextContinuationToken);
@@ -69,7 +69,8 @@ public class RunReader
             }
         }
 
-        return (partialRuns.Where(p => p.final).Select(p => p.run).ToList(), nextContinuationToken);
+        // Runs that were not final could not be filtered on their status by the database
+        return (partialRuns.Where(p => p.final && filter.MatchesStatus(p.run)).Select(p => p.run).ToList(), nextContinuationToken);
     }
 
     public async Task<Run?> GetRun(long id, CancellationToken cancellationToken)
@@ -232,6 +233,23 @@ public class RunReader
         return index;
     }
 
+    private static string GetPodFailureReason(V1Pod pod)
+    {
+        var disruptionCondition = pod.Status.Conditions?.FirstOrDefault(c => c.Type == "DisruptionTarget" && c.Status == "True");
+        if (disruptionCondition != null)
+        {
+            return $"{RunRetryPolicy.PreemptedReason} ({disruptionCondition.Reason})";
+        }
+
+        var terminated = pod.Status.ContainerStatuses?.SingleOrDefault(c => c.Name == "main")?.State.Terminated;
+        if (terminated is { ExitCode: not 0 })
+        {
+            return string.Format(CultureInfo.InvariantCulture, "{0} (exit code {1})", terminated.Reason ?? RunRetryPolicy.ErrorReason, terminated.ExitCode);
+        }
+
+        return pod.Status.Reason ?? RunRetryPolicy.ErrorReason;
+    }
+
     internal static bool HasJobSucceeded(V1Job job)
     {
         return job.Status.Conditions?.Any(c => c.Type == "Complete" && c.Status == "True") == true;
@@ -287,6 +305,7 @@ public class RunReader
         }
 
         run = UpdateStatus(run, job, jobPods, workerPods);
+        run = UpdateAttempts(run, jobPods);
         return UpdateNodePools(run, job, jobPods, workerPods);
 
         static Run UpdateStatus(Run run, V1Job job, IReadOnlyCollection<V1Pod> jobPods, IReadOnlyCollection<V1Pod> workerPods)
@@ -322,6 +341,7 @@ public class RunReader
                 return run with
                 {
                     Status = RunStatus.Succeeded,
+                    StatusReason = run.RetryPolicy == null ? run.StatusReason : null,
                     FinishedAt = finishedTimes.Count == 0 ? null : finishedTimes.Min(),
                     RunningCount = null
                 };
@@ -348,6 +368,7 @@ public class RunReader
                 return run with
                 {
                     Status = RunStatus.Running,
+                    StatusReason = GetRetryStatusReason(run, jobPods),
                     RunningCount = runningCount
                 };
             }
@@ -355,6 +376,59 @@ public class RunReader
             return run with { Status = RunStatus.Pending };
         }
 
+        static string? GetRetryStatusReason(Run run, IReadOnlyCollection<V1Pod> jobPods)
+        {
+            if (run.RetryPolicy == null)
+            {
+                return run.StatusReason;
+            }
+
+            var failedPods = jobPods.Where(p => p.Status.Phase == "Failed").ToList();
+            if (failedPods.Count == 0)
+            {
+                return null;
+            }
+
+            var lastFailedPod = failedPods.MaxBy(p => p.Metadata.CreationTimestamp)!;
+            return string.Format(
+                CultureInfo.InvariantCulture,
+                "Retrying after {0} failed attempt(s). The last attempt failed with: {1}. Input buffers are read again from the beginning, which is possible because buffers are write-once and can be re-read.",
+                failedPods.Count,
+                GetPodFailureReason(lastFailedPod));
+        }
+
+        static Run UpdateAttempts(Run run, IReadOnlyCollection<V1Pod> jobPods)
+        {
+            if (run.RetryPolicy == null || jobPods.Count == 0)
+            {
+                return run;
+            }
+
+            var attempts = jobPods
+                .GroupBy(GetJobCompletionIndex)
+                .OrderBy(g => g.Key)
+                .SelectMany(g => g
+                    .OrderBy(p => p.Metadata.CreationTimestamp)
+                    .Select((p, i) => new RunAttempt
+                    {
+                        Replica = g.Key,
+                        Attempt = i + 1,
+                        Status = p.Status.Phase switch
+                        {
+                            "Running" => RunStatus.Running,
+                            "Succeeded" => RunStatus.Succeeded,
+                            "Failed" => RunStatus.Failed,
+                            _ => RunStatus.Pending,
+                        },
+                        StatusReason = p.Status.Phase == "Failed" ? GetPodFailureReason(p) : null,
+                        StartedAt = p.Status.StartTime,
+                        FinishedAt = p.Status.ContainerStatuses?.SingleOrDefault(c => c.Name == "main")?.State.Terminated?.FinishedAt,
+                    }))
+                .ToList();
+
+            return run with { Attempts = attempts };
+        }
+
         static Run UpdateNodePools(Run run, V1Job job, IReadOnlyCollection<V1Pod> jobPods, IReadOnlyCollection<V1Pod> workerPods)
         {
             static string GetNodePoolFromNodeName(string nodeName)
@@ -22,6 +22,7 @@ public sealed class RunSweeper : IHostedService, IDisposable
     private readonly IRepository _repository;
     private readonly ILogSource _logSource;
     private readonly ILogArchive _logArchive;
+    private readonly RunUsageMonitor _usageMonitor;
     private readonly KubernetesApiOptions _k8sOptions;
     private readonly ILogger<RunSweeper> _logger;
 
@@ -31,12 +32,14 @@ public sealed class RunSweeper : IHostedService, IDisposable
         IOptions<KubernetesApiOptions> k8sOptions,
         ILogSource logSource,
         ILogArchive logArchive,
+        RunUsageMonitor usageMonitor,
         ILogger<RunSweeper> logger)
     {
         _client = client;
         _repository = repository;
         _logSource = logSource;
         _logArchive = logArchive;
+        _usageMonitor = usageMonitor;
         _k8sOptions = k8sOptions.Value;
         _logger = logger;
     }
@@ -152,6 +155,8 @@ public sealed class RunSweeper : IHostedService, IDisposable
                                 };
                             }
 
+                            run = await _usageMonitor.CompleteUsage(run, pods, cancellationToken);
+
                             _logger.FinalizingTerminatedRun(run.Id!.Value, run.Status!.Value);
                             await _repository.UpdateRun(run, final: true, cancellationToken: cancellationToken);
                             await DeleteRunResources(run.Id!.Value, cancellationToken);
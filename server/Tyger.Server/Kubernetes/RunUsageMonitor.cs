@@ -0,0 +1,291 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+using System.Globalization;
+using k8s;
+using k8s.Autorest;
+using k8s.Models;
+using Microsoft.Extensions.Options;
+using Tyger.Server.Buffers;
+using Tyger.Server.Database;
+using Tyger.Server.Model;
+using static Tyger.Server.Kubernetes.KubernetesMetadata;
+
+namespace Tyger.Server.Kubernetes;
+
+/// <summary>
+/// Periodically measures the CPU and memory usage of the main containers of active runs using the metrics API,
+/// and completes the usage of a run with its wall time, GPU time, and buffer sizes when the run is finalized.
+/// </summary>
+public sealed class RunUsageMonitor : IHostedService, IDisposable
+{
+    private static readonly TimeSpan s_measurementInterval = TimeSpan.FromSeconds(15);
+
+    private Task? _backgroundTask;
+    private CancellationTokenSource? _backgroundCancellationTokenSource;
+    private readonly IKubernetes _client;
+    private readonly IRepository _repository;
+    private readonly BufferManager _bufferManager;
+    private readonly KubernetesApiOptions _k8sOptions;
+    private readonly ILogger<RunUsageMonitor> _logger;
+    private bool _metricsUnavailableLogged;
+
+    public RunUsageMonitor(
+        IKubernetes client,
+        IRepository repository,
+        BufferManager bufferManager,
+        IOptions<KubernetesApiOptions> k8sOptions,
+        ILogger<RunUsageMonitor> logger)
+    {
+        _client = client;
+        _repository = repository;
+        _bufferManager = bufferManager;
+        _k8sOptions = k8sOptions.Value;
+        _logger = logger;
+    }
+
+    public Task StartAsync(CancellationToken cancellationToken)
+    {
+        _backgroundCancellationTokenSource = new CancellationTokenSource();
+        _backgroundTask = BackgroundLoop(_backgroundCancellationTokenSource.Token);
+        return Task.CompletedTask;
+    }
+
+    public async Task StopAsync(CancellationToken cancellationToken)
+    {
+        if (_backgroundCancellationTokenSource == null || _backgroundTask == null)
+        {
+            return;
+        }
+
+        _backgroundCancellationTokenSource.Cancel();
+
+        // wait for the background task to complete, but give up once the cancellation token is canceled.
+        var tcs = new TaskCompletionSource();
+        cancellationToken.Register(s => ((TaskCompletionSource)s!).SetResult(), tcs);
+        await Task.WhenAny(_backgroundTask, tcs.Task);
+    }
+
+    private async Task BackgroundLoop(CancellationToken cancellationToken)
+    {
+        while (!cancellationToken.IsCancellationRequested)
+        {
+            try
+            {
+                await Task.Delay(s_measurementInterval, cancellationToken);
+                await MeasureUsage(cancellationToken);
+            }
+            catch (TaskCanceledException) when (cancellationToken.IsCancellationRequested)
+            {
+                return;
+            }
+            catch (Exception e)
+            {
+                _logger.ErrorMeasuringRunUsage(e);
+            }
+        }
+    }
+
+    public async Task MeasureUsage(CancellationToken cancellationToken)
+    {
+        PodMetricsList metrics;
+        try
+        {
+            metrics = await _client.CustomObjects.ListNamespacedCustomObjectAsync<PodMetricsList>(
+                "metrics.k8s.io", "v1beta1", _k8sOptions.Namespace, "pods", labelSelector: RunLabel, cancellationToken: cancellationToken);
+        }
+        catch (HttpOperationException e) when (e.Response.StatusCode is System.Net.HttpStatusCode.NotFound or System.Net.HttpStatusCode.Forbidden)
+        {
+            if (!_metricsUnavailableLogged)
+            {
+                _logger.MetricsApiUnavailable(e.Response.StatusCode);
+                _metricsUnavailableLogged = true;
+            }
+
+            return;
+        }
+
+        if (metrics.Items.Count == 0)
+        {
+            return;
+        }
+
+        var podsByName = await _client.EnumeratePodsInNamespace(_k8sOptions.Namespace, labelSelector: RunLabel, cancellationToken: cancellationToken)
+            .ToDictionaryAsync(p => p.Name(), cancellationToken);
+
+        var metricsByRun = metrics.Items
+            .Where(m => podsByName.ContainsKey(m.Metadata.Name))
+            .GroupBy(m => long.Parse(podsByName[m.Metadata.Name].GetLabel(RunLabel), CultureInfo.InvariantCulture));
+
+        foreach (var runMetrics in metricsByRun)
+        {
+            if (await _repository.GetRun(runMetrics.Key, cancellationToken) is not (Run run, false, _))
+            {
+                continue;
+            }
+
+            var replicas = run.Usage?.Replicas?.ToDictionary(r => (r.Target, r.Replica)) ?? [];
+            bool changed = false;
+            foreach (var podMetrics in runMetrics)
+            {
+                var key = GetReplica(podsByName[podMetrics.Metadata.Name]);
+                var replica = replicas.GetValueOrDefault(key) ?? new ReplicaUsage { Target = key.target, Replica = key.replica };
+
+                var measuredAt = new DateTimeOffset(podMetrics.Timestamp, TimeSpan.Zero);
+                if (replica.MeasuredAt >= measuredAt)
+                {
+                    // Already accounted for, possibly by another instance of the server
+                    continue;
+                }
+
+                // Only the main container is measured, so that the buffer sidecars are not counted as the
+                // usage of the code.
+                var main = podMetrics.Containers?.SingleOrDefault(c => c.Name == "main");
+                if (main == null)
+                {
+                    continue;
+                }
+
+                double cpuCores = main.Usage.TryGetValue("cpu", out var cpu) ? cpu.ToDouble() : 0;
+                long memoryBytes = main.Usage.TryGetValue("memory", out var memory) ? memory.ToInt64() : 0;
+
+                // The usage is the average over the measurement window, so the first measurement covers the window
+                // and later ones cover the time since the previous measurement.
+                var elapsed = replica.MeasuredAt.HasValue
+                    ? measuredAt - replica.MeasuredAt.Value
+                    : ParseWindow(podMetrics.Window);
+
+                replicas[key] = replica with
+                {
+                    CpuSeconds = (replica.CpuSeconds ?? 0) + (cpuCores * elapsed.TotalSeconds),
+                    MemoryHighWaterBytes = Math.Max(replica.MemoryHighWaterBytes ?? 0, memoryBytes),
+                    MeasuredAt = measuredAt,
+                };
+                changed = true;
+            }
+
+            if (changed)
+            {
+                // Only the usage is written, so that changes made to the run in the meantime, such as
+                // its finalization by the sweeper, are not overwritten.
+                var usage = (run.Usage ?? new RunUsage()) with { Replicas = OrderReplicas(replicas.Values) };
+                await _repository.UpdateRunUsage(run.Id!.Value, usage, cancellationToken);
+            }
+        }
+    }
+
+    /// <summary>
+    /// Adds the wall time and GPU time of each replica and the sizes of the job's buffers to the usage of a run
+    /// that has terminated. The pods of the run must not have been deleted yet.
+    /// </summary>
+    public async Task<Run> CompleteUsage(Run run, IReadOnlyList<V1Pod> pods, CancellationToken cancellationToken)
+    {
+        var replicas = run.Usage?.Replicas?.ToDictionary(r => (r.Target, r.Replica)) ?? [];
+        foreach (var replicaPods in pods.GroupBy(GetReplica))
+        {
+            var wallTime = TimeSpan.Zero;
+            foreach (var pod in replicaPods)
+            {
+                var state = pod.Status?.ContainerStatuses?.SingleOrDefault(c => c.Name == "main")?.State;
+                if (state?.Terminated is { StartedAt: not null, FinishedAt: not null } terminated)
+                {
+                    wallTime += terminated.FinishedAt.Value - terminated.StartedAt.Value;
+                }
+                else if (state?.Running?.StartedAt is DateTime startedAt)
+                {
+                    wallTime += DateTime.UtcNow - startedAt;
+                }
+            }
+
+            var codespec = replicaPods.Key.target == ReplicaUsage.JobTarget ? run.Job.Codespec as Codespec : run.Worker?.Codespec as Codespec;
+            var gpus = codespec?.Resources?.Gpu?.ToDouble() ?? 0;
+
+            var replica = replicas.GetValueOrDefault(replicaPods.Key) ?? new ReplicaUsage { Target = replicaPods.Key.target, Replica = replicaPods.Key.replica };
+            replicas[replicaPods.Key] = replica with
+            {
+                WallTimeSeconds = wallTime.TotalSeconds,
+                GpuSeconds = gpus * wallTime.TotalSeconds,
+            };
+        }
+
+        var buffers = new List<BufferUsage>();
+        if (run.Job.Codespec is JobCodespec { Buffers: var parameters } && run.Job.Buffers is { } bufferIds)
+        {
+            async Task AddBuffers(string[]? parameterNames, string direction)
+            {
+                foreach (var parameter in parameterNames ?? [])
+                {
+                    if (!bufferIds.TryGetValue(parameter, out var bufferId))
+                    {
+                        continue;
+                    }
+
+                    var buffer = await _bufferManager.GetBufferById(bufferId, cancellationToken);
+                    buffers.Add(new BufferUsage { Parameter = parameter, BufferId = bufferId, Direction = direction, SizeBytes = buffer?.ByteCount });
+                }
+            }
+
+            await AddBuffers(parameters?.Inputs, BufferUsage.InputDirection);
+            await AddBuffers(parameters?.Outputs, BufferUsage.OutputDirection);
+        }
+
+        return run with { Usage = new RunUsage { Replicas = OrderReplicas(replicas.Values), Buffers = buffers } };
+    }
+
+    private static List<ReplicaUsage> OrderReplicas(IEnumerable<ReplicaUsage> replicas)
+    {
+        return replicas.OrderBy(r => r.Target == ReplicaUsage.JobTarget ? 0 : 1).ThenBy(r => r.Replica).ToList();
+    }
+
+    private static TimeSpan ParseWindow(string? window)
+    {
+        // The window is a Go duration such as "15s", "1m0.5s", or "500ms"
+        if (string.IsNullOrEmpty(window))
+        {
+            return s_measurementInterval;
+        }
+
+        var total = TimeSpan.Zero;
+        int i = 0;
+        while (i < window.Length)
+        {
+            int start = i;
+            while (i < window.Length && (char.IsDigit(window[i]) || window[i] == '.'))
+            {
+                i++;
+            }
+
+            if (!double.TryParse(window.AsSpan(start, i - start), NumberStyles.Float, CultureInfo.InvariantCulture, out var value))
+            {
+                return s_measurementInterval;
+            }
+
+            start = i;
+            while (i < window.Length && !char.IsDigit(window[i]) && window[i] != '.')
+            {
+                i++;
+            }
+
+            total += window[start..i] switch
+            {
+                "h" => TimeSpan.FromHours(value),
+                "m" => TimeSpan.FromMinutes(value),
+                "s" => TimeSpan.FromSeconds(value),
+                "ms" => TimeSpan.FromMilliseconds(value),
+                "us" or "µs" or "μs" => TimeSpan.FromTicks((long)(value * TimeSpan.TicksPerMillisecond / 1000)),
+                "ns" => TimeSpan.FromTicks((long)(value / 100)),
+                _ => TimeSpan.Zero,
+            };
+        }
+
+        return total == TimeSpan.Zero ? s_measurementInterval : total;
+    }
+
+    public void Dispose()
+    {
+        if (_backgroundTask is { IsCompleted: true })
+        {
+            _backgroundTask.Dispose();
+        }
+    }
+}
@@ -0,0 +1,184 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+using System.ComponentModel.DataAnnotations;
+using System.Globalization;
+using System.Net;
+using System.Text.RegularExpressions;
+using k8s;
+using k8s.Autorest;
+using k8s.Models;
+using Microsoft.Extensions.Options;
+using Tyger.Server.Model;
+using static Tyger.Server.Kubernetes.KubernetesMetadata;
+
+namespace Tyger.Server.Kubernetes;
+
+/// <summary>
+/// Stores secrets that codespecs can reference in their environment variables.
+/// Each secret is a Kubernetes secret, so that its values are only read by the kubelet
+/// when starting a container and are never returned by the API.
+/// </summary>
+public class SecretManager
+{
+    private const string NamePattern = "^[a-z0-9]([-a-z0-9]*[a-z0-9])?$";
+    private const int MaxNameLength = 50;
+    private const string KeyPattern = "^[-._a-zA-Z0-9]+$";
+
+    private readonly IKubernetes _client;
+    private readonly KubernetesApiOptions _k8sOptions;
+    private readonly ILogger<SecretManager> _logger;
+
+    public SecretManager(IKubernetes client, IOptions<KubernetesApiOptions> k8sOptions, ILogger<SecretManager> logger)
+    {
+        _client = client;
+        _k8sOptions = k8sOptions.Value;
+        _logger = logger;
+    }
+
+    /// <summary>
+    /// Creates a secret or replaces the values of an existing one. Returns whether the secret was created.
+    /// </summary>
+    public async Task<(Secret secret, bool created)> UpsertSecret(string name, IDictionary<string, string> data, CancellationToken cancellationToken)
+    {
+        if (name.Length > MaxNameLength || !Regex.IsMatch(name, NamePattern))
+        {
+            throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "Secret names must contain only lower case letters (a-z), numbers (0-9), and dashes (-), must start and end with a letter or number, and must be at most {0} characters long.", MaxNameLength));
+        }
+
+        if (data.Count == 0)
+        {
+            throw new ValidationException("A secret must have at least one key.");
+        }
+
+        foreach (var key in data.Keys)
+        {
+            if (!Regex.IsMatch(key, KeyPattern))
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The secret key '{0}' is invalid. Keys must contain only letters, numbers, dashes (-), underscores (_), and dots (.).", key));
+            }
+        }
+
+        var k8sSecret = new V1Secret
+        {
+            Metadata = new()
+            {
+                Name = KubernetesSecretNameFromSecretName(name),
+                Labels = new Dictionary<string, string> { { SecretLabel, name } },
+            },
+            StringData = new Dictionary<string, string>(data),
+        };
+
+        bool created = true;
+        try
+        {
+            k8sSecret = await _client.CoreV1.CreateNamespacedSecretAsync(k8sSecret, _k8sOptions.Namespace, cancellationToken: cancellationToken);
+        }
+        catch (HttpOperationException e) when (e.Response.StatusCode == HttpStatusCode.Conflict)
+        {
+            created = false;
+            k8sSecret = await _client.CoreV1.ReplaceNamespacedSecretAsync(k8sSecret, k8sSecret.Metadata.Name, _k8sOptions.Namespace, cancellationToken: cancellationToken);
+        }
+
+        _logger.SavedSecret(name);
+        return (ToSecret(k8sSecret), created);
+    }
+
+    public async Task<Secret?> GetSecret(string name, CancellationToken cancellationToken)
+    {
+        return await ReadKubernetesSecret(name, cancellationToken) is V1Secret k8sSecret ? ToSecret(k8sSecret) : null;
+    }
+
+    public async Task<(IList<Secret>, string? nextContinuationToken)> ListSecrets(int limit, string? continuationToken, CancellationToken cancellationToken)
+    {
+        V1SecretList list;
+        try
+        {
+            list = await _client.CoreV1.ListNamespacedSecretAsync(_k8sOptions.Namespace, labelSelector: SecretLabel, limit: limit, continueParameter: continuationToken, cancellationToken: cancellationToken);
+        }
+        catch (HttpOperationException e) when (e.Response.StatusCode is HttpStatusCode.BadRequest or HttpStatusCode.Gone && continuationToken != null)
+        {
+            throw new ValidationException("Invalid continuation token.");
+        }
+
+        var secrets = list.Items.Select(ToSecret).OrderBy(s => s.Name, StringComparer.Ordinal).ToList();
+        return (secrets, string.IsNullOrEmpty(list.Metadata.ContinueProperty) ? null : list.Metadata.ContinueProperty);
+    }
+
+    public async Task<Secret?> DeleteSecret(string name, CancellationToken cancellationToken)
+    {
+        if (await ReadKubernetesSecret(name, cancellationToken) is not V1Secret k8sSecret)
+        {
+            return null;
+        }
+
+        try
+        {
+            await _client.CoreV1.DeleteNamespacedSecretAsync(k8sSecret.Metadata.Name, _k8sOptions.Namespace, cancellationToken: cancellationToken);
+        }
+        catch (HttpOperationException e) when (e.Response.StatusCode == HttpStatusCode.NotFound)
+        {
+            return null;
+        }
+
+        _logger.DeletedSecret(name);
+        return ToSecret(k8sSecret);
+    }
+
+    /// <summary>
+    /// Verifies that the secrets referenced by the environment variables of a codespec exist and have the referenced keys,
+    /// so that a run does not fail to start because of a missing secret.
+    /// </summary>
+    public async Task ValidateSecretRefs(Codespec codespec, CancellationToken cancellationToken)
+    {
+        foreach ((var envName, var envValue) in codespec.Env ?? [])
+        {
+            if (envValue.SecretRef is null)
+            {
+                continue;
+            }
+
+            (var secretName, var key) = envValue.ParseSecretRef();
+            if (await ReadKubernetesSecret(secretName, cancellationToken) is not V1Secret k8sSecret)
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The secret '{0}' referenced by the environment variable '{1}' was not found.", secretName, envName));
+            }
+
+            if (k8sSecret.Data?.ContainsKey(key) != true)
+            {
+                throw new ValidationException(string.Format(CultureInfo.InvariantCulture, "The secret '{0}' referenced by the environment variable '{1}' does not have the key '{2}'.", secretName, envName, key));
+            }
+        }
+    }
+
+    private async Task<V1Secret?> ReadKubernetesSecret(string name, CancellationToken cancellationToken)
+    {
+        if (!Regex.IsMatch(name, NamePattern))
+        {
+            return null;
+        }
+
+        V1Secret k8sSecret;
+        try
+        {
+            k8sSecret = await _client.CoreV1.ReadNamespacedSecretAsync(KubernetesSecretNameFromSecretName(name), _k8sOptions.Namespace, cancellationToken: cancellationToken);
+        }
+        catch (HttpOperationException e) when (e.Response.StatusCode == HttpStatusCode.NotFound)
+        {
+            return null;
+        }
+
+        // Only secrets created by this class can be referenced, not the other secrets of the namespace.
+        return k8sSecret.Labels()?.ContainsKey(SecretLabel) == true ? k8sSecret : null;
+    }
+
+    private static Secret ToSecret(V1Secret k8sSecret)
+    {
+        return new Secret
+        {
+            Name = k8sSecret.Labels()[SecretLabel],
+            Keys = (k8sSecret.Data?.Keys ?? Enumerable.Empty<string>()).Order(StringComparer.Ordinal).ToList(),
+            CreatedAt = k8sSecret.Metadata.CreationTimestamp,
+        };
+    }
+}
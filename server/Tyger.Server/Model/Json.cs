@@ -143,3 +143,56 @@ public class CodespecRefConverter : JsonConverter<ICodespecRef>
         }
     }
 }
+
+public class EnvValueConverter : JsonConverter<EnvValue>
+{
+    private const string SecretRefPattern = @"^[a-z0-9]([-a-z0-9]*[a-z0-9])?/[-._a-zA-Z0-9]+$";
+
+    public override EnvValue? Read(ref Utf8JsonReader reader, Type typeToConvert, JsonSerializerOptions options)
+    {
+        if (reader.TokenType == JsonTokenType.String)
+        {
+            return new EnvValue { Value = reader.GetString() };
+        }
+
+        if (reader.TokenType == JsonTokenType.StartObject)
+        {
+            var obj = JsonElement.ParseValue(ref reader);
+            var propertyName = options.PropertyNamingPolicy == JsonNamingPolicy.CamelCase ? "secretRef" : "SecretRef";
+            if (obj.EnumerateObject().Count() != 1 ||
+                !obj.TryGetProperty(propertyName, out var secretRefProperty) ||
+                secretRefProperty.ValueKind != JsonValueKind.String)
+            {
+                throw new JsonException($"An environment variable object must have only a '{propertyName}' string property.");
+            }
+
+            var secretRef = secretRefProperty.GetString()!;
+            if (!Regex.IsMatch(secretRef, SecretRefPattern))
+            {
+                throw new JsonException(
+                    string.Format(CultureInfo.InvariantCulture,
+                    "The secret reference '{0}' is invalid. It should be in the form '<secret_name>/<key>'.",
+                    secretRef));
+            }
+
+            return new EnvValue { SecretRef = secretRef };
+        }
+
+        throw new JsonException("Expected string or object");
+    }
+
+    public override void Write(Utf8JsonWriter writer, EnvValue value, JsonSerializerOptions options)
+    {
+        if (value.SecretRef != null)
+        {
+            writer.WriteStartObject();
+            writer.WritePropertyName(options.PropertyNamingPolicy == JsonNamingPolicy.CamelCase ? "secretRef" : "SecretRef");
+            writer.WriteStringValue(value.SecretRef);
+            writer.WriteEndObject();
+        }
+        else
+        {
+            writer.WriteStringValue(value.Value);
+        }
+    }
+}
@@ -40,6 +40,66 @@ public record Buffer : ModelBase
     public DateTimeOffset CreatedAt { get; init; }
 
     public IDictionary<string, string>? Tags { get; init; }
+
+    /// <summary>
+    /// The number of seconds after its creation that the buffer expires and is deleted. If not specified, the buffer does not expire.
+    /// </summary>
+    public int? TtlSeconds { get; init; }
+
+    /// <summary>
+    /// The datetime when the buffer expires. Populated by the system.
+    /// </summary>
+    public DateTimeOffset? ExpiresAt { get; init; }
+
+    /// <summary>
+    /// The datetime when the buffer was deleted. Populated by the system. A deleted buffer can no longer be accessed
+    /// and is permanently removed after a grace period.
+    /// </summary>
+    public DateTimeOffset? DeletedAt { get; init; }
+
+    /// <summary>
+    /// The status of the buffer's contents. Populated by the system.
+    /// </summary>
+    [JsonConverter(typeof(JsonStringEnumConverter))]
+    public BufferStatus? Status { get; init; }
+
+    /// <summary>
+    /// The number of bytes stored in the buffer. Populated by the system once the buffer is complete.
+    /// </summary>
+    public long? ByteCount { get; init; }
+
+    /// <summary>
+    /// The number of blobs in the buffer. Populated by the system once the buffer is complete.
+    /// </summary>
+    public long? BlobCount { get; init; }
+
+    public Buffer WithExpiration(DateTimeOffset? expiresAt, DateTimeOffset? deletedAt)
+    {
+        return this with
+        {
+            TtlSeconds = expiresAt.HasValue ? (int)Math.Round((expiresAt.Value - CreatedAt).TotalSeconds) : null,
+            ExpiresAt = expiresAt,
+            DeletedAt = deletedAt
+        };
+    }
+}
+
+public enum BufferStatus
+{
+    /// <summary>
+    /// The buffer has not yet been marked as complete or failed by its writer
+    /// </summary>
+    Pending,
+
+    /// <summary>
+    /// The buffer has been completely written
+    /// </summary>
+    Complete,
+
+    /// <summary>
+    /// The writer of the buffer failed
+    /// </summary>
+    Failed,
 }
 
 public record BufferAccess(Uri Uri) : ModelBase;
@@ -79,7 +139,7 @@ public enum CodespecKind
 
 [Equatable]
 [JsonConverter(typeof(CodespecConverter))]
-public abstract partial record Codespec : ModelBase, ICodespecRef
+public abstract partial record Codespec : ModelBase, ICodespecRef, IValidatableObject
 {
     protected Codespec(CodespecKind kind) => Kind = kind;
 
@@ -129,10 +189,10 @@ public abstract partial record Codespec : ModelBase, ICodespecRef
     public string? WorkingDir { get; init; }
 
     /// <summary>
-    /// Environment variables to set in the container
+    /// Environment variables to set in the container. A value is either a string or an object with a secretRef in the form 'name/key'.
     /// </summary>
     [UnorderedEquality]
-    public Dictionary<string, string>? Env { get; init; }
+    public Dictionary<string, EnvValue>? Env { get; init; }
 
     /// <summary>
     /// Container resource requests and limits
@@ -144,8 +204,41 @@ public abstract partial record Codespec : ModelBase, ICodespecRef
     /// </summary>
     public int? MaxReplicas { get; init; }
 
+    /// <summary>
+    /// Datasets to mount read-only into the container.
+    /// </summary>
+    [OrderedEquality]
+    public DatasetMount[]? Mounts { get; init; }
+
     public virtual ICodespecRef ToCodespecRef() => this;
 
+    public virtual IEnumerable<ValidationResult> Validate(ValidationContext validationContext) => ValidateMounts();
+
+    /// <summary>
+    /// Checks that mount paths are absolute and that no dataset or path is used by more than one mount.
+    /// </summary>
+    public IEnumerable<ValidationResult> ValidateMounts()
+    {
+        var datasets = new HashSet<string>(StringComparer.Ordinal);
+        var paths = new HashSet<string>(StringComparer.Ordinal);
+        foreach (var mount in Mounts ?? [])
+        {
+            if (!mount.Path.StartsWith('/'))
+            {
+                yield return new ValidationResult(string.Format(CultureInfo.InvariantCulture, "The mount path '{0}' of the dataset '{1}' must be an absolute path.", mount.Path, mount.Dataset));
+            }
+            else if (!paths.Add(mount.Path.TrimEnd('/')))
+            {
+                yield return new ValidationResult(string.Format(CultureInfo.InvariantCulture, "The mount path '{0}' is used by more than one mount.", mount.Path));
+            }
+
+            if (!datasets.Add(mount.Dataset))
+            {
+                yield return new ValidationResult(string.Format(CultureInfo.InvariantCulture, "The dataset '{0}' is mounted more than once.", mount.Dataset));
+            }
+        }
+    }
+
     public Codespec WithoutSystemProperties()
     {
         return this with
@@ -167,8 +260,56 @@ public abstract partial record Codespec : ModelBase, ICodespecRef
     }
 }
 
+/// <summary>
+/// The value of an environment variable of a codespec. Either a literal value or a reference
+/// to a key of a secret, whose value is only given to the container.
+/// </summary>
+[JsonConverter(typeof(EnvValueConverter))]
+public record EnvValue
+{
+    public string? Value { get; init; }
+
+    /// <summary>
+    /// A reference to a key of a secret in the form 'name/key'.
+    /// </summary>
+    public string? SecretRef { get; init; }
+
+    public static implicit operator EnvValue(string value) => new() { Value = value };
+
+    public (string Name, string Key) ParseSecretRef()
+    {
+        var parts = SecretRef!.Split('/');
+        return (parts[0], parts[1]);
+    }
+}
+
+/// <summary>
+/// A read-only mount of a version of a dataset into a container.
+/// </summary>
+[Equatable]
+public partial record DatasetMount : ModelBase
+{
+    /// <summary>
+    /// The name of the dataset.
+    /// </summary>
+    [Required, Display(Name = "dataset")]
+    public required string Dataset { get; init; }
+
+    /// <summary>
+    /// The version of the dataset. If not specified, the latest version when the run is created is used,
+    /// unless the run specifies a version.
+    /// </summary>
+    public int? Version { get; init; }
+
+    /// <summary>
+    /// The absolute path in the container where the dataset is mounted.
+    /// </summary>
+    [Required, Display(Name = "path")]
+    public required string Path { get; init; }
+}
+
 [Equatable]
-public partial record JobCodespec : Codespec, IValidatableObject
+public partial record JobCodespec : Codespec
 {
     public JobCodespec() : base(CodespecKind.Job) { }
 
@@ -177,8 +318,13 @@ public partial record JobCodespec : Codespec, IValidatableObject
     /// </summary>
     public BufferParameters? Buffers { get; init; }
 
-    public IEnumerable<ValidationResult> Validate(ValidationContext validationContext)
+    public override IEnumerable<ValidationResult> Validate(ValidationContext validationContext)
     {
+        foreach (var result in base.Validate(validationContext))
+        {
+            yield return result;
+        }
+
         if (Buffers != null)
         {
             var combined = (Buffers.Inputs ?? Enumerable.Empty<string>()).Concat(Buffers.Outputs ?? Enumerable.Empty<string>());
@@ -234,6 +380,12 @@ public record RunCodeTarget : ModelBase
     /// The number of replicas to run. Defaults to 1.
     /// </summary>
     public int Replicas { get; init; } = 1;
+
+    /// <summary>
+    /// The versions of the datasets mounted by the codespec, by dataset name. These override the versions in the codespec.
+    /// Populated by the system with the versions that are mounted.
+    /// </summary>
+    public Dictionary<string, int>? Datasets { get; init; }
 }
 
 public record JobRunCodeTarget : RunCodeTarget
@@ -344,6 +496,21 @@ public record Run : ModelBase
     /// </summary>
     public string? Cluster { get; init; }
 
+    /// <summary>
+    /// An optional policy for retrying the job when it fails.
+    /// </summary>
+    public RunRetryPolicy? RetryPolicy { get; init; }
+
+    /// <summary>
+    /// The attempts of each job replica. Populated by the system for runs that have a retry policy.
+    /// </summary>
+    public IReadOnlyList<RunAttempt>? Attempts { get; init; }
+
+    /// <summary>
+    /// The resources consumed by the run. Populated by the system.
+    /// </summary>
+    public RunUsage? Usage { get; init; }
+
     public Run WithoutSystemProperties()
     {
         return this with
@@ -353,19 +520,318 @@ public record Run : ModelBase
             StatusReason = null,
             RunningCount = null,
             CreatedAt = default,
-            FinishedAt = null
+            FinishedAt = null,
+            Attempts = null,
+            Usage = null
         };
     }
 }
 
+public record RunRetryPolicy : ModelBase
+{
+    public const string PreemptedReason = "Preempted";
+    public const string ErrorReason = "Error";
+    public const int MaxBackoffSeconds = 3600;
+
+    /// <summary>
+    /// The maximum number of times each job replica is attempted, including the first attempt. Must be between 1 and 10.
+    /// </summary>
+    public int MaxAttempts { get; init; } = 1;
+
+    /// <summary>
+    /// The reasons for which a failed attempt is retried. 'Preempted' covers pods that were preempted, evicted,
+    /// or stopped because their node was drained or lost. 'Error' covers any other failure, such as a non-zero exit code.
+    /// Defaults to 'Preempted'.
+    /// </summary>
+    public string[]? RetryableReasons { get; init; }
+
+    /// <summary>
+    /// The number of seconds to wait before attempting a failed job replica again. The wait doubles with each failed attempt
+    /// of the replica, up to one hour, and is in addition to the delay that Kubernetes applies before recreating a failed pod.
+    /// Must be between 0 and 3600. Defaults to 0.
+    /// </summary>
+    public int? BackoffSeconds { get; init; }
+
+    public IReadOnlyList<string> GetRetryableReasons() => RetryableReasons ?? [PreemptedReason];
+}
+
+public record RunAttempt : ModelBase
+{
+    /// <summary>
+    /// The index of the job replica
+    /// </summary>
+    public int Replica { get; init; }
+
+    /// <summary>
+    /// The attempt number for the replica, starting at 1
+    /// </summary>
+    public int Attempt { get; init; }
+
+    /// <summary>
+    /// The status of the attempt
+    /// </summary>
+    [JsonConverter(typeof(JsonStringEnumConverter))]
+    public RunStatus Status { get; init; }
+
+    /// <summary>
+    /// The reason for the status of the attempt
+    /// </summary>
+    public string? StatusReason { get; init; }
+
+    /// <summary>
+    /// The time the attempt started
+    /// </summary>
+    public DateTimeOffset? StartedAt { get; init; }
+
+    /// <summary>
+    /// The time the attempt finished
+    /// </summary>
+    public DateTimeOffset? FinishedAt { get; init; }
+}
+
+public record RunUsage : ModelBase
+{
+    /// <summary>
+    /// The resources consumed by each replica of the job and of the worker
+    /// </summary>
+    public IReadOnlyList<ReplicaUsage>? Replicas { get; init; }
+
+    /// <summary>
+    /// The size of each buffer of the job when the run completed. Populated once the run has completed.
+    /// </summary>
+    public IReadOnlyList<BufferUsage>? Buffers { get; init; }
+}
+
+public record ReplicaUsage : ModelBase
+{
+    public const string JobTarget = "job";
+    public const string WorkerTarget = "worker";
+
+    /// <summary>
+    /// Either 'job' or 'worker'
+    /// </summary>
+    public string Target { get; init; } = "";
+
+    /// <summary>
+    /// The index of the replica
+    /// </summary>
+    public int Replica { get; init; }
+
+    /// <summary>
+    /// The time the main container of the replica ran, summed over all attempts. Populated once the run has completed.
+    /// </summary>
+    public double? WallTimeSeconds { get; init; }
+
+    /// <summary>
+    /// The CPU time used by the main container of the replica. Estimated from periodic measurements.
+    /// </summary>
+    public double? CpuSeconds { get; init; }
+
+    /// <summary>
+    /// The highest memory usage of the main container of the replica among the periodic measurements.
+    /// This is a sampled peak, so short spikes between measurements are not captured.
+    /// </summary>
+    public long? MemoryHighWaterBytes { get; init; }
+
+    /// <summary>
+    /// The number of GPUs allocated to the replica multiplied by its wall time. Populated once the run has completed.
+    /// </summary>
+    public double? GpuSeconds { get; init; }
+
+    /// <summary>
+    /// The time of the last measurement of the CPU and memory usage of the replica.
+    /// </summary>
+    public DateTimeOffset? MeasuredAt { get; init; }
+}
+
+public record BufferUsage : ModelBase
+{
+    public const string InputDirection = "input";
+    public const string OutputDirection = "output";
+
+    /// <summary>
+    /// The buffer parameter of the job codespec
+    /// </summary>
+    public string Parameter { get; init; } = "";
+
+    /// <summary>
+    /// The ID of the buffer
+    /// </summary>
+    public string BufferId { get; init; } = "";
+
+    /// <summary>
+    /// Either 'input' or 'output'
+    /// </summary>
+    public string Direction { get; init; } = "";
+
+    /// <summary>
+    /// The size of the buffer in bytes. This is the stored size of the buffer, not the number of bytes
+    /// the run actually transferred. Only known when the buffer is complete.
+    /// </summary>
+    public long? SizeBytes { get; init; }
+}
+
+public record RunEvent : ModelBase
+{
+    public const string CreatedType = "created";
+    public const string ScheduledType = "scheduled";
+    public const string SchedulingFailedType = "schedulingFailed";
+    public const string ImagePullingType = "imagePulling";
+    public const string ImagePulledType = "imagePulled";
+    public const string ImagePullFailedType = "imagePullFailed";
+    public const string ReplicaStartedType = "replicaStarted";
+    public const string ReplicaExitedType = "replicaExited";
+    public const string OomKilledType = "oomKilled";
+    public const string BufferOpenedType = "bufferOpened";
+    public const string BufferClosedType = "bufferClosed";
+    public const string WarningType = "warning";
+    public const string FinishedType = "finished";
+
+    /// <summary>
+    /// The type of the event. One of 'created', 'scheduled', 'schedulingFailed', 'imagePulling', 'imagePulled', 'imagePullFailed', 'replicaStarted', 'replicaExited', 'oomKilled', 'bufferOpened', 'bufferClosed', 'warning', or 'finished'.
+    /// </summary>
+    public string Type { get; init; } = "";
+
+    /// <summary>
+    /// The time of the event
+    /// </summary>
+    public DateTimeOffset Timestamp { get; init; }
+
+    /// <summary>
+    /// Either 'job' or 'worker' for events about a replica
+    /// </summary>
+    public string? Target { get; init; }
+
+    /// <summary>
+    /// The index of the replica for events about a replica
+    /// </summary>
+    public int? Replica { get; init; }
+
+    /// <summary>
+    /// The buffer parameter for 'bufferOpened' and 'bufferClosed' events
+    /// </summary>
+    public string? Buffer { get; init; }
+
+    /// <summary>
+    /// The exit code of the container for 'replicaExited', 'oomKilled', and 'bufferClosed' events
+    /// </summary>
+    public int? ExitCode { get; init; }
+
+    /// <summary>
+    /// A description of the event
+    /// </summary>
+    public string? Message { get; init; }
+}
+
 public record DatabaseVersionInUse(int Id) : ModelBase;
 
 public record RunPage(IReadOnlyList<Run> Items, Uri? NextLink);
 
+/// <summary>
+/// Criteria for listing runs. Null criteria match every run.
+/// </summary>
+public record RunFilter
+{
+    public DateTimeOffset? Since { get; init; }
+    public RunStatus? Status { get; init; }
+    public string? CodespecName { get; init; }
+    public int? CodespecVersion { get; init; }
+    public IDictionary<string, string>? Tags { get; init; }
+    public DateTimeOffset? FinishedBefore { get; init; }
+    public DateTimeOffset? FinishedAfter { get; init; }
+
+    /// <summary>
+    /// Whether the status and finish time of the run match. The other criteria do not change
+    /// over the lifetime of a run and are applied by the database query.
+    /// </summary>
+    public bool MatchesStatus(Run run)
+    {
+        if (Status.HasValue && run.Status != Status)
+        {
+            return false;
+        }
+
+        if (FinishedBefore.HasValue && !(run.FinishedAt < FinishedBefore))
+        {
+            return false;
+        }
+
+        if (FinishedAfter.HasValue && !(run.FinishedAt > FinishedAfter))
+        {
+            return false;
+        }
+
+        return true;
+    }
+}
+
 public record CodespecPage(IList<Codespec> Items, Uri? NextLink);
 
 public record BufferPage(IList<Buffer> Items, Uri? NextLink);
 
+public record Secret : ModelBase
+{
+    public string Name { get; init; } = "";
+
+    /// <summary>
+    /// The keys of the secret. The values are never returned.
+    /// </summary>
+    public IReadOnlyList<string> Keys { get; init; } = [];
+
+    public DateTimeOffset? CreatedAt { get; init; }
+}
+
+public record SecretData : ModelBase
+{
+    /// <summary>
+    /// The values of the secret by key. These replace any existing values of the secret.
+    /// </summary>
+    [Required, Display(Name = "data")]
+    public required Dictionary<string, string> Data { get; init; }
+}
+
+public record SecretPage(IList<Secret> Items, Uri? NextLink);
+
+public record Dataset : ModelBase
+{
+    public string Name { get; init; } = "";
+
+    public int Version { get; init; }
+
+    /// <summary>
+    /// The ID of the buffer that holds the contents of the dataset version.
+    /// </summary>
+    public string BufferId { get; init; } = "";
+
+    /// <summary>
+    /// The number of bytes stored for the dataset version, if known.
+    /// </summary>
+    public long? ByteCount { get; init; }
+
+    public DateTimeOffset CreatedAt { get; init; }
+}
+
+public record NewDatasetVersion : ModelBase
+{
+    /// <summary>
+    /// The ID of a complete buffer that holds a tar archive of the contents of the dataset version.
+    /// </summary>
+    [Required, Display(Name = "bufferId")]
+    public required string BufferId { get; init; }
+}
+
+public record DatasetPage(IList<Dataset> Items, Uri? NextLink);
+
+/// <summary>
+/// A problem with a codespec that would cause it to be rejected or a run using it to fail.
+/// </summary>
+public record CodespecProblem(string Field, string Message);
+
+/// <summary>
+/// The result of validating a codespec. The codespec is valid if there are no problems.
+/// </summary>
+public record CodespecValidationResult(IReadOnlyList<CodespecProblem> Problems);
+
 public record Cluster(string Name, string Location, IReadOnlyList<NodePool> NodePools);
 
 public record NodePool(string Name, string VmSize);
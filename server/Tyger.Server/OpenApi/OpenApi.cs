@@ -32,6 +32,20 @@ public static class OpenApi
 
             c.MapType<ResourceQuantity>(() => new OpenApiSchema { Type = "string" });
             c.MapType<CommittedCodespecRef>(() => new OpenApiSchema { Type = "string" });
+            c.MapType<EnvValue>(() => new OpenApiSchema
+            {
+                OneOf =
+                [
+                    new OpenApiSchema { Type = "string" },
+                    new OpenApiSchema
+                    {
+                        Type = "object",
+                        Properties = new Dictionary<string, OpenApiSchema> { ["secretRef"] = new OpenApiSchema { Type = "string" } },
+                        Required = new HashSet<string> { "secretRef" },
+                        AdditionalPropertiesAllowed = false,
+                    },
+                ],
+            });
             c.MapType<RunStatus>(() => new OpenApiSchema
             {
                 Type = "string",
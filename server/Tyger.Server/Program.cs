@@ -5,9 +5,11 @@ using System.CommandLine;
 using System.CommandLine.Parsing;
 using Tyger.Server.Auth;
 using Tyger.Server.Buffers;
+using Tyger.Server.Clusters;
 using Tyger.Server.Codespecs;
 using Tyger.Server.Configuration;
 using Tyger.Server.Database;
+using Tyger.Server.Datasets;
 using Tyger.Server.Identity;
 using Tyger.Server.Json;
 using Tyger.Server.Kubernetes;
@@ -16,6 +18,7 @@ using Tyger.Server.Middleware;
 using Tyger.Server.Model;
 using Tyger.Server.OpenApi;
 using Tyger.Server.Runs;
+using Tyger.Server.Secrets;
 using Tyger.Server.ServiceMetadata;
 
 var rootCommand = new RootCommand("Tyger Server");
@@ -72,6 +75,7 @@ void RunServer()
     app.UseRequestId();
     app.UseBaggage();
     app.UseExceptionHandling();
+    app.UseWebSockets();
 
     app.UseOpenApi();
     app.UseAuth();
@@ -79,6 +83,9 @@ void RunServer()
     app.MapBuffers();
     app.MapCodespecs();
     app.MapRuns();
+    app.MapSecrets();
+    app.MapDatasets();
+    app.MapClusters();
 
     app.MapServiceMetadata();
     app.MapDatabaseVersionInUse();
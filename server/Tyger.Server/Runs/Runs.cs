@@ -1,7 +1,9 @@
 // Copyright (c) Microsoft Corporation.
 // Licensed under the MIT License.
 
+using System.ComponentModel.DataAnnotations;
 using System.Text.Json;
+using k8s.Models;
 using Microsoft.AspNetCore.Mvc;
 using Microsoft.AspNetCore.WebUtilities;
 using Microsoft.Extensions.Primitives;
@@ -28,10 +30,57 @@ public static class Runs
         .Produces<Run>(StatusCodes.Status201Created)
         .Produces<ErrorBody>(StatusCodes.Status400BadRequest);
 
-        app.MapGet("/v1/runs", async (RunReader runReader, int? limit, DateTimeOffset? since, [FromQuery(Name = "_ct")] string? continuationToken, HttpContext context) =>
+        app.MapGet("/v1/runs", async (
+            RunReader runReader,
+            int? limit,
+            DateTimeOffset? since,
+            string? status,
+            string? codespec,
+            int? codespecVersion,
+            DateTimeOffset? finishedBefore,
+            DateTimeOffset? finishedAfter,
+            [FromQuery(Name = "_ct")] string? continuationToken,
+            HttpContext context) =>
         {
             limit = limit is null ? 20 : Math.Min(limit.Value, 200);
-            (var items, var nextContinuationToken) = await runReader.ListRuns(limit.Value, since, continuationToken, context.RequestAborted);
+
+            RunStatus? parsedStatus = null;
+            if (status != null)
+            {
+                if (!Enum.TryParse<RunStatus>(status, ignoreCase: true, out var parsed) || !Enum.IsDefined(parsed))
+                {
+                    throw new ValidationException($"Invalid status '{status}'. Must be one of {string.Join(", ", Enum.GetNames<RunStatus>())}.");
+                }
+
+                parsedStatus = parsed;
+            }
+
+            if (codespecVersion.HasValue && codespec == null)
+            {
+                throw new ValidationException("The codespecVersion parameter can only be used together with the codespec parameter.");
+            }
+
+            var tagQuery = new Dictionary<string, string>();
+            foreach (var tag in context.Request.Query)
+            {
+                if (tag.Key.StartsWith("tag.", StringComparison.Ordinal))
+                {
+                    tagQuery.Add(tag.Key[4..], tag.Value.FirstOrDefault() ?? "");
+                }
+            }
+
+            var filter = new RunFilter
+            {
+                Since = since,
+                Status = parsedStatus,
+                CodespecName = codespec,
+                CodespecVersion = codespecVersion,
+                Tags = tagQuery.Count == 0 ? null : tagQuery,
+                FinishedBefore = finishedBefore,
+                FinishedAfter = finishedAfter,
+            };
+
+            (var items, var nextContinuationToken) = await runReader.ListRuns(limit.Value, filter, continuationToken, context.RequestAborted);
 
             string? nextLink;
             if (nextContinuationToken is null)
@@ -135,6 +184,84 @@ public static class Runs
         .Produces(StatusCodes.Status200OK, null, "text/plain")
         .Produces<ErrorBody>(StatusCodes.Status404NotFound);
 
+        app.MapGet("/v1/runs/{runId}/events", async (
+            string runId,
+            bool? watch,
+            RunEventReader runEventReader,
+            HttpContext context,
+            JsonSerializerOptions serializerOptions) =>
+        {
+            if (!long.TryParse(runId, out var parsedRunId))
+            {
+                return Responses.NotFound();
+            }
+
+            if (!watch.GetValueOrDefault())
+            {
+                if (await runEventReader.GetEvents(parsedRunId, context.RequestAborted) is not IReadOnlyList<RunEvent> events)
+                {
+                    return Responses.NotFound();
+                }
+
+                return Results.Ok(events);
+            }
+
+            bool any = false;
+            await foreach (var runEvent in runEventReader.WatchEvents(parsedRunId, context.RequestAborted))
+            {
+                if (!any)
+                {
+                    any = true;
+                    context.Response.StatusCode = StatusCodes.Status200OK;
+                    context.Response.ContentType = "application/json; charset=utf-8";
+                }
+
+                await JsonSerializer.SerializeAsync(context.Response.Body, runEvent, serializerOptions, context.RequestAborted);
+                await context.Response.Body.WriteAsync(s_newline, context.RequestAborted);
+                await context.Response.Body.FlushAsync(context.RequestAborted);
+            }
+
+            if (!any)
+            {
+                return Responses.NotFound();
+            }
+
+            return Results.Empty;
+        })
+        .Produces<IReadOnlyList<RunEvent>>(StatusCodes.Status200OK)
+        .Produces<ErrorBody>(StatusCodes.Status404NotFound);
+
+        // Executes a command in a running job replica over a WebSocket. This is not described
+        // in the OpenAPI spec since it cannot represent WebSocket endpoints.
+        app.MapGet("/v1/runs/{runId}/exec", async (
+            string runId,
+            int? replica,
+            bool? tty,
+            string[]? command,
+            RunExecutor runExecutor,
+            HttpContext context) =>
+        {
+            if (!context.WebSockets.IsWebSocketRequest)
+            {
+                return Responses.BadRequest("WebSocketRequired", "This endpoint must be called with a WebSocket request.");
+            }
+
+            if (command is null or [])
+            {
+                return Responses.BadRequest("InvalidCommand", "The command to execute must be given with the 'command' query parameter.");
+            }
+
+            if (!long.TryParse(runId, out var parsedRunId) ||
+                await runExecutor.GetReplicaPod(parsedRunId, replica.GetValueOrDefault(), context.RequestAborted) is not V1Pod pod)
+            {
+                return Responses.NotFound();
+            }
+
+            using var webSocket = await context.WebSockets.AcceptWebSocketAsync();
+            await runExecutor.Exec(pod, command, tty.GetValueOrDefault(), webSocket, context.RequestAborted);
+            return Results.Empty;
+        }).ExcludeFromDescription();
+
         app.MapPost("/v1/runs/{runId}/cancel", async (
             string runId,
             RunUpdater runUpdater,
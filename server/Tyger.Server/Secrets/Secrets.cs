@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+using Microsoft.AspNetCore.Mvc;
+using Microsoft.AspNetCore.WebUtilities;
+using Microsoft.Extensions.Primitives;
+using Tyger.Server.Json;
+using Tyger.Server.Kubernetes;
+using Tyger.Server.Model;
+
+namespace Tyger.Server.Secrets;
+
+public static class Secrets
+{
+    public static void MapSecrets(this WebApplication app)
+    {
+        app.MapPut("/v1/secrets/{name}", async (string name, SecretManager secretManager, HttpContext context) =>
+        {
+            var secretData = await context.Request.ReadAndValidateJson<SecretData>(context.RequestAborted);
+            (var secret, var created) = await secretManager.UpsertSecret(name, secretData.Data, context.RequestAborted);
+            return Results.Json(secret, statusCode: created ? StatusCodes.Status201Created : StatusCodes.Status200OK);
+        })
+        .Accepts<SecretData>("application/json")
+        .Produces<Secret>(StatusCodes.Status200OK)
+        .Produces<Secret>(StatusCodes.Status201Created)
+        .Produces<ErrorBody>(StatusCodes.Status400BadRequest);
+
+        app.MapGet("/v1/secrets", async (SecretManager secretManager, int? limit, [FromQuery(Name = "_ct")] string? continuationToken, HttpContext context) =>
+        {
+            limit = limit is null ? 20 : Math.Min(limit.Value, 200);
+            (var secrets, var nextContinuationToken) = await secretManager.ListSecrets(limit.Value, continuationToken, context.RequestAborted);
+
+            string? nextLink;
+            if (nextContinuationToken is null)
+            {
+                nextLink = null;
+            }
+            else if (context.Request.QueryString.HasValue)
+            {
+                var qd = QueryHelpers.ParseQuery(context.Request.QueryString.Value);
+                qd["_ct"] = new StringValues(nextContinuationToken);
+                nextLink = QueryHelpers.AddQueryString(context.Request.Path, qd);
+            }
+            else
+            {
+                nextLink = QueryHelpers.AddQueryString(context.Request.Path, "_ct", nextContinuationToken);
+            }
+
+            return Results.Ok(new SecretPage(secrets, nextLink == null ? null : new Uri(nextLink)));
+        })
+        .Produces<SecretPage>();
+
+        app.MapGet("/v1/secrets/{name}", async (string name, SecretManager secretManager, HttpContext context) =>
+        {
+            if (await secretManager.GetSecret(name, context.RequestAborted) is not Secret secret)
+            {
+                return Responses.NotFound();
+            }
+
+            return Results.Ok(secret);
+        })
+        .Produces<Secret>()
+        .Produces<ErrorBody>(StatusCodes.Status404NotFound);
+
+        app.MapDelete("/v1/secrets/{name}", async (string name, SecretManager secretManager, HttpContext context) =>
+        {
+            if (await secretManager.DeleteSecret(name, context.RequestAborted) is not Secret secret)
+            {
+                return Responses.NotFound();
+            }
+
+            return Results.Ok(secret);
+        })
+        .Produces<Secret>(StatusCodes.Status200OK)
+        .Produces<ErrorBody>(StatusCodes.Status404NotFound);
+    }
+}